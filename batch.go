@@ -0,0 +1,169 @@
+package twikey
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// batchJob is a single item of a batch as seen by runBatch, send creates it at Twikey
+type batchJob struct {
+	key  string
+	send func(ctx context.Context) batchOutcome
+}
+
+// batchOutcome is the result of an item independent of its type, see InvoiceBatchResult and TransactionBatchResult
+type batchOutcome struct {
+	key     string
+	id      string      // id of the created item (also when skipped if the journal has it)
+	created interface{} // the created item (nil when skipped or failed)
+	skipped bool
+	err     error
+}
+
+// batchSettings are the options shared by all batches
+type batchSettings struct {
+	concurrency       int
+	requestsPerSecond float64
+	journalPath       string
+}
+
+// runBatch reads the jobs using next and sends them with bounded concurrency, passing the outcome of every item
+// to emit and calling done once all items are handled or the context is cancelled. next returns io.EOF when no
+// more items are available, a job along with an error reports an item that couldn't be read while an error
+// without a job stops the batch. Items that are in the journal are skipped, the others are added once accepted.
+func runBatch(ctx context.Context, settings batchSettings, next func() (*batchJob, error), emit func(batchOutcome), done func()) error {
+	journal, accepted, err := openJournal(settings.journalPath)
+	if err != nil {
+		return err
+	}
+
+	concurrency := settings.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var throttle <-chan time.Time
+	var ticker *time.Ticker
+	if settings.requestsPerSecond > 0 {
+		ticker = time.NewTicker(time.Duration(float64(time.Second) / settings.requestsPerSecond))
+		throttle = ticker.C
+	}
+
+	jobs := make(chan *batchJob)
+
+	// producer
+	go func() {
+		defer close(jobs)
+		for ctx.Err() == nil {
+			job, err := next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				if job == nil {
+					emit(batchOutcome{err: err})
+					return
+				}
+				emit(batchOutcome{key: job.key, err: err})
+				continue
+			}
+			if job == nil {
+				emit(batchOutcome{err: errors.New("the iterator returned no item")})
+				continue
+			}
+			if id, ok := accepted[job.key]; ok {
+				emit(batchOutcome{key: job.key, id: id, skipped: true})
+				continue
+			}
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if throttle != nil {
+					select {
+					case <-throttle:
+					case <-ctx.Done():
+						return
+					}
+				}
+				outcome := job.send(ctx)
+				outcome.key = job.key
+				if outcome.err == nil && journal != nil {
+					outcome.err = journal.accept(job.key, outcome.id)
+				}
+				emit(outcome)
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		if ticker != nil {
+			ticker.Stop()
+		}
+		if journal != nil {
+			_ = journal.close()
+		}
+		done()
+	}()
+	return nil
+}
+
+// batchJournal records the accepted items of a batch, one per line as "key<TAB>id"
+type batchJournal struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// openJournal opens (or creates) the journal and returns the keys that were already accepted with their id
+func openJournal(path string) (*batchJournal, map[string]string, error) {
+	accepted := map[string]string{}
+	if path == "" {
+		return nil, accepted, nil
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "\t", 2)
+		if parts[0] == "" {
+			continue
+		}
+		accepted[parts[0]] = ""
+		if len(parts) == 2 {
+			accepted[parts[0]] = parts[1]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+	return &batchJournal{file: file}, accepted, nil
+}
+
+func (j *batchJournal) accept(key string, id string) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	_, err := j.file.WriteString(key + "\t" + id + "\n")
+	return err
+}
+
+func (j *batchJournal) close() error {
+	return j.file.Close()
+}
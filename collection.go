@@ -0,0 +1,178 @@
+package twikey
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CollectRequest selects the open transactions to be sent to the bank for collection
+type CollectRequest struct {
+	IdempotencyKey string
+	// Template is the id (ct) or the name of the template of which the transactions are collected
+	Template string
+	// Prenotify sends a prenotification to the customers before collecting
+	Prenotify bool
+	// Until only collects the transactions created before this moment
+	Until *time.Time
+	// CollectionDate is the requested date of the collection (yyyy-mm-dd), default the first possible date
+	CollectionDate string
+	// TransactionIds only collects these transactions
+	TransactionIds []int64
+	// Refs only collects the transactions with these references
+	Refs []string
+	// MaxAmount limits the total amount of the collection, 0 means no limit
+	MaxAmount float64
+	// MaxCount limits the number of transactions in the collection, 0 means no limit
+	MaxCount int
+}
+
+// Validate checks the request locally and returns a ValidationError listing all invalid fields
+func (request *CollectRequest) Validate() error {
+	return request.validateAt(time.Now())
+}
+
+func (request *CollectRequest) validateAt(now time.Time) error {
+	v := validation{}
+	v.required("Template", request.Template)
+	v.date("CollectionDate", request.CollectionDate)
+	if date, err := time.Parse("2006-01-02", request.CollectionDate); err == nil && date.Before(dateOf(now)) {
+		v.fail("CollectionDate", "should not be in the past")
+	}
+	if request.MaxAmount < 0 {
+		v.fail("MaxAmount", "should be positive")
+	}
+	if request.MaxCount < 0 {
+		v.fail("MaxCount", "should be positive")
+	}
+	return v.err()
+}
+
+func (request *CollectRequest) asUrlParams() url.Values {
+	params := url.Values{}
+	if _, err := strconv.Atoi(request.Template); err == nil {
+		params.Add("ct", request.Template)
+	} else {
+		params.Add("tc", request.Template)
+	}
+	if request.Prenotify {
+		params.Add("prenotify", "true")
+	}
+	if request.Until != nil {
+		params.Add("until", strconv.FormatInt(request.Until.UnixNano()/int64(time.Millisecond), 10))
+	}
+	addIfExists(params, "colldt", request.CollectionDate)
+	for _, id := range request.TransactionIds {
+		params.Add("id", strconv.FormatInt(id, 10))
+	}
+	for _, ref := range request.Refs {
+		params.Add("ref", ref)
+	}
+	if request.MaxAmount != 0 {
+		params.Add("maxAmount", fmt.Sprintf("%.2f", request.MaxAmount))
+	}
+	if request.MaxCount != 0 {
+		params.Add("maxCount", strconv.Itoa(request.MaxCount))
+	}
+	return params
+}
+
+// Collection states
+const (
+	CollectionStatePrepared = "PREPARED" // Collection was created but not yet sent
+	CollectionStateSent     = "SENT"     // Collection was sent to the bank
+	CollectionStateError    = "ERROR"    // Bank refused the collection
+)
+
+// Collection is a group of transactions sent to the bank at once
+type Collection struct {
+	Id             string  `json:"rcurMsgId"` // Empty when there was nothing to collect
+	State          string  `json:"state,omitempty"`
+	Count          int     `json:"count"`  // Number of transactions
+	Amount         float64 `json:"amount"` // Total amount of the transactions
+	CollectionDate string  `json:"colldt,omitempty"`
+	Error          string  `json:"error,omitempty"` // Reason the bank refused the collection
+
+	IdempotencyKey   string `json:"-"` // key used when creating the collection
+	IdempotentReplay bool   `json:"-"` // true if Twikey returned the result of a previous call with the same key
+}
+
+func (collection *Collection) setIdempotency(key string, replayed bool) {
+	collection.IdempotencyKey = key
+	collection.IdempotentReplay = replayed
+}
+
+// IsEmpty returns true when no transactions were collected
+func (collection *Collection) IsEmpty() bool {
+	return collection.Id == ""
+}
+
+// IsSent returns true when the collection was sent to the bank
+func (collection *Collection) IsSent() bool {
+	return collection.State == CollectionStateSent
+}
+
+// IsFinal returns true when the state of the collection no longer changes
+func (collection *Collection) IsFinal() bool {
+	return collection.State == CollectionStateSent || collection.State == CollectionStateError
+}
+
+// Collect groups the selected open transactions in a collection to be sent to the bank
+func (c *Client) Collect(ctx context.Context, request *CollectRequest) (*Collection, error) {
+	if err := c.validate(request); err != nil {
+		return nil, err
+	}
+	params := request.asUrlParams()
+	c.Debug.Debugf("Collecting transactions using %s", params.Encode())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/creditor/collect", strings.NewReader(params.Encode()))
+	c.addIdempotencyKey(req, &request.IdempotencyKey, []byte(params.Encode()))
+	collection := &Collection{}
+	if err := c.sendRequest(req, collection); err != nil {
+		return nil, err
+	}
+	c.Debug.Debugf("Collected %d transactions for %s into %s", collection.Count, request.Template, collection.Id)
+	return collection, nil
+}
+
+// CollectionDetail returns the current state of a collection
+func (c *Client) CollectionDetail(ctx context.Context, id string) (*Collection, error) {
+	if id == "" {
+		return nil, NewTwikeyError("err_invalid_collection", "A collection is required", "")
+	}
+	params := url.Values{}
+	params.Add("id", id)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/creditor/collect?"+params.Encode(), nil)
+	collection := &Collection{}
+	if err := c.sendRequest(req, collection); err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
+
+// WaitForCollection polls the collection every interval (default 30s) until it was sent to the bank or refused and
+// returns it in that state. Use a context with a deadline to time out, in which case the context error is returned.
+func (c *Client) WaitForCollection(ctx context.Context, id string, interval time.Duration) (*Collection, error) {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		collection, err := c.CollectionDetail(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if collection.IsFinal() {
+			return collection, nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package twikey
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCollect(t *testing.T) {
+	var polls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AssertEquals(t, "/creditor/collect", r.URL.Path)
+		_ = r.ParseForm()
+		switch r.Method {
+		case http.MethodPost:
+			AssertEquals(t, "", r.Form.Get("tc"))
+			AssertEquals(t, "12", r.Form.Get("ct"))
+			AssertEquals(t, "true", r.Form.Get("prenotify"))
+			AssertEquals(t, "1704067200000", r.Form.Get("until"))
+			AssertEquals(t, "2099-01-15", r.Form.Get("colldt"))
+			AssertEquals(t, "1,2", strings.Join(r.Form["id"], ","))
+			AssertEquals(t, "R3", strings.Join(r.Form["ref"], ","))
+			AssertEquals(t, "100.00", r.Form.Get("maxAmount"))
+			AssertEquals(t, "3", r.Form.Get("maxCount"))
+			_, _ = w.Write([]byte(`{"rcurMsgId":"coll1","state":"PREPARED","count":3,"amount":75.5,"colldt":"2099-01-15"}`))
+		case http.MethodGet:
+			AssertEquals(t, "coll1", r.Form.Get("id"))
+			state := "PREPARED"
+			if atomic.AddInt32(&polls, 1) >= 3 {
+				state = "SENT"
+			}
+			_, _ = w.Write([]byte(`{"rcurMsgId":"coll1","state":"` + state + `","count":3,"amount":75.5}`))
+		}
+	}))
+	defer server.Close()
+	c := NewMockedTestClient(server)
+
+	until := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	collection, err := c.Collect(context.Background(), &CollectRequest{
+		Template:       "12",
+		Prenotify:      true,
+		Until:          &until,
+		CollectionDate: "2099-01-15",
+		TransactionIds: []int64{1, 2},
+		Refs:           []string{"R3"},
+		MaxAmount:      100,
+		MaxCount:       3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, "coll1", collection.Id)
+	AssertEquals(t, 3, collection.Count)
+	AssertEquals(t, 75.5, collection.Amount)
+	AssertEquals(t, false, collection.IsSent())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	collection, err = c.WaitForCollection(ctx, collection.Id, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, true, collection.IsSent())
+	AssertEquals(t, int32(3), atomic.LoadInt32(&polls))
+}
+
+func TestCollectValidationAndLegacy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		AssertEquals(t, "MyTemplate", r.Form.Get("tc"))
+		AssertEquals(t, "1704067200000", r.Form.Get("until"))
+		_, _ = w.Write([]byte(`{"rcurMsgId":"coll2"}`))
+	}))
+	defer server.Close()
+	c := NewMockedTestClient(server)
+
+	_, err := c.Collect(context.Background(), &CollectRequest{CollectionDate: "2000-01-01", MaxCount: -1})
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Expected a validation error got %v", err)
+	}
+	AssertEquals(t, true, verr.HasField("Template"))
+	AssertEquals(t, true, verr.HasField("CollectionDate"))
+	AssertEquals(t, true, verr.HasField("MaxCount"))
+
+	id, err := c.TransactionCollect(context.Background(), "MyTemplate", false, WithUntil(1704067200000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, "coll2", id)
+}
+
+func TestCollectUsesTimeProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/creditor/collect" {
+			return // the client logs in again as its time lies far beyond the last login
+		}
+		_ = r.ParseForm()
+		AssertEquals(t, "2030-01-15", r.Form.Get("colldt"))
+		_, _ = w.Write([]byte(`{"rcurMsgId":"coll3"}`))
+	}))
+	defer server.Close()
+	c := NewMockedTestClient(server)
+	WithTimeProvider(&TestTimeProvider{currentTime: time.Date(2030, 1, 15, 12, 0, 0, 0, time.UTC)})(c)
+
+	// the day before the time of the client is in the past, the day itself is not
+	_, err := c.Collect(context.Background(), &CollectRequest{Template: "MyTemplate", CollectionDate: "2030-01-14"})
+	if verr, ok := err.(*ValidationError); !ok || !verr.HasField("CollectionDate") {
+		t.Fatalf("Expected the collection date to be refused, got %v", err)
+	}
+	collection, err := c.Collect(context.Background(), &CollectRequest{Template: "MyTemplate", CollectionDate: "2030-01-15"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, "coll3", collection.Id)
+}
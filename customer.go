@@ -2,22 +2,245 @@ package twikey
 
 import (
 	"context"
-	"errors"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 )
 
+// requireCustomerNumber returns a ValidationError when no customer number is passed, as it is part of the endpoint
+// this is checked even when validation is disabled
+func requireCustomerNumber(customerNumber string) error {
+	v := validation{}
+	v.required("CustomerNumber", customerNumber)
+	return v.err()
+}
+
+func customerEndpoint(c *Client, customerNumber string) string {
+	return c.BaseURL + "/creditor/customer/" + url.PathEscape(customerNumber)
+}
+
+// CustomerCreate creates a new customer, the returned customer contains the customer number assigned by Twikey
+// when none was passed
+func (c *Client) CustomerCreate(ctx context.Context, request *Customer) (*Customer, error) {
+	if err := c.validate(request); err != nil {
+		return nil, err
+	}
+
+	payload := request.asUrlParams()
+	if request.CustomerNumber != "" {
+		payload = "customerNumber=" + url.QueryEscape(request.CustomerNumber) + "&" + payload
+	}
+	c.Debug.Debugf("New customer %s", payload)
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/creditor/customer", strings.NewReader(payload))
+	var customer Customer
+	if err := c.sendRequest(req, &customer); err != nil {
+		return nil, err
+	}
+	return &customer, nil
+}
+
+// CustomerDetail retrieves a single customer by its customer number
+func (c *Client) CustomerDetail(ctx context.Context, customerNumber string) (*Customer, error) {
+	if err := requireCustomerNumber(customerNumber); err != nil {
+		return nil, err
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, customerEndpoint(c, customerNumber), nil)
+	var customer Customer
+	if err := c.sendRequest(req, &customer); err != nil {
+		return nil, err
+	}
+	return &customer, nil
+}
+
+// CustomerUpdate updates the customer identified by its CustomerNumber, only the passed fields are changed
 func (c *Client) CustomerUpdate(ctx context.Context, request *Customer) error {
 
-	if request.CustomerNumber == "" {
-		return errors.New("A customerNumber is required")
+	if err := requireCustomerNumber(request.CustomerNumber); err != nil {
+		return err
+	}
+
+	if err := c.validate(request); err != nil {
+		return err
 	}
 
 	params := request.asUrlParams()
 	c.Debug.Debugf("Update customer %s", params)
 
-	req, _ := http.NewRequestWithContext(ctx, "PATCH", c.BaseURL+"/creditor/customer/"+request.CustomerNumber+"?"+params, nil)
+	req, _ := http.NewRequestWithContext(ctx, "PATCH", customerEndpoint(c, request.CustomerNumber)+"?"+params, nil)
 	if err := c.sendRequest(req, nil); err != nil {
 		return err
 	}
 	return nil
 }
+
+type CustomerQueryRequest struct {
+	// Email of the customer
+	Email string
+	// Name matches the company name, first name or last name of the customer
+	Name string
+	// Coc is the vat or enterprise number of the customer
+	Coc string
+	// CustomerNumber specifies the reference of a customer.
+	CustomerNumber string
+	// Page of the results (if more than 1 is available)
+	Page int
+}
+
+func (r *CustomerQueryRequest) asUrlParams() string {
+	params := url.Values{}
+	addIfExists(params, "email", r.Email)
+	addIfExists(params, "name", r.Name)
+	addIfExists(params, "coc", r.Coc)
+	addIfExists(params, "customerNumber", r.CustomerNumber)
+	if r.Page > 0 {
+		params.Add("page", strconv.Itoa(r.Page))
+	}
+	return params.Encode()
+}
+
+// NextPage will increment the current page number of the customer query.
+func (r *CustomerQueryRequest) NextPage() *CustomerQueryRequest {
+	r.Page++
+	return r
+}
+
+type CustomerQueryResponse struct {
+	Customers []Customer `json:"Customers"`
+	Links     struct {
+		Previous string `json:"previous"`
+		Self     string `json:"self"`
+		Next     string `json:"next"`
+	} `json:"_links"`
+}
+
+// HasNext will return true if another page of results is available.
+func (r *CustomerQueryResponse) HasNext() bool {
+	return r.Links.Next != ""
+}
+
+// CustomerQuery retrieves a page of customers matching the query
+func (c *Client) CustomerQuery(ctx context.Context, request *CustomerQueryRequest) (*CustomerQueryResponse, error) {
+	endpoint := c.BaseURL + "/creditor/customer/query"
+	if params := request.asUrlParams(); params != "" {
+		endpoint += "?" + params
+	}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	var output CustomerQueryResponse
+	if err := c.sendRequest(req, &output); err != nil {
+		return nil, err
+	}
+	return &output, nil
+}
+
+// CustomerMandates retrieves all mandates signed by the customer
+func (c *Client) CustomerMandates(ctx context.Context, customerNumber string) ([]MndtDetail, error) {
+	if err := requireCustomerNumber(customerNumber); err != nil {
+		return nil, err
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, customerEndpoint(c, customerNumber)+"/mandates", nil)
+	var output struct {
+		Mandates []MndtDetail `json:"Mandates"`
+	}
+	if err := c.sendRequest(req, &output); err != nil {
+		return nil, err
+	}
+	return output.Mandates, nil
+}
+
+// CustomerPaylinks retrieves all paylinks of the customer
+func (c *Client) CustomerPaylinks(ctx context.Context, customerNumber string) ([]Paylink, error) {
+	if err := requireCustomerNumber(customerNumber); err != nil {
+		return nil, err
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, customerEndpoint(c, customerNumber)+"/paylinks", nil)
+	var output PaylinkList
+	if err := c.sendRequest(req, &output); err != nil {
+		return nil, err
+	}
+	return output.Links, nil
+}
+
+// CustomerTransactions retrieves all transactions of the customer
+func (c *Client) CustomerTransactions(ctx context.Context, customerNumber string) ([]Transaction, error) {
+	if err := requireCustomerNumber(customerNumber); err != nil {
+		return nil, err
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, customerEndpoint(c, customerNumber)+"/transactions", nil)
+	var output TransactionList
+	if err := c.sendRequest(req, &output); err != nil {
+		return nil, err
+	}
+	return output.Entries, nil
+}
+
+// CustomerOverview is an aggregated view of everything related to a customer
+type CustomerOverview struct {
+	Customer      *Customer
+	Mandates      []MndtDetail
+	Invoices      []Invoice
+	Subscriptions []Subscription
+	Paylinks      []Paylink
+	// Errors contains the error per part (mandates, invoices, subscriptions or paylinks) that could not be retrieved
+	Errors map[string]error
+}
+
+// IsComplete returns true if all parts of the overview could be retrieved
+func (o *CustomerOverview) IsComplete() bool {
+	return len(o.Errors) == 0
+}
+
+// CustomerOverview retrieves the customer with all its mandates, invoices, subscriptions and paylinks. The parts are
+// fetched concurrently, a part that fails is reported in Errors so the others can still be shown. An error is only
+// returned when the customer itself can't be retrieved.
+func (c *Client) CustomerOverview(ctx context.Context, customerNumber string) (*CustomerOverview, error) {
+	customer, err := c.CustomerDetail(ctx, customerNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	overview := &CustomerOverview{Customer: customer}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	fetch := func(part string, fn func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(); err != nil {
+				mu.Lock()
+				if overview.Errors == nil {
+					overview.Errors = make(map[string]error)
+				}
+				overview.Errors[part] = err
+				mu.Unlock()
+			}
+		}()
+	}
+
+	fetch("mandates", func() (err error) {
+		overview.Mandates, err = c.CustomerMandates(ctx, customerNumber)
+		return err
+	})
+	fetch("invoices", func() error {
+		return c.InvoiceQueryAll(ctx, &InvoiceQueryRequest{CustomerNumber: customerNumber}, func(invoice *Invoice) error {
+			overview.Invoices = append(overview.Invoices, *invoice)
+			return nil
+		})
+	})
+	fetch("subscriptions", func() (err error) {
+		overview.Subscriptions, err = c.Subscriptions(ctx, &SubscriptionListRequest{CustomerNumber: customerNumber}).All()
+		return err
+	})
+	fetch("paylinks", func() (err error) {
+		overview.Paylinks, err = c.CustomerPaylinks(ctx, customerNumber)
+		return err
+	})
+	wg.Wait()
+	return overview, nil
+}
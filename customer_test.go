@@ -0,0 +1,91 @@
+package twikey
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCustomerCrud(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "POST /creditor/customer":
+			_ = r.ParseForm()
+			AssertEquals(t, "cst1", r.Form.Get("customerNumber"))
+			AssertEquals(t, "info@acme.be", r.Form.Get("email"))
+			_, _ = w.Write([]byte(`{"customerNumber":"cst1","email":"info@acme.be","companyName":"Acme"}`))
+		case "GET /creditor/customer/cst1":
+			_, _ = w.Write([]byte(`{"customerNumber":"cst1","email":"info@acme.be","companyName":"Acme"}`))
+		case "GET /creditor/customer/query":
+			AssertEquals(t, "Acme", r.URL.Query().Get("name"))
+			AssertEquals(t, "BE0123456749", r.URL.Query().Get("coc"))
+			_, _ = w.Write([]byte(`{"Customers":[{"customerNumber":"cst1"}],"_links":{"next":"page=1"}}`))
+		default:
+			t.Errorf("Unexpected call %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+	ctx := context.Background()
+
+	customer, err := cl.CustomerCreate(ctx, &Customer{CustomerNumber: "cst1", Email: "info@acme.be", CompanyName: "Acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, "cst1", customer.CustomerNumber)
+
+	customer, err = cl.CustomerDetail(ctx, "cst1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, "Acme", customer.CompanyName)
+
+	page, err := cl.CustomerQuery(ctx, &CustomerQueryRequest{Name: "Acme", Coc: "BE0123456749"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 1, len(page.Customers))
+	AssertEquals(t, true, page.HasNext())
+
+	err = cl.CustomerUpdate(ctx, &Customer{Email: "info@acme.be"})
+	if validationError, ok := err.(*ValidationError); !ok || !validationError.HasField("CustomerNumber") {
+		t.Fatalf("Expected a validation error on CustomerNumber, got %v", err)
+	}
+}
+
+func TestCustomerOverview(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/creditor/customer/cst1":
+			_, _ = w.Write([]byte(`{"customerNumber":"cst1","lastName":"Doe"}`))
+		case "/creditor/customer/cst1/mandates":
+			_, _ = w.Write([]byte(`{"Mandates":[{"State":"signed","Mndt":{"MndtId":"M1"}}]}`))
+		case "/creditor/invoice/query":
+			AssertEquals(t, "cst1", r.URL.Query().Get("customerNumber"))
+			_, _ = w.Write([]byte(`{"Invoices":[{"id":"i1"},{"id":"i2"}],"_links":{}}`))
+		case "/creditor/subscription/query":
+			AssertEquals(t, "cst1", r.URL.Query().Get("customerNumber"))
+			_, _ = w.Write([]byte(`{"Subscriptions":[{"ref":"S1"}],"_links":{}}`))
+		case "/creditor/customer/cst1/paylinks":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			t.Errorf("Unexpected call %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+
+	overview, err := cl.CustomerOverview(context.Background(), "cst1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, "Doe", overview.Customer.LastName)
+	AssertEquals(t, "M1", overview.Mandates[0].Mndt.MndtId)
+	AssertEquals(t, 2, len(overview.Invoices))
+	AssertEquals(t, 1, len(overview.Subscriptions))
+	AssertEquals(t, false, overview.IsComplete())
+	if overview.Errors["paylinks"] == nil {
+		t.Error("Expected the paylinks to fail")
+	}
+}
@@ -1,21 +1,24 @@
 package twikey
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/twikey/twikey-api-go/iban"
 )
 
 // InviteRequest contains all possible parameters that can be send to invite a customer
 // to sign a document
 type InviteRequest struct {
+	IdempotencyKey string // Avoid double entries
 	Template       string // mandatory
 	CustomerNumber string
 	Email          string
@@ -81,11 +84,32 @@ func (request *InviteRequest) Add(key string, value string) {
 	request.Extra[key] = value
 }
 
+// Validate checks the request locally and returns a ValidationError listing all invalid fields
+func (request *InviteRequest) Validate() error {
+	v := validation{}
+	v.required("Template", request.Template)
+	v.email("Email", request.Email)
+	v.isoCode("Language", request.Language)
+	v.isoCode("Country", request.Country)
+	v.date("SignDate", request.SignDate)
+	v.decimal("Amount", request.Amount)
+	v.account("Iban", request.Iban, "Bic", request.Bic)
+	return v.err()
+}
+
 // Invite is the response containing the documentNumber, key and the url to point the customer too.
 type Invite struct {
 	MndtId string // documentNumber
 	Url    string // where the customer can sign the document
 	Key    string // specific invite key
+
+	IdempotencyKey   string `json:"-"` // key used when creating the invite
+	IdempotentReplay bool   `json:"-"` // true if Twikey returned the result of a previous call with the same key
+}
+
+func (invite *Invite) setIdempotency(key string, replayed bool) {
+	invite.IdempotencyKey = key
+	invite.IdempotentReplay = replayed
 }
 
 // UpdateRequest contains all possible parameters that can be send to update a document
@@ -144,6 +168,37 @@ func (request *UpdateRequest) Add(key string, value string) {
 	request.Extra[key] = value
 }
 
+// Validate checks the request locally and returns a ValidationError listing all invalid fields
+func (request *UpdateRequest) Validate() error {
+	v := validation{}
+	v.required("MandateNumber", request.MandateNumber)
+	v.oneOf("State", request.State, "active", "passive")
+	v.email("Email", request.Email)
+	v.isoCode("Language", request.Language)
+	v.isoCode("Country", request.Country)
+	v.account("Iban", request.Iban, "Bic", request.Bic)
+	return v.err()
+}
+
+// validateAccount checks the format and checksum of the iban and the format of the bic when the client was
+// created using WithAccountValidation
+func (c *Client) validateAccount(account string, bic string) error {
+	if !c.validateAccounts {
+		return nil
+	}
+	if account != "" {
+		if err := iban.Validate(account); err != nil {
+			return NewTwikeyError("err_invalid_iban", err.Error(), account)
+		}
+	}
+	if bic != "" {
+		if err := iban.ValidateBIC(bic); err != nil {
+			return NewTwikeyError("err_invalid_bic", err.Error(), bic)
+		}
+	}
+	return nil
+}
+
 // CtctDtls contains all contact details for a specific document
 type CtctDtls struct {
 	EmailAdr string
@@ -224,6 +279,13 @@ type MandateUpdates struct {
 // DocumentInvite allows to invite a customer to sign a specific document
 func (c *Client) DocumentInvite(ctx context.Context, request *InviteRequest) (*Invite, error) {
 
+	if err := c.validateAccount(request.Iban, request.Bic); err != nil {
+		return nil, err
+	}
+	if err := c.validate(request); err != nil {
+		return nil, err
+	}
+
 	if request.Template == "" {
 		return nil, errors.New("A template is required")
 	}
@@ -231,6 +293,7 @@ func (c *Client) DocumentInvite(ctx context.Context, request *InviteRequest) (*I
 	params := request.asUrlParams()
 	c.Debug.Debugf("New document %s", params)
 	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/creditor/invite", strings.NewReader(params))
+	c.addIdempotencyKey(req, &request.IdempotencyKey, []byte(params))
 
 	var invite Invite
 	if err := c.sendRequest(req, &invite); err != nil {
@@ -242,6 +305,13 @@ func (c *Client) DocumentInvite(ctx context.Context, request *InviteRequest) (*I
 // DocumentSign allows a customer to sign directly a specific document
 func (c *Client) DocumentSign(ctx context.Context, request *InviteRequest) (*Invite, error) {
 
+	if err := c.validateAccount(request.Iban, request.Bic); err != nil {
+		return nil, err
+	}
+	if err := c.validate(request); err != nil {
+		return nil, err
+	}
+
 	if request.Template == "" {
 		return nil, errors.New("A template is required")
 	}
@@ -249,6 +319,7 @@ func (c *Client) DocumentSign(ctx context.Context, request *InviteRequest) (*Inv
 	params := request.asUrlParams()
 	c.Debug.Debugf("New sign document %s", params)
 	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/creditor/sign", strings.NewReader(params))
+	c.addIdempotencyKey(req, &request.IdempotencyKey, []byte(params))
 
 	var invite Invite
 	if err := c.sendRequest(req, &invite); err != nil {
@@ -260,6 +331,13 @@ func (c *Client) DocumentSign(ctx context.Context, request *InviteRequest) (*Inv
 // DocumentUpdate allows to update a previously added document
 func (c *Client) DocumentUpdate(ctx context.Context, request *UpdateRequest) error {
 
+	if err := c.validateAccount(request.Iban, request.Bic); err != nil {
+		return err
+	}
+	if err := c.validate(request); err != nil {
+		return err
+	}
+
 	if request.MandateNumber == "" {
 		return NewTwikeyError("err_invalid_mandatenumber", "A mndtId is required", "")
 	}
@@ -268,7 +346,7 @@ func (c *Client) DocumentUpdate(ctx context.Context, request *UpdateRequest) err
 
 	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/creditor/mandate/update", strings.NewReader(request.asUrlParams()))
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.apiToken)
+	req.Header.Add("Authorization", c.token())
 	req.Header.Add("Accept", "application/json")
 	req.Header.Set("User-Agent", c.UserAgent)
 
@@ -294,7 +372,7 @@ func (c *Client) DocumentCancel(ctx context.Context, mandate string, reason stri
 
 	req, _ := http.NewRequestWithContext(ctx, http.MethodDelete, c.BaseURL+"/creditor/mandate?"+params.Encode(), nil)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Authorization", c.apiToken)
+	req.Header.Set("Authorization", c.token())
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("User-Agent", c.UserAgent)
 
@@ -328,67 +406,85 @@ func (c *Client) DocumentFeed(
 	cancelledDocument func(mandateNumber string, reason *CxlRsn, eventTime string, eventId int64),
 	options ...FeedOption) error {
 
-	if err := c.refreshTokenIfRequired(); err != nil {
-		return err
-	}
-
-	feedOptions := parseFeedOptions(options)
-	_url := c.BaseURL + "/creditor/mandate"
-	for i, sideload := range feedOptions.includes {
-		if i == 0 {
-			_url = _url + "?include=" + sideload
+	return c.HandleDocumentFeed(ctx, func(update *MandateUpdate) error {
+		if update.CxlRsn != nil {
+			cancelledDocument(update.OrgnlMndtId, update.CxlRsn, update.EvtTime, update.EvtId)
+		} else if update.AmdmntRsn != nil {
+			updateDocument(update.OrgnlMndtId, update.Mndt, update.AmdmntRsn, update.EvtTime, update.EvtId)
 		} else {
-			_url = _url + "&include=" + sideload
+			newDocument(update.Mndt, update.EvtTime, update.EvtId)
 		}
-	}
+		return nil
+	}, options...)
+}
 
+// HandleDocumentFeed retrieves all documents since the last call. Depending on the event either AmdmntRsn (update)
+// or CxlRsn (cancel) is set, a new document has neither. The first error returned by the handler stops the feed
+// and is returned as a FeedError containing the EvtId of the last handled event.
+func (c *Client) HandleDocumentFeed(ctx context.Context, handler func(update *MandateUpdate) error, options ...FeedOption) error {
+	feed := c.newFeedReader(ctx, "/creditor/mandate", options)
 	for {
-		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, _url, nil)
-		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-		req.Header.Set("Authorization", c.apiToken)
-		req.Header.Set("Accept", "application/json")
-		req.Header.Set("User-Agent", c.UserAgent)
-
-		if feedOptions.start != -1 {
-			req.Header.Set("X-RESUME-AFTER", fmt.Sprintf("%d", feedOptions.start))
-			feedOptions.start = -1
-		}
-
-		res, err := c.HTTPClient.Do(req)
-		if err != nil {
+		var updates MandateUpdates
+		if err := feed.next(&updates); err != nil {
 			return err
 		}
-		if res.StatusCode == 200 {
-			payload, _ := io.ReadAll(res.Body)
-			var updates MandateUpdates
-			err := json.Unmarshal(payload, &updates)
-			if err != nil {
+		c.Debug.Debugf("Fetched %d documents\n", len(updates.Messages))
+		for i := range updates.Messages {
+			update := &updates.Messages[i]
+			if err := feed.handled(update.EvtId, handler(update)); err != nil {
 				return err
 			}
+		}
+		if len(updates.Messages) == 0 {
+			return nil
+		}
+	}
+}
 
-			_ = res.Body.Close()
-			c.Debug.Debugf("Fetched %d documents\n", len(updates.Messages))
-			for _, update := range updates.Messages {
-				if update.CxlRsn != nil {
-					cancelledDocument(update.OrgnlMndtId, update.CxlRsn, update.EvtTime, update.EvtId)
-				} else if update.AmdmntRsn != nil {
-					updateDocument(update.OrgnlMndtId, update.Mndt, update.AmdmntRsn, update.EvtTime, update.EvtId)
-				} else {
-					newDocument(update.Mndt, update.EvtTime, update.EvtId)
-				}
-			}
-
-			if len(updates.Messages) == 0 {
+// DocumentStream delivers the document feed over a channel, allowing it to be consumed by eg. a pool of workers.
+// Depending on the event either AmdmntRsn (update) or CxlRsn (cancel) is set, a new document has neither.
+// The channel is closed when the feed is empty, when an error occurred (see FeedStream.Errors) or when the context is cancelled.
+func (c *Client) DocumentStream(ctx context.Context, options ...FeedOption) (<-chan *MandateUpdate, *FeedStream) {
+	events := make(chan *MandateUpdate)
+	stream := newFeedStream(options)
+	go func() {
+		defer close(events)
+		stream.finish(ctx, c.HandleDocumentFeed(ctx, func(update *MandateUpdate) error {
+			stream.delivering(update.EvtId)
+			select {
+			case events <- update:
 				return nil
+			case <-ctx.Done():
+				stream.undelivered()
+				return ctx.Err()
 			}
-		} else {
-			return NewTwikeyErrorFromResponse(res)
-		}
-	}
+		}, options...))
+	}()
+	return events, stream
 }
 
 // DownloadPdf allows the download of a specific (signed) pdf
 func (c *Client) DownloadPdf(ctx context.Context, mndtId string, downloadFile string) error {
+	absPath, _ := filepath.Abs(downloadFile)
+	var payload bytes.Buffer
+	if err := c.WritePdf(ctx, mndtId, &payload); err != nil {
+		c.Debug.Debugf("Unable to download file %s", absPath)
+		return err
+	}
+
+	f, _ := os.Create(downloadFile)
+	defer f.Close()
+	_, err := f.Write(payload.Bytes())
+	if err != nil {
+		c.Debug.Debugf("Unable to download file %s : %v", absPath, err)
+	} else {
+		c.Debug.Debugf("Saving to file %s", absPath)
+	}
+	return err
+}
+
+// WritePdf writes the pdf of a specific (signed) document to w
+func (c *Client) WritePdf(ctx context.Context, mndtId string, w io.Writer) error {
 	params := url.Values{}
 	params.Add("mndtId", mndtId)
 
@@ -396,27 +492,17 @@ func (c *Client) DownloadPdf(ctx context.Context, mndtId string, downloadFile st
 	req.Header.Add("Accept-Language", "en")
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("User-Agent", c.UserAgent)
-	req.Header.Add("Authorization", c.apiToken)
+	req.Header.Add("Authorization", c.token())
 
-	absPath, _ := filepath.Abs(downloadFile)
-	res, err := c.HTTPClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return err
 	}
+	defer res.Body.Close()
 	if res.StatusCode == 200 {
-		payload, _ := io.ReadAll(res.Body)
-
-		f, _ := os.Create(downloadFile)
-		defer f.Close()
-		_, err := f.Write(payload)
-		if err != nil {
-			c.Debug.Debugf("Unable to download file %s : %v", absPath, err)
-		} else {
-			c.Debug.Debugf("Saving to file %s", absPath)
-		}
+		_, err = io.Copy(w, res.Body)
 		return err
 	}
-	c.Debug.Debugf("Unable to download file %s", absPath)
 	return NewTwikeyErrorFromResponse(res)
 }
 
@@ -438,9 +524,9 @@ func (c *Client) DocumentDetail(ctx context.Context, mndtId string, force bool)
 	req.Header.Add("Accept-Language", "en")
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("User-Agent", c.UserAgent)
-	req.Header.Add("Authorization", c.apiToken)
+	req.Header.Add("Authorization", c.token())
 
-	res, err := c.HTTPClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -2,6 +2,8 @@ package twikey
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 )
@@ -121,3 +123,106 @@ func TestDocumentDetail(t *testing.T) {
 		t.Log("Got Mandate", mndt.Mndt.MndtId, "with state", mndt.State)
 	}
 }
+
+func TestDocumentInviteWithAccountValidation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AssertEquals(t, "/creditor/invite", r.URL.Path)
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		AssertEquals(t, "BE09363107700857", r.Form.Get("iban"))
+		_, _ = w.Write([]byte(`{"mndtId":"MNDT1","url":"https://twikey.com/s/1","key":"1"}`))
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+	WithAccountValidation()(cl)
+
+	_, err := cl.DocumentInvite(context.Background(), &InviteRequest{
+		Template: "1",
+		Iban:     "BE10363107700857",
+	})
+	if err == nil {
+		t.Fatal("Expected an invalid iban to be refused")
+	}
+	AssertEquals(t, "err_invalid_iban", err.(*TwikeyError).Code)
+
+	_, err = cl.DocumentInvite(context.Background(), &InviteRequest{
+		Template: "1",
+		Iban:     "BE09363107700857",
+		Bic:      "GEBEBEB",
+	})
+	if err == nil {
+		t.Fatal("Expected an invalid bic to be refused")
+	}
+	AssertEquals(t, "err_invalid_bic", err.(*TwikeyError).Code)
+
+	invite, err := cl.DocumentInvite(context.Background(), &InviteRequest{
+		Template: "1",
+		Iban:     "BE09363107700857",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, "MNDT1", invite.MndtId)
+}
+
+func TestAccountValidationWithoutValidation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"mndtId":"MNDT1","url":"https://twikey.com/s/1","key":"1"}`))
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+	WithoutValidation()(cl)
+	WithAccountValidation()(cl)
+
+	// only the account is checked, the invalid email is left to Twikey
+	_, err := cl.DocumentInvite(context.Background(), &InviteRequest{Template: "1", Email: "john", Iban: "BE10363107700857"})
+	if err == nil {
+		t.Fatal("Expected an invalid iban to be refused")
+	}
+	AssertEquals(t, "err_invalid_iban", err.(*TwikeyError).Code)
+	if _, err = cl.DocumentInvite(context.Background(), &InviteRequest{Template: "1", Email: "john", Iban: "BE09363107700857"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDocumentInviteValidation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AssertEquals(t, "/creditor/invite", r.URL.Path)
+		_, _ = w.Write([]byte(`{"mndtId":"MNDT1","url":"https://twikey.com/s/1","key":"1"}`))
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+
+	_, err := cl.DocumentInvite(context.Background(), &InviteRequest{
+		Template: "1",
+		Email:    "john",
+		Iban:     "BE10363107700857",
+		Bic:      "GEBEBEB",
+	})
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+	AssertEquals(t, 3, len(verr.Fields))
+	AssertEquals(t, true, verr.HasField("Email"))
+	AssertEquals(t, true, verr.HasField("Iban"))
+	AssertEquals(t, true, verr.HasField("Bic"))
+
+	invite, err := cl.DocumentInvite(context.Background(), &InviteRequest{
+		Template: "1",
+		Iban:     "BE09363107700857",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, "MNDT1", invite.MndtId)
+
+	WithoutValidation()(cl)
+	if _, err = cl.DocumentInvite(context.Background(), &InviteRequest{
+		Template: "1",
+		Iban:     "BE10363107700857",
+	}); err != nil {
+		t.Fatalf("Expected no validation when disabled, got %v", err)
+	}
+}
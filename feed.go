@@ -0,0 +1,156 @@
+package twikey
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// FeedStream controls a feed that is delivered over a channel (eg. TransactionStream). Events are sent over
+// an unbuffered channel, so the feed is only read as fast as the consumer takes the events. An event is only
+// considered handled once the consumer acknowledges it using Ack.
+type FeedStream struct {
+	errors   chan error
+	mutex    sync.Mutex
+	position int64
+	pending  []int64 // positions of the delivered events that weren't acknowledged yet, in the order of the feed
+	acked    map[int64]bool
+}
+
+func newFeedStream(options []FeedOption) *FeedStream {
+	return &FeedStream{
+		errors:   make(chan error, 1),
+		position: parseFeedOptions(options).start,
+		acked:    map[int64]bool{},
+	}
+}
+
+// Errors returns the channel on which the error that stopped the feed is sent, it is closed right
+// before the events channel. Cancelling the context is not considered an error.
+func (s *FeedStream) Errors() <-chan error {
+	return s.errors
+}
+
+// Position returns the position (Seq or EvtId) up to which all events were acknowledged or the start
+// position when nothing was acknowledged yet (-1 when none was passed). Passing it as FeedStartPosition
+// allows the feed to continue right after that event, even if the stream was stopped halfway a page.
+func (s *FeedStream) Position() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.position
+}
+
+// Ack marks the event at the given position (its Seq, or EvtId for documents) as handled. The Position only
+// advances once all events before it are acknowledged as well, so events that are still being handled by
+// another worker are never skipped when resuming.
+func (s *FeedStream) Ack(position int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, pending := range s.pending {
+		if pending == position {
+			s.acked[position] = true
+			break
+		}
+	}
+	for len(s.pending) > 0 && s.acked[s.pending[0]] {
+		delete(s.acked, s.pending[0])
+		s.position = s.pending[0]
+		s.pending = s.pending[1:]
+	}
+}
+
+// delivering registers the event at the given position before it is handed to the consumer
+func (s *FeedStream) delivering(position int64) {
+	s.mutex.Lock()
+	s.pending = append(s.pending, position)
+	s.mutex.Unlock()
+}
+
+// undelivered removes the last registered event when it couldn't be handed to the consumer
+func (s *FeedStream) undelivered() {
+	s.mutex.Lock()
+	if len(s.pending) > 0 {
+		s.pending = s.pending[:len(s.pending)-1]
+	}
+	s.mutex.Unlock()
+}
+
+// finish reports the error (if any) and closes the error channel
+func (s *FeedStream) finish(ctx context.Context, err error) {
+	if err != nil && ctx.Err() == nil {
+		s.errors <- err
+	}
+	close(s.errors)
+}
+
+// FeedError is returned when a handler of a feed failed. As Twikey already advanced the feed, the Position
+// (Seq or EvtId) of the last successfully handled event allows to restart from exactly there using
+// FeedStartPosition. When no event was handled yet the Position is the start position that was passed
+// or, without one, the position right before the failed event so it is retried.
+type FeedError struct {
+	Position int64
+	Err      error
+}
+
+func (err *FeedError) Error() string {
+	return fmt.Sprintf("feed stopped after position %d: %v", err.Position, err.Err)
+}
+
+func (err *FeedError) Unwrap() error {
+	return err.Err
+}
+
+// feedReader fetches the pages of a feed and keeps track of the position of the handled events
+type feedReader struct {
+	client   *Client
+	ctx      context.Context
+	url      string
+	start    int64
+	position int64
+}
+
+// newFeedReader creates a reader for the feed at path, the "seq" of the events is always included as
+// the position of the handled events can't be tracked without it
+func (c *Client) newFeedReader(ctx context.Context, path string, options []FeedOption) *feedReader {
+	feedOptions := parseFeedOptions(options)
+	_url := c.BaseURL + path + "?include=seq"
+	for _, sideload := range feedOptions.includes {
+		if sideload != "seq" {
+			_url = _url + "&include=" + sideload
+		}
+	}
+	return &feedReader{
+		client:   c,
+		ctx:      ctx,
+		url:      _url,
+		start:    feedOptions.start,
+		position: feedOptions.start,
+	}
+}
+
+// next fetches the next page of the feed into v
+func (f *feedReader) next(v interface{}) error {
+	req, _ := http.NewRequestWithContext(f.ctx, http.MethodGet, f.url, nil)
+	if f.start != -1 {
+		req.Header.Set("X-RESUME-AFTER", fmt.Sprintf("%d", f.start))
+		f.start = -1
+	}
+	return f.client.sendRequest(req, v)
+}
+
+// handled registers the outcome of the handler for the event at the given position
+func (f *feedReader) handled(position int64, err error) error {
+	if err != nil {
+		resume := f.position
+		if resume == -1 && position > 0 {
+			// nothing was handled yet, resuming right before the failed event retries it
+			resume = position - 1
+		}
+		return &FeedError{Position: resume, Err: err}
+	}
+	if position > 0 {
+		f.position = position
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package twikey
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newFeedServer returns a server that serves the given pages in order followed by an empty one
+func newFeedServer(t *testing.T, path string, pages ...string) *httptest.Server {
+	page := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AssertEquals(t, path, r.URL.Path)
+		if page < len(pages) {
+			_, _ = w.Write([]byte(pages[page]))
+			page++
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+}
+
+func TestTransactionStream(t *testing.T) {
+	server := newFeedServer(t, "/creditor/transaction",
+		`{"Entries":[{"id":1,"seq":11,"state":"PAID"},{"id":2,"seq":12,"state":"ERROR"}]}`,
+		`{"Entries":[{"id":3,"seq":13,"state":"PAID"}]}`,
+	)
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+
+	events, stream := cl.TransactionStream(context.Background())
+	var ids []int64
+	for transaction := range events {
+		ids = append(ids, transaction.Id)
+		stream.Ack(transaction.Seq)
+	}
+	if err := <-stream.Errors(); err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 3, len(ids))
+	AssertEquals(t, int64(1), ids[0])
+	AssertEquals(t, int64(3), ids[2])
+	AssertEquals(t, int64(13), stream.Position())
+}
+
+func TestTransactionStreamCancel(t *testing.T) {
+	server := newFeedServer(t, "/creditor/transaction",
+		`{"Entries":[{"id":1,"seq":11},{"id":2,"seq":12},{"id":3,"seq":13}]}`,
+	)
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, stream := cl.TransactionStream(ctx, FeedStartPosition(10))
+	AssertEquals(t, int64(10), stream.Position())
+
+	first := <-events
+	AssertEquals(t, int64(1), first.Id)
+	stream.Ack(first.Seq)
+	cancel()
+	for range events {
+		// drain whatever was already handed over without handling it
+	}
+	if err := <-stream.Errors(); err != nil {
+		t.Fatalf("Cancelling should not be an error, got %v", err)
+	}
+	AssertEquals(t, int64(11), stream.Position())
+}
+
+func TestTransactionStreamAck(t *testing.T) {
+	server := newFeedServer(t, "/creditor/transaction",
+		`{"Entries":[{"id":1,"seq":11},{"id":2,"seq":12},{"id":3,"seq":13}]}`,
+	)
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+
+	events, stream := cl.TransactionStream(context.Background(), FeedStartPosition(10))
+	var transactions []*Transaction
+	for transaction := range events {
+		transactions = append(transactions, transaction)
+	}
+	AssertEquals(t, 3, len(transactions))
+	AssertEquals(t, int64(10), stream.Position())
+
+	// the third event is handled before the second one, which is still in flight
+	stream.Ack(13)
+	AssertEquals(t, int64(10), stream.Position())
+	stream.Ack(11)
+	AssertEquals(t, int64(11), stream.Position())
+	stream.Ack(12)
+	AssertEquals(t, int64(13), stream.Position())
+}
+
+func TestTransactionStreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+
+	events, stream := cl.TransactionStream(context.Background())
+	for range events {
+		t.Error("Expected no events")
+	}
+	if err := <-stream.Errors(); err == nil {
+		t.Error("Expected the error to be reported")
+	}
+}
+
+func TestDocumentStream(t *testing.T) {
+	server := newFeedServer(t, "/creditor/mandate",
+		`{"Messages":[
+			{"Mndt":{"MndtId":"MNDT1"},"EvtId":1},
+			{"Mndt":{"MndtId":"MNDT2"},"AmdmntRsn":{"Rsn":"_T50"},"OrgnlMndtId":"MNDT2","EvtId":2},
+			{"CxlRsn":{"Rsn":"MD01"},"OrgnlMndtId":"MNDT3","EvtId":3}
+		]}`,
+	)
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+
+	events, stream := cl.DocumentStream(context.Background())
+	var updates []*MandateUpdate
+	for update := range events {
+		updates = append(updates, update)
+		stream.Ack(update.EvtId)
+	}
+	if err := <-stream.Errors(); err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 3, len(updates))
+	AssertEquals(t, "MNDT1", updates[0].Mndt.MndtId)
+	AssertEquals(t, "_T50", updates[1].AmdmntRsn.Rsn)
+	AssertEquals(t, "MNDT3", updates[2].OrgnlMndtId)
+	AssertEquals(t, int64(3), stream.Position())
+}
+
+func TestHandleTransactionFeedError(t *testing.T) {
+	resumedAfter := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if resumedAfter == "" {
+			resumedAfter = r.Header.Get("X-RESUME-AFTER")
+		}
+		_, _ = w.Write([]byte(`{"Entries":[{"id":1,"seq":11},{"id":2,"seq":12},{"id":3,"seq":13}]}`))
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+
+	failure := errors.New("database unavailable")
+	handled := 0
+	err := cl.HandleTransactionFeed(context.Background(), func(transaction *Transaction) error {
+		if transaction.Seq == 12 {
+			return failure
+		}
+		handled++
+		return nil
+	}, FeedStartPosition(10))
+
+	var feedError *FeedError
+	if !errors.As(err, &feedError) {
+		t.Fatalf("Expected a FeedError, got %v", err)
+	}
+	AssertEquals(t, int64(11), feedError.Position)
+	AssertEquals(t, true, errors.Is(err, failure))
+	AssertEquals(t, 1, handled)
+	AssertEquals(t, "10", resumedAfter)
+}
+
+func TestHandleTransactionFeedFirstEventError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AssertEquals(t, "seq", r.URL.Query().Get("include"))
+		_, _ = w.Write([]byte(`{"Entries":[{"id":1,"seq":11},{"id":2,"seq":12}]}`))
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+
+	err := cl.HandleTransactionFeed(context.Background(), func(transaction *Transaction) error {
+		return errors.New("database unavailable")
+	})
+
+	// without a start position the failed event is retried when resuming
+	var feedError *FeedError
+	if !errors.As(err, &feedError) {
+		t.Fatalf("Expected a FeedError, got %v", err)
+	}
+	AssertEquals(t, int64(10), feedError.Position)
+}
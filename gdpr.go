@@ -0,0 +1,265 @@
+package twikey
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// CustomerData contains everything Twikey holds about a customer, see CustomerDataExport
+type CustomerData struct {
+	ExportedAt    time.Time      `json:"exportedAt"`
+	Customer      *Customer      `json:"customer"`
+	Mandates      []MndtDetail   `json:"mandates"`
+	Invoices      []Invoice      `json:"invoices"`
+	Transactions  []Transaction  `json:"transactions"`
+	Subscriptions []Subscription `json:"subscriptions"`
+	Paylinks      []Paylink      `json:"paylinks"`
+
+	// Pdfs contains the signed pdf per mandate, these are only part of the zip bundle
+	Pdfs map[string][]byte `json:"-"`
+}
+
+// CustomerDataExport collects everything Twikey holds about the customer for a data-subject access request. Mandates
+// are retrieved via DocumentDetail including their pdf. Unlike CustomerOverview any failure fails the export, as an
+// incomplete export can't be handed over.
+func (c *Client) CustomerDataExport(ctx context.Context, customerNumber string) (*CustomerData, error) {
+	overview, err := c.CustomerOverview(ctx, customerNumber)
+	if err != nil {
+		return nil, err
+	}
+	if !overview.IsComplete() {
+		for part, err := range overview.Errors {
+			return nil, fmt.Errorf("unable to export %s of %s: %w", part, customerNumber, err)
+		}
+	}
+	transactions, err := c.CustomerTransactions(ctx, customerNumber)
+	if err != nil {
+		return nil, fmt.Errorf("unable to export transactions of %s: %w", customerNumber, err)
+	}
+
+	data := &CustomerData{
+		ExportedAt:    c.TimeProvider.Now().UTC(),
+		Customer:      overview.Customer,
+		Invoices:      overview.Invoices,
+		Transactions:  transactions,
+		Subscriptions: overview.Subscriptions,
+		Paylinks:      overview.Paylinks,
+		Pdfs:          make(map[string][]byte),
+	}
+	for _, mandate := range overview.Mandates {
+		mndtId := mandate.Mndt.MndtId
+		detail, err := c.DocumentDetail(ctx, mndtId, true)
+		if err != nil {
+			return nil, fmt.Errorf("unable to export mandate %s: %w", mndtId, err)
+		}
+		data.Mandates = append(data.Mandates, *detail)
+
+		// unsigned mandates have no pdf
+		var pdf bytes.Buffer
+		if err := c.WritePdf(ctx, mndtId, &pdf); err != nil {
+			if twikeyError, ok := err.(*TwikeyError); ok && twikeyError.Status == 404 {
+				continue
+			}
+			return nil, fmt.Errorf("unable to export pdf of mandate %s: %w", mndtId, err)
+		}
+		data.Pdfs[mndtId] = pdf.Bytes()
+	}
+	return data, nil
+}
+
+// WriteJSON writes the export (without pdfs) as json
+func (d *CustomerData) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(d)
+}
+
+// WriteZip writes the export as a zip bundle containing customer.json and the pdf of every mandate
+// as mandates/<mndtId>.pdf
+func (d *CustomerData) WriteZip(w io.Writer) error {
+	archive := zip.NewWriter(w)
+	header := &zip.FileHeader{Name: "customer.json", Method: zip.Deflate, Modified: d.ExportedAt}
+	out, err := archive.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	if err = d.WriteJSON(out); err != nil {
+		return err
+	}
+	for _, mandate := range d.Mandates {
+		pdf, exists := d.Pdfs[mandate.Mndt.MndtId]
+		if !exists {
+			continue
+		}
+		header = &zip.FileHeader{Name: "mandates/" + mandate.Mndt.MndtId + ".pdf", Method: zip.Deflate, Modified: d.ExportedAt}
+		if out, err = archive.CreateHeader(header); err != nil {
+			return err
+		}
+		if _, err = out.Write(pdf); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// Anonymized is the value used to overwrite personal fields during an erasure
+const Anonymized = "ANONYMIZED"
+
+type ErasureRequest struct {
+	// CustomerNumber of the data subject
+	CustomerNumber string
+	// Reason used when cancelling the mandates
+	Reason string
+	// DryRun only records what would be done in the audit log
+	DryRun bool
+	// AuditLog receives every action as a json line, the returned ErasureReport contains the same entries
+	AuditLog io.Writer
+}
+
+// AuditEntry records a single action of an erasure
+type AuditEntry struct {
+	Time           time.Time `json:"time"`
+	CustomerNumber string    `json:"customerNumber"`
+	Action         string    `json:"action"` // cancel_subscription, anonymize_mandate, cancel_mandate or anonymize_customer
+	Target         string    `json:"target"` // the subscription (mndtId/ref), mandate or customer
+	DryRun         bool      `json:"dryRun,omitempty"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// ErasureReport contains all actions taken during the erasure
+type ErasureReport struct {
+	CustomerNumber string
+	Entries        []AuditEntry
+}
+
+// Failed returns the entries of actions that failed
+func (r *ErasureReport) Failed() []AuditEntry {
+	var failed []AuditEntry
+	for _, entry := range r.Entries {
+		if entry.Error != "" {
+			failed = append(failed, entry)
+		}
+	}
+	return failed
+}
+
+// erasure keeps the state of a running CustomerErase
+type erasure struct {
+	request *ErasureRequest
+	report  *ErasureReport
+	clock   TimeProvider
+}
+
+func (e *erasure) record(action string, target string, fn func() error) error {
+	entry := AuditEntry{
+		Time:           e.clock.Now().UTC(),
+		CustomerNumber: e.request.CustomerNumber,
+		Action:         action,
+		Target:         target,
+		DryRun:         e.request.DryRun,
+	}
+	var err error
+	if !e.request.DryRun {
+		if err = fn(); err != nil {
+			entry.Error = err.Error()
+		}
+	}
+	e.report.Entries = append(e.report.Entries, entry)
+	if e.request.AuditLog != nil {
+		line, _ := json.Marshal(entry)
+		if _, writeErr := e.request.AuditLog.Write(append(line, '\n')); writeErr != nil && err == nil {
+			err = writeErr
+		}
+	}
+	return err
+}
+
+// anonymizedEmail returns an undeliverable email address that is stable for the customer
+func anonymizedEmail(customerNumber string) string {
+	hash := sha256.Sum256([]byte(customerNumber))
+	return "anonymized-" + hex.EncodeToString(hash[:8]) + "@example.invalid"
+}
+
+// CustomerErase erases the personal data of a customer. It cancels the active subscriptions, overwrites the personal
+// fields of the mandates, cancels the mandates that aren't cancelled yet and finally overwrites the personal fields
+// of the customer. Invoices and transactions are kept as they are needed for the accounting. A failing action doesn't
+// stop the erasure, all failures are part of the report and the first one is returned.
+//
+// The iban, bic and mobile number of the mandates and the mobile number of the customer are NOT erased: an update
+// can't clear a field (empty values are not sent) and replacing the account would amend the signed mandate. These
+// have to be erased by Twikey separately, the report only covers the fields listed above.
+func (c *Client) CustomerErase(ctx context.Context, request *ErasureRequest) (*ErasureReport, error) {
+	if err := requireCustomerNumber(request.CustomerNumber); err != nil {
+		return nil, err
+	}
+	customerNumber := request.CustomerNumber
+	subscriptions, err := c.Subscriptions(ctx, &SubscriptionListRequest{CustomerNumber: customerNumber}).All()
+	if err != nil {
+		return nil, err
+	}
+	mandates, err := c.CustomerMandates(ctx, customerNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &erasure{request: request, report: &ErasureReport{CustomerNumber: customerNumber}, clock: c.TimeProvider}
+	var firstErr error
+	keep := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	for _, subscription := range subscriptions {
+		if subscription.State != SubscriptionStateActive && subscription.State != SubscriptionStateSuspended {
+			continue
+		}
+		subscription := subscription
+		keep(e.record("cancel_subscription", subscription.MndtId+"/"+subscription.Ref, func() error {
+			return c.SubscriptionCancel(ctx, subscription.MndtId, subscription.Ref)
+		}))
+	}
+
+	email := anonymizedEmail(customerNumber)
+	for _, mandate := range mandates {
+		mndtId := mandate.Mndt.MndtId
+		keep(e.record("anonymize_mandate", mndtId, func() error {
+			return c.DocumentUpdate(ctx, &UpdateRequest{
+				MandateNumber: mndtId,
+				Email:         email,
+				Firstname:     Anonymized,
+				Lastname:      Anonymized,
+				Address:       Anonymized,
+				City:          Anonymized,
+				Zip:           Anonymized,
+			})
+		}))
+		if strings.EqualFold(mandate.State, "cancelled") {
+			continue
+		}
+		keep(e.record("cancel_mandate", mndtId, func() error {
+			return c.DocumentCancel(ctx, mndtId, request.Reason)
+		}))
+	}
+
+	keep(e.record("anonymize_customer", customerNumber, func() error {
+		return c.CustomerUpdate(ctx, &Customer{
+			CustomerNumber: customerNumber,
+			Email:          email,
+			FirstName:      Anonymized,
+			LastName:       Anonymized,
+			Address:        Anonymized,
+			City:           Anonymized,
+			Zip:            Anonymized,
+		})
+	}))
+	return e.report, firstErr
+}
@@ -0,0 +1,132 @@
+package twikey
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newCustomerDataServer(t *testing.T, calls *[]string) *httptest.Server {
+	var mu sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /creditor/customer/cst1":
+			_, _ = w.Write([]byte(`{"customerNumber":"cst1","email":"jane@doe.be","lastName":"Doe"}`))
+		case "GET /creditor/customer/cst1/mandates":
+			_, _ = w.Write([]byte(`{"Mandates":[{"State":"signed","Mndt":{"MndtId":"M1"}},{"State":"cancelled","Mndt":{"MndtId":"M2"}}]}`))
+		case "GET /creditor/customer/cst1/paylinks":
+			_, _ = w.Write([]byte(`{"Links":[{"id":1,"amount":5}]}`))
+		case "GET /creditor/customer/cst1/transactions":
+			_, _ = w.Write([]byte(`{"Entries":[{"id":2,"mndtId":"M1","amount":10}]}`))
+		case "GET /creditor/invoice/query":
+			_, _ = w.Write([]byte(`{"Invoices":[{"id":"i1"}],"_links":{}}`))
+		case "GET /creditor/subscription/query":
+			_, _ = w.Write([]byte(`{"Subscriptions":[{"mndtId":"M1","ref":"S1","state":"active"},{"mndtId":"M1","ref":"S2","state":"closed"}],"_links":{}}`))
+		case "GET /creditor/mandate/detail":
+			w.Header().Set("X-STATE", "signed")
+			_, _ = w.Write([]byte(`{"Mndt":{"MndtId":"` + r.URL.Query().Get("mndtId") + `"}}`))
+		case "GET /creditor/mandate/pdf":
+			if r.URL.Query().Get("mndtId") == "M2" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte("%PDF-1.4"))
+		default:
+			mu.Lock()
+			*calls = append(*calls, r.Method+" "+r.URL.Path)
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+}
+
+func TestCustomerDataExport(t *testing.T) {
+	var calls []string
+	server := newCustomerDataServer(t, &calls)
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	cl.TimeProvider = &TestTimeProvider{currentTime: now}
+	cl.lastLogin = now
+
+	data, err := cl.CustomerDataExport(context.Background(), "cst1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, now, data.ExportedAt)
+	AssertEquals(t, "Doe", data.Customer.LastName)
+	AssertEquals(t, 2, len(data.Mandates))
+	AssertEquals(t, "signed", data.Mandates[0].State)
+	AssertEquals(t, 1, len(data.Transactions))
+	AssertEquals(t, 1, len(data.Pdfs))
+
+	var out bytes.Buffer
+	if err = data.WriteZip(&out); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 2, len(archive.File))
+	AssertEquals(t, "customer.json", archive.File[0].Name)
+	AssertEquals(t, "mandates/M1.pdf", archive.File[1].Name)
+
+	file, _ := archive.File[0].Open()
+	content, _ := io.ReadAll(file)
+	var exported CustomerData
+	if err = json.Unmarshal(content, &exported); err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, "i1", exported.Invoices[0].Id)
+}
+
+func TestCustomerErase(t *testing.T) {
+	var calls []string
+	server := newCustomerDataServer(t, &calls)
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+
+	var audit bytes.Buffer
+	report, err := cl.CustomerErase(context.Background(), &ErasureRequest{CustomerNumber: "cst1", Reason: "GDPR", DryRun: true, AuditLog: &audit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 0, len(calls))
+	AssertEquals(t, 5, len(report.Entries))
+	AssertEquals(t, 5, strings.Count(audit.String(), "\n"))
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	cl.TimeProvider = &TestTimeProvider{currentTime: now}
+	cl.lastLogin = now
+
+	report, err = cl.CustomerErase(context.Background(), &ErasureRequest{CustomerNumber: "cst1", Reason: "GDPR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 0, len(report.Failed()))
+	expected := []string{
+		"DELETE /creditor/subscription/M1/S1",
+		"POST /creditor/mandate/update",
+		"DELETE /creditor/mandate",
+		"POST /creditor/mandate/update",
+		"PATCH /creditor/customer/cst1",
+	}
+	AssertEquals(t, strings.Join(expected, "\n"), strings.Join(calls, "\n"))
+	AssertEquals(t, "cancel_subscription", report.Entries[0].Action)
+	AssertEquals(t, "anonymize_customer", report.Entries[4].Action)
+	AssertEquals(t, now, report.Entries[4].Time)
+
+	_, err = cl.CustomerErase(context.Background(), &ErasureRequest{})
+	if _, ok := err.(*ValidationError); !ok {
+		t.Errorf("Expected a validation error, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package iban
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+//go:embed bic.csv
+var defaultBicTable []byte
+
+// bankCodeLength is the length of the bank identifier at the start of the bban per supported country
+var bankCodeLength = map[string]int{
+	"BE": 3,
+	"NL": 4,
+}
+
+type bicRange struct {
+	from string
+	to   string
+	bic  string
+}
+
+var (
+	bicMutex sync.RWMutex
+	bicTable map[string][]bicRange
+)
+
+func init() {
+	if err := LoadBICTable(bytes.NewReader(defaultBicTable)); err != nil {
+		panic(err)
+	}
+}
+
+// LoadBICTable replaces the table used by BIC with the content of the reader. This allows the embedded
+// table to be updated without a new release. Every line contains "country,first bank code,last bank code,bic",
+// empty lines or lines starting with '#' are ignored.
+func LoadBICTable(r io.Reader) error {
+	table := make(map[string][]bicRange)
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) != 4 {
+			return fmt.Errorf("invalid bic table on line %d: expected 4 fields", lineNo)
+		}
+		country := strings.ToUpper(strings.TrimSpace(fields[0]))
+		length, ok := bankCodeLength[country]
+		if !ok {
+			return fmt.Errorf("invalid bic table on line %d: unsupported country %s", lineNo, country)
+		}
+		from := strings.ToUpper(strings.TrimSpace(fields[1]))
+		to := strings.ToUpper(strings.TrimSpace(fields[2]))
+		bic := strings.ToUpper(strings.TrimSpace(fields[3]))
+		if len(from) != length || len(to) != length || from > to {
+			return fmt.Errorf("invalid bic table on line %d: invalid bank code range", lineNo)
+		}
+		if err := ValidateBIC(bic); err != nil {
+			return fmt.Errorf("invalid bic table on line %d: %v", lineNo, err)
+		}
+		table[country] = append(table[country], bicRange{from: from, to: to, bic: bic})
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	bicMutex.Lock()
+	bicTable = table
+	bicMutex.Unlock()
+	return nil
+}
+
+// BIC derives the BIC from the bank code inside a (valid) Belgian or Dutch iban. The second return value
+// is false when the iban is invalid or the bank code is unknown.
+func BIC(iban string) (string, bool) {
+	iban = Normalize(iban)
+	if Validate(iban) != nil {
+		return "", false
+	}
+	country := iban[:2]
+	length, ok := bankCodeLength[country]
+	if !ok {
+		return "", false
+	}
+	code := iban[4 : 4+length]
+
+	bicMutex.RLock()
+	defer bicMutex.RUnlock()
+	for _, r := range bicTable[country] {
+		if code >= r.from && code <= r.to {
+			return r.bic, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,155 @@
+package iban
+
+import (
+	"strconv"
+)
+
+// format describes the BBAN structure of a country using the notation of the SWIFT IBAN registry
+// eg. "3n7n2n" for Belgium: n = digits, a = uppercase letters, c = alphanumeric
+type format string
+
+type segment struct {
+	length int
+	kind   byte
+}
+
+func (f format) segments() []segment {
+	var segments []segment
+	start := 0
+	for i := 0; i < len(f); i++ {
+		if f[i] < '0' || f[i] > '9' {
+			n, _ := strconv.Atoi(string(f[start:i]))
+			segments = append(segments, segment{length: n, kind: f[i]})
+			start = i + 1
+		}
+	}
+	return segments
+}
+
+// length returns the full length of the iban (country code + check digits + bban)
+func (f format) length() int {
+	total := 4
+	for _, s := range f.segments() {
+		total += s.length
+	}
+	return total
+}
+
+// matches verifies that the bban follows the structure of the format
+func (f format) matches(bban string) bool {
+	pos := 0
+	for _, s := range f.segments() {
+		for i := 0; i < s.length; i++ {
+			if pos >= len(bban) {
+				return false
+			}
+			ch := bban[pos]
+			switch s.kind {
+			case 'n':
+				if !isDigit(ch) {
+					return false
+				}
+			case 'a':
+				if !isLetter(ch) {
+					return false
+				}
+			default:
+				if !isDigit(ch) && !isLetter(ch) {
+					return false
+				}
+			}
+			pos++
+		}
+	}
+	return pos == len(bban)
+}
+
+// countries contains the BBAN format per country as defined in the SWIFT IBAN registry
+var countries = map[string]format{
+	"AD": "4n4n12c",
+	"AE": "3n16n",
+	"AL": "8n16c",
+	"AT": "5n11n",
+	"AZ": "4a20c",
+	"BA": "3n3n8n2n",
+	"BE": "3n7n2n",
+	"BG": "4a4n2n8c",
+	"BH": "4a14c",
+	"BR": "8n5n10n1a1c",
+	"BY": "4c4n16c",
+	"CH": "5n12c",
+	"CR": "4n14n",
+	"CY": "3n5n16c",
+	"CZ": "4n6n10n",
+	"DE": "8n10n",
+	"DK": "4n9n1n",
+	"DO": "4c20n",
+	"EE": "2n2n11n1n",
+	"EG": "4n4n17n",
+	"ES": "4n4n1n1n10n",
+	"FI": "3n11n",
+	"FO": "4n9n1n",
+	"FR": "5n5n11c2n",
+	"GB": "4a6n8n",
+	"GE": "2a16n",
+	"GI": "4a15c",
+	"GL": "4n9n1n",
+	"GR": "3n4n16c",
+	"GT": "4c20c",
+	"HR": "7n10n",
+	"HU": "3n4n1n15n1n",
+	"IE": "4a6n8n",
+	"IL": "3n3n13n",
+	"IQ": "4a3n12n",
+	"IS": "4n2n6n10n",
+	"IT": "1a5n5n12c",
+	"JO": "4a4n18c",
+	"KW": "4a22c",
+	"KZ": "3n13c",
+	"LB": "4n20c",
+	"LC": "4a24c",
+	"LI": "5n12c",
+	"LT": "5n11n",
+	"LU": "3n13c",
+	"LV": "4a13c",
+	"MC": "5n5n11c2n",
+	"MD": "2c18c",
+	"ME": "3n13n2n",
+	"MK": "3n10c2n",
+	"MR": "5n5n11n2n",
+	"MT": "4a5n18c",
+	"MU": "4a2n2n12n3n3a",
+	"NL": "4a10n",
+	"NO": "4n6n1n",
+	"PK": "4a16c",
+	"PL": "8n16n",
+	"PS": "4a21c",
+	"PT": "4n4n11n2n",
+	"QA": "4a21c",
+	"RO": "4a16c",
+	"RS": "3n13n2n",
+	"SA": "2n18c",
+	"SC": "4a2n2n16n3a",
+	"SE": "3n16n1n",
+	"SI": "5n8n2n",
+	"SK": "4n6n10n",
+	"SM": "1a5n5n12c",
+	"ST": "8n11n2n",
+	"SV": "4a20n",
+	"TL": "3n14n2n",
+	"TN": "2n3n13n2n",
+	"TR": "5n1n16c",
+	"UA": "6n19c",
+	"VA": "3n15n",
+	"VG": "4a16n",
+	"XK": "4n10n2n",
+}
+
+// sepaCountries are the countries (with their own IBAN prefix) that are part of the SEPA scheme
+var sepaCountries = map[string]bool{
+	"AD": true, "AT": true, "BE": true, "BG": true, "CH": true, "CY": true, "CZ": true, "DE": true,
+	"DK": true, "EE": true, "ES": true, "FI": true, "FR": true, "GB": true, "GI": true, "GR": true,
+	"HR": true, "HU": true, "IE": true, "IS": true, "IT": true, "LI": true, "LT": true, "LU": true,
+	"LV": true, "MC": true, "MT": true, "NL": true, "NO": true, "PL": true, "PT": true, "RO": true,
+	"SE": true, "SI": true, "SK": true, "SM": true, "VA": true,
+}
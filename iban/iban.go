@@ -0,0 +1,118 @@
+// Package iban provides offline validation of IBAN and BIC codes, allowing requests to be checked
+// before they are sent to Twikey (and thus before a customer is ever contacted).
+package iban
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrTooShort is returned when the value can't even hold a country code and check digits
+	ErrTooShort = errors.New("iban is too short")
+	// ErrUnknownCountry is returned when the country code is not part of the IBAN registry
+	ErrUnknownCountry = errors.New("iban country is not supported")
+	// ErrInvalidLength is returned when the length doesn't match the one of the country
+	ErrInvalidLength = errors.New("iban has an invalid length for its country")
+	// ErrInvalidFormat is returned when the BBAN doesn't match the structure of the country
+	ErrInvalidFormat = errors.New("iban has an invalid format for its country")
+	// ErrInvalidChecksum is returned when the mod-97 check fails
+	ErrInvalidChecksum = errors.New("iban has an invalid checksum")
+	// ErrInvalidBIC is returned when a BIC doesn't follow the ISO 9362 structure
+	ErrInvalidBIC = errors.New("bic has an invalid format")
+)
+
+// Normalize removes all spaces and converts the value to uppercase, as IBANs are often
+// written in groups of 4 characters.
+func Normalize(iban string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(iban), ""))
+}
+
+// Country returns the 2 letter country code of an iban (or an empty string when too short)
+func Country(iban string) string {
+	iban = Normalize(iban)
+	if len(iban) < 2 {
+		return ""
+	}
+	return iban[:2]
+}
+
+// Validate checks the length, the country specific format and the mod-97 checksum of an iban.
+func Validate(iban string) error {
+	iban = Normalize(iban)
+	if len(iban) < 5 {
+		return ErrTooShort
+	}
+	spec, ok := countries[iban[:2]]
+	if !ok {
+		return ErrUnknownCountry
+	}
+	if len(iban) != spec.length() {
+		return ErrInvalidLength
+	}
+	if !isDigit(iban[2]) || !isDigit(iban[3]) || !spec.matches(iban[4:]) {
+		return ErrInvalidFormat
+	}
+	if Mod97(iban[4:]+iban[:4]) != 1 {
+		return ErrInvalidChecksum
+	}
+	return nil
+}
+
+// IsValid is a convenience method returning whether Validate succeeds
+func IsValid(iban string) bool {
+	return Validate(iban) == nil
+}
+
+// IsSEPA returns true if the iban belongs to a country that is reachable via SEPA (direct debit and credit transfer).
+func IsSEPA(iban string) bool {
+	return sepaCountries[Country(iban)]
+}
+
+// ValidateBIC checks the structure of a BIC (ISO 9362): 4 letter bank code, 2 letter country code,
+// 2 character location code and an optional 3 character branch code.
+func ValidateBIC(bic string) error {
+	bic = strings.ToUpper(strings.TrimSpace(bic))
+	if len(bic) != 8 && len(bic) != 11 {
+		return ErrInvalidBIC
+	}
+	for i := 0; i < len(bic); i++ {
+		ch := bic[i]
+		if i < 6 {
+			if !isLetter(ch) {
+				return ErrInvalidBIC
+			}
+		} else if !isLetter(ch) && !isDigit(ch) {
+			return ErrInvalidBIC
+		}
+	}
+	return nil
+}
+
+// Mod97 computes the remainder modulo 97 of the numeric representation (A=10, B=11, ..) of the value as used by
+// the checksums of ibans, creditor references (ISO 11649) and creditor identifiers. It returns -1 when the value
+// contains anything else than digits and uppercase letters.
+func Mod97(value string) int {
+	// digit by digit to avoid big number arithmetic
+	remainder := 0
+	for i := 0; i < len(value); i++ {
+		ch := value[i]
+		switch {
+		case isDigit(ch):
+			remainder = (remainder*10 + int(ch-'0')) % 97
+		case isLetter(ch):
+			remainder = (remainder*100 + int(ch-'A') + 10) % 97
+		default:
+			return -1
+		}
+	}
+	return remainder
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isLetter(ch byte) bool {
+	return ch >= 'A' && ch <= 'Z'
+}
@@ -0,0 +1,114 @@
+package iban
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		iban     string
+		expected error
+	}{
+		{"BE09363107700857", nil},
+		{"be09 3631 0770 0857", nil},
+		{"BE68539007547034", nil},
+		{"NL91ABNA0417164300", nil},
+		{"DE89370400440532013000", nil},
+		{"GB82WEST12345698765432", nil},
+		{"FR1420041010050500013M02606", nil},
+		{"MU17BOMM0101101030300200000MUR", nil},
+		{"BE", ErrTooShort},
+		{"XX09363107700857", ErrUnknownCountry},
+		{"BE0936310770085", ErrInvalidLength},
+		{"NL91ABNA04171643AB", ErrInvalidFormat},
+		{"BE10363107700857", ErrInvalidChecksum},
+	}
+	for _, test := range tests {
+		if err := Validate(test.iban); err != test.expected {
+			t.Errorf("Validate(%s): expected %v, got %v", test.iban, test.expected, err)
+		}
+	}
+}
+
+func TestMod97(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int
+	}{
+		{"363107700857BE09", 1},  // iban
+		{"539007547034RF18", 1},  // creditor reference
+		{"050D000000008BE69", 1}, // creditor identifier
+		{"363107700857BE10", 2},
+		{"3631-0770", -1},
+		{"abc", -1},
+	}
+	for _, test := range tests {
+		if remainder := Mod97(test.value); remainder != test.expected {
+			t.Errorf("Mod97(%s): expected %d, got %d", test.value, test.expected, remainder)
+		}
+	}
+}
+
+func TestIsSEPA(t *testing.T) {
+	if !IsSEPA("BE09363107700857") {
+		t.Error("Belgium should be part of SEPA")
+	}
+	if !IsSEPA("GB82WEST12345698765432") {
+		t.Error("United Kingdom should be part of SEPA")
+	}
+	if IsSEPA("MU17BOMM0101101030300200000MUR") {
+		t.Error("Mauritius should not be part of SEPA")
+	}
+}
+
+func TestValidateBIC(t *testing.T) {
+	for _, bic := range []string{"GEBABEBB", "KREDBEBB", "GKCCBEBB", "BBRUBEBB", "DEUTDEFF500"} {
+		if err := ValidateBIC(bic); err != nil {
+			t.Errorf("ValidateBIC(%s): %v", bic, err)
+		}
+	}
+	for _, bic := range []string{"", "GEBEBEB", "GEB1BEBB", "GEBABEBB50", "GEBABEBB-XX"} {
+		if err := ValidateBIC(bic); err != ErrInvalidBIC {
+			t.Errorf("ValidateBIC(%s): expected an error", bic)
+		}
+	}
+}
+
+func TestBIC(t *testing.T) {
+	tests := map[string]string{
+		"BE09363107700857":   "BBRUBEBB",
+		"NL91ABNA0417164300": "ABNANL2A",
+	}
+	for account, expected := range tests {
+		bic, ok := BIC(account)
+		if !ok || bic != expected {
+			t.Errorf("BIC(%s): expected %s, got %s", account, expected, bic)
+		}
+	}
+	if _, ok := BIC("DE89370400440532013000"); ok {
+		t.Error("German banks are not part of the table")
+	}
+	if _, ok := BIC("BE10363107700857"); ok {
+		t.Error("Invalid ibans should not have a bic")
+	}
+}
+
+func TestLoadBICTable(t *testing.T) {
+	defer func() {
+		_ = LoadBICTable(strings.NewReader(string(defaultBicTable)))
+	}()
+
+	if err := LoadBICTable(strings.NewReader("BE,363,363,TESTBEBB\n")); err != nil {
+		t.Fatal(err)
+	}
+	if bic, _ := BIC("BE09363107700857"); bic != "TESTBEBB" {
+		t.Errorf("Expected the loaded table to be used, got %s", bic)
+	}
+	if err := LoadBICTable(strings.NewReader("DE,100,100,TESTDEFF\n")); err == nil {
+		t.Error("Expected unsupported countries to be refused")
+	}
+	if err := LoadBICTable(strings.NewReader("BE,363,363,INVALID\n")); err == nil {
+		t.Error("Expected invalid bics to be refused")
+	}
+}
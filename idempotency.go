@@ -0,0 +1,73 @@
+package twikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+)
+
+// IdempotencyKeyGenerator returns the key to use for a mutating call based on its endpoint and payload
+type IdempotencyKeyGenerator func(endpoint string, payload []byte) string
+
+// RandomIdempotencyKey generates a random (uuid v4) key for every new request
+func RandomIdempotencyKey(_ string, _ []byte) string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// DerivedIdempotencyKey derives the key from the endpoint and the payload, this way sending the same
+// request twice (eg. after a restart of the application) results in the same key.
+func DerivedIdempotencyKey(endpoint string, payload []byte) string {
+	hash := sha256.New()
+	_, _ = hash.Write([]byte(endpoint))
+	_, _ = hash.Write([]byte{0})
+	_, _ = hash.Write(payload)
+	return hex.EncodeToString(hash.Sum(nil))[:32]
+}
+
+// WithIdempotencyKeys makes the client add an Idempotency-Key to the calls creating something that have an
+// IdempotencyKey field in their request (eg. TransactionNew, InvoiceAdd or DocumentInvite) when none was passed.
+// The generated key is stored in that field, so retrying the same request (eg. after a timeout) reuses the key and
+// Twikey replays the previous response instead of creating it twice. Clear the field before sending a changed
+// request. The key is also available on the result along with the fact whether Twikey replayed a response.
+func WithIdempotencyKeys(generator IdempotencyKeyGenerator) ClientOption {
+	return func(client *Client) {
+		client.idempotencyKeys = generator
+	}
+}
+
+// idempotentResult is implemented by the results that expose the idempotency of the call
+type idempotentResult interface {
+	setIdempotency(key string, replayed bool)
+}
+
+// addIdempotencyKey sets the Idempotency-Key header of a create call, either using the key of the request or
+// generating one when the client was configured to do so. A generated key is stored in the request so a retry of
+// the same request sends the same key.
+func (c *Client) addIdempotencyKey(req *http.Request, key *string, payload []byte) {
+	if req == nil || req.Header.Get("Idempotency-Key") != "" {
+		return
+	}
+	if *key == "" && c.idempotencyKeys != nil && isMutating(req.Method) {
+		*key = c.idempotencyKeys(req.URL.RequestURI(), payload)
+	}
+	if *key != "" {
+		req.Header.Set("Idempotency-Key", *key)
+	}
+}
+
+// isReplayed returns true if Twikey indicated that the response was replayed for an existing idempotency key
+func isReplayed(res *http.Response) bool {
+	return res.Header.Get("Idempotent-Replayed") == "true"
+}
+
+func isMutating(method string) bool {
+	return method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch || method == http.MethodDelete
+}
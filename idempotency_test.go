@@ -0,0 +1,103 @@
+package twikey
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		for _, previous := range keys {
+			if previous == key {
+				w.Header().Set("Idempotent-Replayed", "true")
+			}
+		}
+		keys = append(keys, key)
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		_, _ = w.Write([]byte(`{"Entries":[{"id":1,"mndtId":"MNDT1","amount":10,"msg":"Test"}]}`))
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+	WithIdempotencyKeys(DerivedIdempotencyKey)(cl)
+
+	request := &TransactionRequest{DocumentReference: "MNDT1", Msg: "Test", Amount: 10}
+	first, err := cl.TransactionNew(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.IdempotencyKey == "" {
+		t.Fatal("Expected the generated key on the result")
+	}
+	AssertEquals(t, first.IdempotencyKey, request.IdempotencyKey)
+	AssertEquals(t, false, first.IdempotentReplay)
+
+	// the same content derives the same key, even in a new request
+	second, err := cl.TransactionNew(context.Background(), &TransactionRequest{DocumentReference: "MNDT1", Msg: "Test", Amount: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, first.IdempotencyKey, second.IdempotencyKey)
+	AssertEquals(t, true, second.IdempotentReplay)
+
+	// a changed request (without the previous key) gets a new key instead of the result of the previous one
+	third, err := cl.TransactionNew(context.Background(), &TransactionRequest{DocumentReference: "MNDT1", Msg: "Test", Amount: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.IdempotencyKey == first.IdempotencyKey || third.IdempotentReplay {
+		t.Error("Expected a new key for a changed request")
+	}
+
+	// calls without a key field don't get one
+	if err := cl.SubscriptionCancel(context.Background(), "MNDT1", "REF1"); err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 4, len(keys))
+	AssertEquals(t, "", keys[3])
+}
+
+func TestIdempotencyKeyKeptForRetry(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if len(keys) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"Entries":[{"id":1,"mndtId":"MNDT1","amount":10,"msg":"Test"}]}`))
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+	WithIdempotencyKeys(RandomIdempotencyKey)(cl)
+
+	request := &TransactionRequest{DocumentReference: "MNDT1", Msg: "Test", Amount: 10}
+	if _, err := cl.TransactionNew(context.Background(), request); err == nil {
+		t.Fatal("Expected the first attempt to fail")
+	}
+	// retrying the same request sends the same random key
+	transaction, err := cl.TransactionNew(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 2, len(keys))
+	if keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("Expected the retry to reuse the key, got %v", keys)
+	}
+	AssertEquals(t, keys[0], transaction.IdempotencyKey)
+}
+
+func TestRandomIdempotencyKey(t *testing.T) {
+	first := RandomIdempotencyKey("/creditor/transaction", nil)
+	second := RandomIdempotencyKey("/creditor/transaction", nil)
+	AssertEquals(t, 36, len(first))
+	if first == second {
+		t.Error("Expected random keys to differ")
+	}
+}
@@ -6,9 +6,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 )
 
@@ -26,6 +26,7 @@ const (
 // Invoice is the base object for sending and receiving invoices to Twikey
 type Invoice struct {
 	Id                 string            `json:"id,omitempty"`
+	Seq                int64             `json:"seq,omitempty"`
 	Number             string            `json:"number"`
 	RelatedInvoice     string            `json:"relatedInvoiceNumber"` // RelatedInvoice in case this is a creditNote
 	Title              string            `json:"title"`
@@ -34,7 +35,11 @@ type Invoice struct {
 	Manual             bool              `json:"manual,omitempty"`
 	Locale             string            `json:"locale,omitempty"`
 	State              string            `json:"state,omitempty"`
-	Amount             float64           `json:"amount"`
+	Amount             float64           `json:"amount"` // Total amount including VAT
+	NetAmount          float64           `json:"netAmount,omitempty"`
+	VatAmount          float64           `json:"vatAmount,omitempty"`
+	Lines              []InvoiceLine     `json:"lines,omitempty"`
+	VatSubtotals       []VatSubtotal     `json:"vatSubtotals,omitempty"`
 	Date               string            `json:"date"`
 	Duedate            string            `json:"duedate"`
 	Ref                string            `json:"ref,omitempty"`
@@ -45,6 +50,14 @@ type Invoice struct {
 	Meta               *InvoiceFeedMeta  `json:"meta,omitempty"`
 	LastPayment        *Lastpayment      `json:"lastpayment,omitempty"`
 	Extra              map[string]string `json:"extra,omitempty"` // extra attributes
+
+	IdempotencyKey   string `json:"-"` // key used when creating the invoice
+	IdempotentReplay bool   `json:"-"` // true if Twikey returned the result of a previous call with the same key
+}
+
+func (inv *Invoice) setIdempotency(key string, replayed bool) {
+	inv.IdempotencyKey = key
+	inv.IdempotentReplay = replayed
 }
 
 type Lastpayment []map[string]interface{}
@@ -57,6 +70,7 @@ type NewInvoiceRequest struct {
 	Purpose          string
 	Manual           bool // Don't automatically collect
 	ForceTransaction bool // Ignore the state of the contract if passed
+	Refund           bool // Credit notes only: refund the credited amount when the related invoice was already paid
 	Template         string
 	Delivery         string // email/print/peppol/disabled
 	Contract         string
@@ -65,6 +79,41 @@ type NewInvoiceRequest struct {
 	Extra            map[string]string // extra attributes
 }
 
+// Validate checks the request locally and returns a ValidationError listing all invalid fields
+func (request *NewInvoiceRequest) Validate() error {
+	v := validation{}
+	v.oneOf("Delivery", request.Delivery, DeliveryEmail, DeliveryPrint, DeliveryPeppol, DeliveryDisabled)
+	if request.Invoice == nil && len(request.UblBytes) == 0 {
+		v.fail("Invoice", "or UblBytes is required")
+	} else if request.Invoice != nil && len(request.UblBytes) != 0 {
+		v.fail("Invoice", "and UblBytes are exclusive")
+	} else if request.Invoice != nil {
+		invoice := request.Invoice
+		if request.Id != "" && invoice.Id != "" && request.Id != invoice.Id {
+			v.fail("Id", "should match the id of the invoice")
+		}
+		if request.Extra != nil && invoice.Extra != nil {
+			v.fail("Extra", "and the extra of the invoice are exclusive")
+		}
+		v.required("Invoice.Number", invoice.Number)
+		v.required("Invoice.Date", invoice.Date)
+		v.date("Invoice.Date", invoice.Date)
+		v.required("Invoice.Duedate", invoice.Duedate)
+		v.date("Invoice.Duedate", invoice.Duedate)
+		if invoice.Amount == 0 {
+			v.fail("Invoice.Amount", "is required")
+		}
+		v.oneOf("Invoice.Delivery", invoice.Delivery, DeliveryEmail, DeliveryPrint, DeliveryPeppol, DeliveryDisabled)
+		invoice.validateTotals(&v, "Invoice.")
+		if invoice.Customer == nil && invoice.CustomerByDocument == "" {
+			v.fail("Invoice.Customer", "or CustomerByDocument is required")
+		} else if invoice.Customer != nil {
+			v.merge("Invoice.Customer.", invoice.Customer.Validate())
+		}
+	}
+	return v.err()
+}
+
 type UpdateInvoiceRequest struct {
 	ID      string            `json:"-"`
 	Date    string            `json:"date,omitempty"`
@@ -92,6 +141,18 @@ type Customer struct {
 	Mobile         string `json:"mobile,omitempty"`
 }
 
+// Validate checks the customer locally and returns a ValidationError listing all invalid fields
+func (c *Customer) Validate() error {
+	v := validation{}
+	if c.CustomerNumber == "" && c.Email == "" && c.CompanyName == "" && c.LastName == "" {
+		v.fail("CustomerNumber", "or Email, CompanyName or LastName is required")
+	}
+	v.email("Email", c.Email)
+	v.isoCode("Country", c.Country)
+	v.isoCode("Language", c.Language)
+	return v.err()
+}
+
 func (c *Customer) asUrlParams() string {
 	params := url.Values{}
 	addIfExists(params, "email", c.Email)
@@ -105,6 +166,7 @@ func (c *Customer) asUrlParams() string {
 	addIfExists(params, "country", c.Country)
 	addIfExists(params, "l", c.Language)
 	addIfExists(params, "mobile", c.Mobile)
+	addIfExists(params, "peppol", c.Peppol)
 	return params.Encode()
 }
 
@@ -134,12 +196,22 @@ func (inv *Invoice) HasMeta() bool {
 }
 
 type InvoiceFeedMeta struct {
-	LastError string `json:"lastError,omitempty"`
+	LastError string        `json:"lastError,omitempty"`
+	Peppol    *PeppolStatus `json:"peppol,omitempty"` // Delivery status when the invoice was sent via Peppol
 }
 
-// InvoiceAdd sends an invoice to Twikey in UBL format
+// InvoiceAdd sends an invoice to Twikey in UBL format. The totals of an invoice with lines (amount, net amount,
+// VAT amount, line totals and VAT subtotals) that were left empty are computed from its lines, also when the
+// validation is disabled.
 func (c *Client) InvoiceAdd(ctx context.Context, invoiceRequest *NewInvoiceRequest) (*Invoice, error) {
 
+	if invoiceRequest.Invoice != nil {
+		invoiceRequest.Invoice.fillTotals()
+	}
+	if err := c.validate(invoiceRequest); err != nil {
+		return nil, err
+	}
+
 	if err := c.refreshTokenIfRequired(); err != nil {
 		return nil, err
 	}
@@ -173,12 +245,8 @@ func (c *Client) InvoiceAdd(ctx context.Context, invoiceRequest *NewInvoiceReque
 		if err != nil {
 			return nil, err
 		}
-		req, _ = http.NewRequest(http.MethodPost, c.BaseURL+"/creditor/invoice", bytes.NewReader(invoiceBytes))
-		req.WithContext(ctx)
+		req, _ = http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/creditor/invoice", bytes.NewReader(invoiceBytes))
 		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Authorization", c.apiToken) //Already there
-		req.Header.Set("Accept", "application/json")
-		req.Header.Set("User-Agent", c.UserAgent)
 		// req.Header.Set("X-Ref", invoiceRequest.Reference)  ref already in json
 		if invoiceRequest.Origin != "" {
 			req.Header.Set("X-PARTNER", invoiceRequest.Origin)
@@ -192,17 +260,14 @@ func (c *Client) InvoiceAdd(ctx context.Context, invoiceRequest *NewInvoiceReque
 		if invoiceRequest.ForceTransaction {
 			req.Header.Set("X-FORCE-TRANSACTION", "true")
 		}
-		if invoiceRequest.IdempotencyKey != "" {
-			req.Header.Add("Idempotency-Key", invoiceRequest.IdempotencyKey)
+		if invoiceRequest.Refund {
+			req.Header.Set("X-REFUND", "true")
 		}
+		c.addIdempotencyKey(req, &invoiceRequest.IdempotencyKey, invoiceBytes)
 	} else if len(invoiceRequest.UblBytes) != 0 {
 		invoiceUrl := c.BaseURL + "/creditor/invoice/ubl"
-		req, _ = http.NewRequest(http.MethodPost, invoiceUrl, bytes.NewReader(invoiceRequest.UblBytes))
-		req.WithContext(ctx)
+		req, _ = http.NewRequestWithContext(ctx, http.MethodPost, invoiceUrl, bytes.NewReader(invoiceRequest.UblBytes))
 		req.Header.Set("Content-Type", "application/xml")
-		req.Header.Set("Authorization", c.apiToken) //Already there
-		req.Header.Set("Accept", "application/json")
-		req.Header.Set("User-Agent", c.UserAgent)
 		if invoiceRequest.Id != "" {
 			req.Header.Set("X-INVOICE-ID", invoiceRequest.Id)
 		}
@@ -227,12 +292,13 @@ func (c *Client) InvoiceAdd(ctx context.Context, invoiceRequest *NewInvoiceReque
 		if invoiceRequest.ForceTransaction {
 			req.Header.Set("X-FORCE-TRANSACTION", "true")
 		}
+		if invoiceRequest.Refund {
+			req.Header.Set("X-REFUND", "true")
+		}
 		if invoiceRequest.Reference != "" {
 			req.Header.Set("X-Ref", invoiceRequest.Reference)
 		}
-		if invoiceRequest.IdempotencyKey != "" {
-			req.Header.Add("Idempotency-Key", invoiceRequest.IdempotencyKey)
-		}
+		c.addIdempotencyKey(req, &invoiceRequest.IdempotencyKey, invoiceRequest.UblBytes)
 		// include extra
 		for key, value := range invoiceRequest.Extra {
 			req.Header.Add(key, value)
@@ -245,78 +311,67 @@ func (c *Client) InvoiceAdd(ctx context.Context, invoiceRequest *NewInvoiceReque
 		}
 	}
 
-	res, err := c.HTTPClient.Do(req)
-	if err != nil {
+	invoice := &Invoice{}
+	if err := c.sendRequest(req, invoice); err != nil {
+		c.Debug.Debugf("Error sending invoice to Twikey: %v", err)
 		return nil, err
 	}
-	if res.StatusCode == 200 {
-		payload, _ := io.ReadAll(res.Body)
-		c.Debug.Debugf("TwikeyInvoice: %s", string(payload))
-		if res.Header["X-Warning"] != nil {
-			c.Debug.Debugf("Warning for new invoice with ref=%s : %s", invoiceRequest.Reference, res.Header["X-Warning"])
-		}
-		var invoice Invoice
-		err := json.Unmarshal(payload, &invoice)
-		if err != nil {
-			return nil, err
-		}
-		return &invoice, nil
-	}
-
-	errLoad, _ := io.ReadAll(res.Body)
-	c.Debug.Debugf("Error sending invoice to Twikey: %s", string(errLoad))
-	return nil, NewTwikeyErrorFromResponse(res)
+	return invoice, nil
 }
 
 // InvoiceFeed Get invoice Feed twikey
 func (c *Client) InvoiceFeed(ctx context.Context, callback func(invoice *Invoice), options ...FeedOption) error {
+	return c.HandleInvoiceFeed(ctx, func(invoice *Invoice) error {
+		callback(invoice)
+		return nil
+	}, options...)
+}
 
-	if err := c.refreshTokenIfRequired(); err != nil {
-		return err
-	}
-
-	feedOptions := parseFeedOptions(options)
-	_url := c.BaseURL + "/creditor/invoice"
-	for i, sideload := range feedOptions.includes {
-		if i == 0 {
-			_url = _url + "?include=" + sideload
-		} else {
-			_url = _url + "&include=" + sideload
-		}
-	}
-
+// HandleInvoiceFeed retrieves the invoice updates since the last call, the first error returned by the handler
+// stops the feed and is returned as a FeedError containing the Seq of the last handled invoice.
+func (c *Client) HandleInvoiceFeed(ctx context.Context, handler func(invoice *Invoice) error, options ...FeedOption) error {
+	feed := c.newFeedReader(ctx, "/creditor/invoice", options)
 	for {
-		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, _url, nil)
-		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-		req.Header.Set("Authorization", c.apiToken)
-		req.Header.Set("User-Agent", c.UserAgent)
-
-		if feedOptions.start != -1 {
-			req.Header.Set("X-RESUME-AFTER", fmt.Sprintf("%d", feedOptions.start))
-			feedOptions.start = -1
-		}
-
 		var feeds InvoiceFeed
-		if err := c.sendRequest(req, &feeds); err != nil {
+		if err := feed.next(&feeds); err != nil {
 			return err
 		}
-		for _, invoice := range feeds.Invoices {
-			callback(&invoice)
+		for i := range feeds.Invoices {
+			invoice := &feeds.Invoices[i]
+			if err := feed.handled(invoice.Seq, handler(invoice)); err != nil {
+				return err
+			}
 		}
-
 		if len(feeds.Invoices) == 0 {
 			return nil
 		}
 	}
 }
 
+// InvoiceStream delivers the invoice feed over a channel, allowing it to be consumed by eg. a pool of workers.
+// The channel is closed when the feed is empty, when an error occurred (see FeedStream.Errors) or when the context is cancelled.
+func (c *Client) InvoiceStream(ctx context.Context, options ...FeedOption) (<-chan *Invoice, *FeedStream) {
+	events := make(chan *Invoice)
+	stream := newFeedStream(options)
+	go func() {
+		defer close(events)
+		stream.finish(ctx, c.HandleInvoiceFeed(ctx, func(invoice *Invoice) error {
+			stream.delivering(invoice.Seq)
+			select {
+			case events <- invoice:
+				return nil
+			case <-ctx.Done():
+				stream.undelivered()
+				return ctx.Err()
+			}
+		}, options...))
+	}()
+	return events, stream
+}
+
 // InvoiceDetail allows a snapshot of a particular invoice, note that this is rate limited
 func (c *Client) InvoiceDetail(ctx context.Context, invoiceIdOrNumber string, feedOptions ...FeedOption) (*Invoice, error) {
 
-	if err := c.refreshTokenIfRequired(); err != nil {
-		return nil, err
-	}
-
 	feedOption := parseFeedOptions(feedOptions)
 
 	_url := c.BaseURL + "/creditor/invoice/" + invoiceIdOrNumber
@@ -328,110 +383,268 @@ func (c *Client) InvoiceDetail(ctx context.Context, invoiceIdOrNumber string, fe
 		}
 	}
 
-	req, _ := http.NewRequest(http.MethodGet, _url, nil)
-	req.WithContext(ctx)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, _url, nil)
 	req.Header.Add("Accept-Language", "en")
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("User-Agent", c.UserAgent)
-	req.Header.Add("Authorization", c.apiToken)
 
-	res, err := c.HTTPClient.Do(req)
-	if err != nil {
+	invoice := &Invoice{}
+	if err := c.sendRequest(req, invoice); err != nil {
 		return nil, err
 	}
-	if res.StatusCode == 200 {
-		payload, _ := io.ReadAll(res.Body)
+	return invoice, nil
+}
 
-		var invoice Invoice
-		err := json.Unmarshal(payload, &invoice)
-		if err != nil {
-			return nil, err
-		}
+// InvoiceQueryRequest contains the filters to search for invoices, all filters are optional
+type InvoiceQueryRequest struct {
+	// State of the invoice (eg. PENDING, PAID, EXPIRED, ...)
+	State string
+	// CustomerNumber specifies the reference of a customer.
+	CustomerNumber string
+	// FromDate and ToDate limit the invoice date (yyyy-mm-dd, inclusive)
+	FromDate string
+	ToDate   string
+	// FromDueDate and ToDueDate limit the due date (yyyy-mm-dd, inclusive)
+	FromDueDate string
+	ToDueDate   string
+	// Ref of the invoice
+	Ref string
+	// Template (ct) used for the invoice
+	Template string
+	// Page of the results (if more than 1 is available)
+	Page int
+}
+
+// Validate checks the request locally and returns a ValidationError listing all invalid fields
+func (r *InvoiceQueryRequest) Validate() error {
+	v := validation{}
+	v.date("FromDate", r.FromDate)
+	v.date("ToDate", r.ToDate)
+	v.date("FromDueDate", r.FromDueDate)
+	v.date("ToDueDate", r.ToDueDate)
+	return v.err()
+}
 
-		return &invoice, nil
+func (r *InvoiceQueryRequest) asUrlParams() string {
+	params := url.Values{}
+	addIfExists(params, "state", r.State)
+	addIfExists(params, "customerNumber", r.CustomerNumber)
+	addIfExists(params, "fromDate", r.FromDate)
+	addIfExists(params, "toDate", r.ToDate)
+	addIfExists(params, "fromDueDate", r.FromDueDate)
+	addIfExists(params, "toDueDate", r.ToDueDate)
+	addIfExists(params, "ref", r.Ref)
+	addIfExists(params, "ct", r.Template)
+	if r.Page > 0 {
+		params.Add("page", strconv.Itoa(r.Page))
 	}
-	return nil, NewTwikeyErrorFromResponse(res)
+	return params.Encode()
 }
 
-// InvoiceAction allows certain actions to be done on an existing invoice
-func (c *Client) InvoiceAction(ctx context.Context, invoiceIdOrNumber string, action InvoiceAction) error {
+// NextPage will increment the current page number of the invoice query.
+func (r *InvoiceQueryRequest) NextPage() *InvoiceQueryRequest {
+	r.Page++
+	return r
+}
 
-	if err := c.refreshTokenIfRequired(); err != nil {
-		return err
+type InvoiceQueryResponse struct {
+	Invoices []Invoice `json:"Invoices"`
+	Links    struct {
+		Previous string `json:"previous"`
+		Self     string `json:"self"`
+		Next     string `json:"next"`
+	} `json:"_links"`
+}
+
+// HasNext will return true if another page of results is available.
+func (r *InvoiceQueryResponse) HasNext() bool {
+	return r.Links.Next != ""
+}
+
+// InvoiceQuery retrieves a single page of invoices matching the query.
+func (c *Client) InvoiceQuery(ctx context.Context, request *InvoiceQueryRequest) (*InvoiceQueryResponse, error) {
+	if err := c.validate(request); err != nil {
+		return nil, err
 	}
 
-	_url := c.BaseURL + "/creditor/invoice/" + invoiceIdOrNumber + "/action"
-	params := url.Values{}
+	endpoint := c.BaseURL + "/creditor/invoice/query"
+	if params := request.asUrlParams(); params != "" {
+		endpoint += "?" + params
+	}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	var output InvoiceQueryResponse
+	if err := c.sendRequest(req, &output); err != nil {
+		return nil, err
+	}
+	return &output, nil
+}
+
+// InvoiceQueryAll walks through all pages of invoices matching the query (starting from the page in the request).
+// The first error returned by the callback stops the iteration and is returned. The request itself is not modified.
+func (c *Client) InvoiceQueryAll(ctx context.Context, request *InvoiceQueryRequest, callback func(invoice *Invoice) error) error {
+	query := *request
+	for {
+		page, err := c.InvoiceQuery(ctx, &query)
+		if err != nil {
+			return err
+		}
+		for i := range page.Invoices {
+			if err := callback(&page.Invoices[i]); err != nil {
+				return err
+			}
+		}
+		if !page.HasNext() || len(page.Invoices) == 0 {
+			return nil
+		}
+		query.NextPage()
+	}
+}
 
+// InvoiceAction allows certain actions to be done on an existing invoice, see InvoiceDoAction for all
+// actions including their parameters.
+func (c *Client) InvoiceAction(ctx context.Context, invoiceIdOrNumber string, action InvoiceAction) error {
+
+	var request InvoiceActionRequest
 	switch action {
 	case InvoiceAction_EMAIL:
-		params.Add("type", "email")
+		request = &EmailAction{}
 	case InvoiceAction_SMS:
-		params.Add("type", "sms")
+		request = &SmsAction{}
 	case InvoiceAction_LETTER:
-		params.Add("type", "letter")
+		request = &LetterAction{}
 	case InvoiceAction_REMINDER:
-		params.Add("type", "reminder")
+		request = &ReminderAction{}
 	case InvoiceAction_REOFFER:
-		params.Add("type", "reoffer")
+		request = &ReofferAction{}
 	case InvoiceAction_PEPPOL:
-		params.Add("type", "peppol")
+		request = &PeppolAction{}
 	default:
 		return errors.New("invalid action")
 	}
 
-	req, _ := http.NewRequest(http.MethodPost, _url, strings.NewReader(params.Encode()))
-	req.WithContext(ctx)
-	req.Header.Add("Accept-Language", "en")
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("User-Agent", c.UserAgent)
-	req.Header.Add("Authorization", c.apiToken)
-
-	res, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return err
-	}
-	if res.StatusCode == 204 {
-		return nil
-	}
-	return NewTwikeyErrorFromResponse(res)
+	_, err := c.InvoiceDoAction(ctx, invoiceIdOrNumber, request)
+	return err
 }
 
 // InvoicePayment allows marking an existing invoice as paid
 func (c *Client) InvoicePayment(ctx context.Context, invoiceIdOrNumber string, method string, paymentdate string) error {
+	_, err := c.InvoiceDoAction(ctx, invoiceIdOrNumber, &ManualPaymentAction{
+		Method: method,
+		Date:   paymentdate,
+	})
+	return err
+}
 
-	if err := c.refreshTokenIfRequired(); err != nil {
-		return err
+// InvoiceCancel cancels an open invoice, the reason is shown in the history of the invoice
+func (c *Client) InvoiceCancel(ctx context.Context, invoiceIdOrNumber string, reason string) error {
+	if invoiceIdOrNumber == "" {
+		return errors.New("missing invoice id")
 	}
 
-	_url := c.BaseURL + "/creditor/invoice/" + invoiceIdOrNumber + "/action"
 	params := url.Values{}
-	params.Add("type", "manualPayment")
-	params.Add("rsn", method)
-	params.Add("date", paymentdate)
+	params.Add("type", "cancel")
+	addIfExists(params, "rsn", reason)
 
-	req, _ := http.NewRequest(http.MethodPost, _url, strings.NewReader(params.Encode()))
-	req.WithContext(ctx)
-	req.Header.Add("Accept-Language", "en")
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("User-Agent", c.UserAgent)
-	req.Header.Add("Authorization", c.apiToken)
+	c.Debug.Debugf("Cancel invoice %s : %s", invoiceIdOrNumber, reason)
+	_url := c.BaseURL + "/creditor/invoice/" + invoiceIdOrNumber + "/action"
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, _url, strings.NewReader(params.Encode()))
+	return c.sendRequest(req, nil)
+}
 
-	res, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return err
+// InvoiceDelete removes (archives) an invoice, only invoices without any payments can be deleted
+func (c *Client) InvoiceDelete(ctx context.Context, invoiceIdOrNumber string) error {
+	if invoiceIdOrNumber == "" {
+		return errors.New("missing invoice id")
 	}
-	if res.StatusCode == 204 {
-		return nil
+
+	c.Debug.Debugf("Delete invoice %s", invoiceIdOrNumber)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodDelete, c.BaseURL+"/creditor/invoice/"+invoiceIdOrNumber, nil)
+	return c.sendRequest(req, nil)
+}
+
+// CreditNoteRequest contains the parameters to credit (part of) an existing invoice
+type CreditNoteRequest struct {
+	IdempotencyKey string  // Avoid double entries
+	Invoice        string  // Id or number of the invoice being credited
+	Number         string  // Number of the credit note
+	Amount         float64 // Amount to credit (positive), it is sent as a negative amount
+	Title          string  // Title of the credit note, by default refers to the credited invoice
+	Date           string  // Date of the credit note (yyyy-mm-dd), by default today
+	Remittance     string  // Remittance of the credit note
+	// Refund the credited amount to the customer when the invoice was already paid. By default
+	// the credit note is netted against the open amount of the invoice.
+	Refund bool
+}
+
+// Validate checks the request locally and returns a ValidationError listing all invalid fields
+func (request *CreditNoteRequest) Validate() error {
+	v := validation{}
+	v.required("Invoice", request.Invoice)
+	v.required("Number", request.Number)
+	if request.Amount <= 0 {
+		v.fail("Amount", "should be positive")
 	}
-	return NewTwikeyErrorFromResponse(res)
+	v.date("Date", request.Date)
+	return v.err()
 }
 
-func (c *Client) InvoiceUpdate(ctx context.Context, request *UpdateInvoiceRequest) (*Invoice, error) {
-	if err := c.refreshTokenIfRequired(); err != nil {
+// CreditNoteNew creates a credit note for an existing invoice. The invoice is retrieved first to link the credit note
+// to the same customer and to verify that the credited amount doesn't exceed the amount of the invoice. Twikey
+// refuses a credit note that, together with the ones already issued for the invoice, exceeds that amount.
+func (c *Client) CreditNoteNew(ctx context.Context, request *CreditNoteRequest) (*Invoice, error) {
+	if err := c.validate(request); err != nil {
 		return nil, err
 	}
 
+	original, err := c.InvoiceDetail(ctx, request.Invoice, FeedInclude("customer"))
+	if err != nil {
+		return nil, err
+	}
+	if original.RelatedInvoice != "" || original.Amount < 0 {
+		return nil, &ValidationError{Fields: []FieldError{{Field: "Invoice", Message: "is a credit note itself"}}}
+	}
+	if original.Amount == 0 {
+		return nil, &ValidationError{Fields: []FieldError{{Field: "Invoice", Message: "has no amount to credit"}}}
+	}
+	if request.Amount > original.Amount {
+		return nil, &ValidationError{Fields: []FieldError{{Field: "Amount", Message: fmt.Sprintf("exceeds the amount of invoice %s (%.2f)", original.Number, original.Amount)}}}
+	}
+
+	date := request.Date
+	if date == "" {
+		date = c.TimeProvider.Now().Format("2006-01-02")
+	}
+	title := request.Title
+	if title == "" {
+		title = "Credit note for " + original.Number
+	}
+
+	creditNote := &Invoice{
+		Number:             request.Number,
+		RelatedInvoice:     original.Number,
+		Title:              title,
+		Remittance:         request.Remittance,
+		Amount:             -request.Amount,
+		Date:               date,
+		Duedate:            date,
+		Manual:             true,
+		Customer:           original.Customer,
+		CustomerByDocument: original.CustomerByDocument,
+	}
+	if creditNote.Customer == nil && creditNote.CustomerByDocument == "" {
+		return nil, errors.New("unable to determine the customer of the credited invoice")
+	}
+
+	c.Debug.Debugf("New credit note %s for %s : %.2f", request.Number, original.Number, request.Amount)
+	invoiceRequest := &NewInvoiceRequest{
+		IdempotencyKey: request.IdempotencyKey,
+		Invoice:        creditNote,
+		Refund:         request.Refund,
+	}
+	invoice, err := c.InvoiceAdd(ctx, invoiceRequest)
+	request.IdempotencyKey = invoiceRequest.IdempotencyKey
+	return invoice, err
+}
+
+func (c *Client) InvoiceUpdate(ctx context.Context, request *UpdateInvoiceRequest) (*Invoice, error) {
 	if request.ID == "" {
 		return nil, errors.New("missing invoice id")
 	}
@@ -449,25 +662,10 @@ func (c *Client) InvoiceUpdate(ctx context.Context, request *UpdateInvoiceReques
 
 	req.Header.Add("Accept-Language", "en")
 	req.Header.Add("Content-Type", "application/json")
-	req.Header.Set("User-Agent", c.UserAgent)
-	req.Header.Add("Authorization", c.apiToken)
 
-	res, err := c.HTTPClient.Do(req)
-	if err != nil {
+	invoice := &Invoice{}
+	if err := c.sendRequest(req, invoice); err != nil {
 		return nil, err
 	}
-	defer res.Body.Close()
-
-	if res.StatusCode == 200 {
-		payload, _ := io.ReadAll(res.Body)
-
-		var invoice Invoice
-		err := json.Unmarshal(payload, &invoice)
-		if err != nil {
-			return nil, err
-		}
-
-		return &invoice, nil
-	}
-	return nil, NewTwikeyErrorFromResponse(res)
+	return invoice, nil
 }
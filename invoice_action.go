@@ -0,0 +1,220 @@
+package twikey
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// InvoiceActionRequest is implemented by all actions that can be done on an existing invoice (see Client.InvoiceDoAction)
+type InvoiceActionRequest interface {
+	// actionType returns the type of the action as known by Twikey
+	actionType() string
+	// addParams adds the parameters of the action
+	addParams(params url.Values)
+	// Validate checks the parameters of the action before it is sent
+	Validate() error
+}
+
+// EmailAction (re)sends the invoice to the customer by email
+type EmailAction struct {
+	Template string // Optional custom email template
+	Email    string // Optional email address to use instead of the one of the customer
+}
+
+func (a *EmailAction) actionType() string { return "email" }
+func (a *EmailAction) Validate() error {
+	v := validation{}
+	v.email("Email", a.Email)
+	return v.err()
+}
+func (a *EmailAction) addParams(params url.Values) {
+	addIfExists(params, "template", a.Template)
+	addIfExists(params, "email", a.Email)
+}
+
+// SmsAction sends a link to the invoice to the customer by sms
+type SmsAction struct {
+	Mobile string // Optional mobile number to use instead of the one of the customer
+}
+
+func (a *SmsAction) actionType() string { return "sms" }
+func (a *SmsAction) Validate() error    { return nil }
+func (a *SmsAction) addParams(params url.Values) {
+	addIfExists(params, "mobile", a.Mobile)
+}
+
+// ReminderAction sends a reminder by email
+type ReminderAction struct {
+	Template string // Optional custom reminder template
+}
+
+func (a *ReminderAction) actionType() string { return "reminder" }
+func (a *ReminderAction) Validate() error    { return nil }
+func (a *ReminderAction) addParams(params url.Values) {
+	addIfExists(params, "template", a.Template)
+}
+
+// LetterAction sends the invoice via postal letter
+type LetterAction struct {
+	Registered bool // Send as a registered letter
+}
+
+func (a *LetterAction) actionType() string { return "letter" }
+func (a *LetterAction) Validate() error    { return nil }
+func (a *LetterAction) addParams(params url.Values) {
+	if a.Registered {
+		params.Add("registered", "true")
+	}
+}
+
+// ReofferAction tries to collect the invoice (again) via a recurring mechanism
+type ReofferAction struct {
+	CollectionDate string // Optional requested collection date (yyyy-mm-dd)
+}
+
+func (a *ReofferAction) actionType() string { return "reoffer" }
+func (a *ReofferAction) Validate() error {
+	v := validation{}
+	v.date("CollectionDate", a.CollectionDate)
+	return v.err()
+}
+func (a *ReofferAction) addParams(params url.Values) {
+	addIfExists(params, "reqcolldt", a.CollectionDate)
+}
+
+// PeppolAction sends the invoice via the Peppol network
+type PeppolAction struct{}
+
+func (a *PeppolAction) actionType() string     { return "peppol" }
+func (a *PeppolAction) addParams(_ url.Values) {}
+func (a *PeppolAction) Validate() error        { return nil }
+
+// ManualPaymentAction registers a payment that was received outside of Twikey
+type ManualPaymentAction struct {
+	Method string  // How the invoice was paid (eg. cash, transfer, ..)
+	Date   string  // Date of the payment (yyyy-mm-dd)
+	Amount float64 // Amount paid, by default the full open amount
+}
+
+func (a *ManualPaymentAction) actionType() string { return "manualPayment" }
+func (a *ManualPaymentAction) Validate() error {
+	v := validation{}
+	v.date("Date", a.Date)
+	if a.Amount < 0 {
+		v.fail("Amount", "can't be negative")
+	}
+	return v.err()
+}
+func (a *ManualPaymentAction) addParams(params url.Values) {
+	addIfExists(params, "rsn", a.Method)
+	addIfExists(params, "date", a.Date)
+	if a.Amount != 0 {
+		params.Add("amount", fmt.Sprintf("%.2f", a.Amount))
+	}
+}
+
+// PaymentPlanAction splits the open amount of the invoice in a number of installments
+type PaymentPlanAction struct {
+	Installments int        // Number of installments
+	Recurrence   Recurrence // Time between installments, monthly by default
+	Start        string     // Date of the first installment (yyyy-mm-dd)
+}
+
+func (a *PaymentPlanAction) actionType() string { return "paymentplan" }
+func (a *PaymentPlanAction) Validate() error {
+	v := validation{}
+	if a.Installments <= 0 {
+		v.fail("Installments", "should be positive")
+	}
+	v.oneOf("Recurrence", string(a.Recurrence), recurrences...)
+	v.date("Start", a.Start)
+	return v.err()
+}
+func (a *PaymentPlanAction) addParams(params url.Values) {
+	if a.Installments > 0 {
+		params.Add("installments", strconv.Itoa(a.Installments))
+	}
+	addIfExists(params, "recurrence", string(a.Recurrence))
+	addIfExists(params, "start", a.Start)
+}
+
+// DisputeAction marks the invoice as disputed which halts all dunning
+type DisputeAction struct {
+	Reason string
+}
+
+func (a *DisputeAction) actionType() string { return "dispute" }
+func (a *DisputeAction) Validate() error    { return nil }
+func (a *DisputeAction) addParams(params url.Values) {
+	addIfExists(params, "rsn", a.Reason)
+}
+
+// WriteOffAction writes off (part of) the open amount of the invoice as uncollectable
+type WriteOffAction struct {
+	Reason string
+	Amount float64 // Amount to write off, by default the full open amount
+}
+
+func (a *WriteOffAction) actionType() string { return "writeoff" }
+func (a *WriteOffAction) Validate() error {
+	v := validation{}
+	if a.Amount < 0 {
+		v.fail("Amount", "can't be negative")
+	}
+	return v.err()
+}
+func (a *WriteOffAction) addParams(params url.Values) {
+	addIfExists(params, "rsn", a.Reason)
+	if a.Amount != 0 {
+		params.Add("amount", fmt.Sprintf("%.2f", a.Amount))
+	}
+}
+
+// InvoiceActionResult contains the outcome of an action on an invoice
+type InvoiceActionResult struct {
+	Type     string   `json:"type"`    // Type of the executed action
+	Message  string   `json:"message"` // Optional message describing the result
+	Warnings []string `json:"-"`       // Warnings returned by Twikey (X-Warning header)
+}
+
+func (result *InvoiceActionResult) setWarnings(warnings []string) {
+	result.Warnings = warnings
+}
+
+// InvoiceDoAction executes an action on an existing invoice
+func (c *Client) InvoiceDoAction(ctx context.Context, invoiceIdOrNumber string, action InvoiceActionRequest) (*InvoiceActionResult, error) {
+
+	if invoiceIdOrNumber == "" {
+		return nil, NewTwikeyError("err_invalid_invoice", "An invoice is required", "")
+	}
+	if action == nil {
+		return nil, NewTwikeyError("err_invalid_action", "An action is required", "")
+	}
+
+	if err := c.validate(action); err != nil {
+		return nil, err
+	}
+
+	_url := c.BaseURL + "/creditor/invoice/" + invoiceIdOrNumber + "/action"
+	params := url.Values{}
+	params.Add("type", action.actionType())
+	action.addParams(params)
+
+	c.Debug.Debugf("Action on invoice %s : %s", invoiceIdOrNumber, params.Encode())
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, _url, strings.NewReader(params.Encode()))
+	req.Header.Add("Accept-Language", "en")
+
+	result := &InvoiceActionResult{}
+	if err := c.sendRequest(req, result); err != nil {
+		return nil, err
+	}
+	if result.Type == "" {
+		result.Type = action.actionType()
+	}
+	return result, nil
+}
@@ -0,0 +1,103 @@
+package twikey
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInvoiceDoAction(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AssertEquals(t, http.MethodPost, r.Method)
+		AssertEquals(t, "/creditor/invoice/INV1/action", r.URL.Path)
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		switch r.Form.Get("type") {
+		case "manualPayment":
+			AssertEquals(t, "cash", r.Form.Get("rsn"))
+			AssertEquals(t, "2024-01-15", r.Form.Get("date"))
+			AssertEquals(t, "25.50", r.Form.Get("amount"))
+			w.Header().Add("X-Warning", "Invoice is only partially paid")
+			_, _ = w.Write([]byte(`{"type":"manualPayment","message":"Payment registered"}`))
+		case "reminder":
+			AssertEquals(t, "my-reminder", r.Form.Get("template"))
+			w.WriteHeader(http.StatusNoContent)
+		case "reoffer":
+			AssertEquals(t, "2024-02-01", r.Form.Get("reqcolldt"))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("ApiError", "err_invalid_action")
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+	ctx := context.Background()
+
+	result, err := cl.InvoiceDoAction(ctx, "INV1", &ManualPaymentAction{Method: "cash", Date: "2024-01-15", Amount: 25.50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, "Payment registered", result.Message)
+	AssertEquals(t, 1, len(result.Warnings))
+	AssertEquals(t, "Invoice is only partially paid", result.Warnings[0])
+
+	result, err = cl.InvoiceDoAction(ctx, "INV1", &ReminderAction{Template: "my-reminder"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, "reminder", result.Type)
+	AssertEquals(t, 0, len(result.Warnings))
+
+	if _, err = cl.InvoiceDoAction(ctx, "INV1", &ReofferAction{CollectionDate: "2024-02-01"}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = cl.InvoiceDoAction(ctx, "INV1", &WriteOffAction{Reason: "bankrupt"})
+	if twikeyError, ok := err.(*TwikeyError); !ok || twikeyError.Code != "err_invalid_action" {
+		t.Fatalf("Expected the error of Twikey, got %v", err)
+	}
+
+	if err = cl.InvoiceAction(ctx, "INV1", InvoiceAction(99)); err == nil {
+		t.Error("Expected an unknown action to be refused")
+	}
+}
+
+func TestInvoiceDoActionValidation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Expected no request for an invalid action, got %s", r.URL)
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		action InvoiceActionRequest
+		fields []string
+	}{
+		{"plan without installments", &PaymentPlanAction{Recurrence: "5d", Start: "01/02/2024"}, []string{"Installments", "Recurrence", "Start"}},
+		{"negative payment", &ManualPaymentAction{Method: "cash", Date: "15/01/2024", Amount: -10}, []string{"Date", "Amount"}},
+		{"negative write off", &WriteOffAction{Amount: -1}, []string{"Amount"}},
+		{"reoffer on invalid date", &ReofferAction{CollectionDate: "tomorrow"}, []string{"CollectionDate"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := cl.InvoiceDoAction(ctx, "INV1", test.action)
+			verr, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("Expected a validation error, got %v", err)
+			}
+			if len(verr.Fields) != len(test.fields) {
+				t.Errorf("Expected %d invalid fields, got %v", len(test.fields), verr)
+			}
+			for _, field := range test.fields {
+				if !verr.HasField(field) {
+					t.Errorf("Expected %s to be invalid, got %v", field, verr)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package twikey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// InvoiceBatchItem is a single invoice of a batch
+type InvoiceBatchItem struct {
+	// Key identifies the item within the batch (eg. the file name). It is used in the journal and
+	// to derive the idempotency key, so it should be the same when the batch is run again.
+	Key     string
+	Request *NewInvoiceRequest
+}
+
+// InvoiceIterator provides the items of a batch one by one, Next returns io.EOF when no more items are available.
+// When an item can't be read an error can be returned along with the item (having at least its Key) to report
+// the failure and continue with the next one. An error without an item stops the batch.
+type InvoiceIterator interface {
+	Next() (*InvoiceBatchItem, error)
+}
+
+type sliceIterator struct {
+	requests []*NewInvoiceRequest
+	pos      int
+}
+
+func (it *sliceIterator) Next() (*InvoiceBatchItem, error) {
+	if it.pos >= len(it.requests) {
+		return nil, io.EOF
+	}
+	request := it.requests[it.pos]
+	it.pos++
+	key := request.Id
+	if key == "" && request.Invoice != nil {
+		key = request.Invoice.Number
+	}
+	if key == "" {
+		key = request.Reference
+	}
+	return &InvoiceBatchItem{Key: key, Request: request}, nil
+}
+
+// InvoicesFromSlice iterates over the passed requests, the key of every item is the id, the number
+// of the invoice or the reference (whichever is found first).
+func InvoicesFromSlice(requests []*NewInvoiceRequest) InvoiceIterator {
+	return &sliceIterator{requests: requests}
+}
+
+type directoryIterator struct {
+	dir   string
+	files []string
+	pos   int
+}
+
+func (it *directoryIterator) Next() (*InvoiceBatchItem, error) {
+	if it.pos >= len(it.files) {
+		return nil, io.EOF
+	}
+	name := it.files[it.pos]
+	it.pos++
+	item := &InvoiceBatchItem{Key: name}
+	content, err := os.ReadFile(filepath.Join(it.dir, name))
+	if err != nil {
+		return item, err
+	}
+	if strings.EqualFold(filepath.Ext(name), ".xml") {
+		item.Request = &NewInvoiceRequest{UblBytes: content}
+		return item, nil
+	}
+	var invoice Invoice
+	if err := json.Unmarshal(content, &invoice); err != nil {
+		return item, err
+	}
+	item.Request = &NewInvoiceRequest{Invoice: &invoice}
+	return item, nil
+}
+
+// InvoicesFromDirectory iterates over all invoices in a directory (in alphabetical order), json files contain
+// an Invoice while xml files contain a UBL document. Other files are ignored.
+func InvoicesFromDirectory(dir string) (InvoiceIterator, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if !entry.IsDir() && (ext == ".json" || ext == ".xml") {
+			files = append(files, entry.Name())
+		}
+	}
+	sort.Strings(files)
+	return &directoryIterator{dir: dir, files: files}, nil
+}
+
+// InvoiceBatchResult is the outcome of a single item of the batch
+type InvoiceBatchResult struct {
+	Key     string
+	Invoice *Invoice // the created invoice (nil when skipped or failed)
+	Skipped bool     // true if the journal shows the item was accepted in a previous run
+	Err     error
+}
+
+// InvoiceBatch uploads many invoices with bounded concurrency, failures of single invoices don't stop the batch.
+type InvoiceBatch struct {
+	client *Client
+	// Concurrency is the number of invoices sent at the same time (default 4)
+	Concurrency int
+	// RequestsPerSecond limits the rate at which invoices are sent, 0 means no limit
+	RequestsPerSecond float64
+	// JournalPath is the file in which the accepted items are recorded, running the batch again with
+	// the same journal skips those items. When empty no journal is kept.
+	JournalPath string
+}
+
+// NewInvoiceBatch creates a batch uploader using this client
+func (c *Client) NewInvoiceBatch() *InvoiceBatch {
+	return &InvoiceBatch{
+		client:      c,
+		Concurrency: 4,
+	}
+}
+
+// Run sends all invoices of the iterator and streams the result of every item over the returned channel, which
+// is closed when all items are handled or the context is cancelled. The channel must be drained by the caller.
+// Items without an IdempotencyKey get one derived from their key and content so a retry never creates duplicates.
+func (b *InvoiceBatch) Run(ctx context.Context, items InvoiceIterator) (<-chan *InvoiceBatchResult, error) {
+	results := make(chan *InvoiceBatchResult)
+	next := func() (*batchJob, error) {
+		item, err := items.Next()
+		if item == nil {
+			return nil, err
+		}
+		return &batchJob{key: item.Key, send: func(ctx context.Context) batchOutcome {
+			return b.upload(ctx, item)
+		}}, err
+	}
+	emit := func(outcome batchOutcome) {
+		result := &InvoiceBatchResult{Key: outcome.key, Skipped: outcome.skipped, Err: outcome.err}
+		result.Invoice, _ = outcome.created.(*Invoice)
+		select {
+		case results <- result:
+		case <-ctx.Done():
+		}
+	}
+	settings := batchSettings{concurrency: b.Concurrency, requestsPerSecond: b.RequestsPerSecond, journalPath: b.JournalPath}
+	if err := runBatch(ctx, settings, next, emit, func() { close(results) }); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (b *InvoiceBatch) upload(ctx context.Context, item *InvoiceBatchItem) batchOutcome {
+	if item.Request == nil {
+		return batchOutcome{err: errors.New("no invoice for " + item.Key)}
+	}
+	if item.Request.IdempotencyKey == "" {
+		payload := item.Request.UblBytes
+		if item.Request.Invoice != nil {
+			payload, _ = json.Marshal(item.Request.Invoice)
+		}
+		item.Request.IdempotencyKey = DerivedIdempotencyKey(item.Key, payload)
+	}
+	invoice, err := b.client.InvoiceAdd(ctx, item.Request)
+	if err != nil {
+		return batchOutcome{err: err}
+	}
+	return batchOutcome{id: invoice.Id, created: invoice}
+}
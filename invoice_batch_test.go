@@ -0,0 +1,132 @@
+package twikey
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestInvoiceBatch(t *testing.T) {
+	var mutex sync.Mutex
+	calls := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Idempotency-Key") == "" {
+			t.Error("Expected an idempotency key")
+		}
+		var invoice Invoice
+		if r.Header.Get("Content-Type") == "application/xml" {
+			invoice.Number = r.Header.Get("X-INVOICE-ID")
+		} else if err := json.NewDecoder(r.Body).Decode(&invoice); err != nil {
+			t.Error(err)
+		}
+		mutex.Lock()
+		calls[invoice.Number]++
+		mutex.Unlock()
+		if invoice.Number == "INV3" {
+			w.Header().Set("ApiError", "err_invalid_customer")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		invoice.Id = "id-" + invoice.Number
+		_ = json.NewEncoder(w).Encode(invoice)
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+
+	dir := t.TempDir()
+	for _, number := range []string{"INV1", "INV2", "INV3", "INV4"} {
+		invoice := Invoice{Number: number, Date: "2024-01-01", Duedate: "2024-02-01", Amount: 10, Customer: &Customer{CustomerNumber: "cst1"}}
+		content, _ := json.Marshal(invoice)
+		if err := os.WriteFile(filepath.Join(dir, number+".json"), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = os.WriteFile(filepath.Join(dir, "INV5.xml"), []byte("<Invoice/>"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "README.txt"), []byte("ignored"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0644)
+
+	journal := filepath.Join(t.TempDir(), "journal.txt")
+	run := func() map[string]*InvoiceBatchResult {
+		items, err := InvoicesFromDirectory(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		batch := cl.NewInvoiceBatch()
+		batch.Concurrency = 2
+		batch.RequestsPerSecond = 1000
+		batch.JournalPath = journal
+		results, err := batch.Run(context.Background(), items)
+		if err != nil {
+			t.Fatal(err)
+		}
+		byKey := map[string]*InvoiceBatchResult{}
+		for result := range results {
+			byKey[result.Key] = result
+		}
+		return byKey
+	}
+
+	first := run()
+	AssertEquals(t, 6, len(first))
+	AssertEquals(t, "id-INV1", first["INV1.json"].Invoice.Id)
+	if first["INV3.json"].Err == nil || first["broken.json"].Err == nil {
+		t.Error("Expected the failures to be reported")
+	}
+	if first["INV5.xml"].Err != nil {
+		t.Error(first["INV5.xml"].Err)
+	}
+
+	second := run()
+	AssertEquals(t, true, second["INV1.json"].Skipped)
+	AssertEquals(t, true, second["INV5.xml"].Skipped)
+	AssertEquals(t, false, second["INV3.json"].Skipped)
+	AssertEquals(t, 1, calls["INV1"])
+	AssertEquals(t, 2, calls["INV3"])
+}
+
+func TestInvoicesFromSlice(t *testing.T) {
+	items := InvoicesFromSlice([]*NewInvoiceRequest{
+		{Invoice: &Invoice{Number: "INV1"}},
+		{Id: "abc", Invoice: &Invoice{Number: "INV2"}},
+	})
+	first, _ := items.Next()
+	second, _ := items.Next()
+	_, err := items.Next()
+	AssertEquals(t, "INV1", first.Key)
+	AssertEquals(t, "abc", second.Key)
+	AssertEquals(t, true, err != nil)
+}
+
+// invoiceIteratorFunc allows a function to be used as InvoiceIterator
+type invoiceIteratorFunc func() (*InvoiceBatchItem, error)
+
+func (f invoiceIteratorFunc) Next() (*InvoiceBatchItem, error) {
+	return f()
+}
+
+func TestInvoiceBatchNilItem(t *testing.T) {
+	calls := 0
+	items := invoiceIteratorFunc(func() (*InvoiceBatchItem, error) {
+		calls++
+		if calls > 1 {
+			return nil, io.EOF
+		}
+		return nil, nil
+	})
+	results, err := NewClient("TEST_API_KEY").NewInvoiceBatch().Run(context.Background(), items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all []*InvoiceBatchResult
+	for result := range results {
+		all = append(all, result)
+	}
+	AssertEquals(t, 1, len(all))
+	AssertEquals(t, true, all[0].Err != nil)
+}
@@ -0,0 +1,202 @@
+package twikey
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// VAT categories as defined in EN 16931 (UNCL5305)
+const (
+	VatCategoryStandard       = "S"  // Standard rate
+	VatCategoryZero           = "Z"  // Zero rated goods
+	VatCategoryExempt         = "E"  // Exempt from tax
+	VatCategoryReverseCharge  = "AE" // VAT reverse charge
+	VatCategoryIntraCommunity = "K"  // Intra-community supply
+	VatCategoryExport         = "G"  // Export outside the EU
+	VatCategoryOutOfScope     = "O"  // Services outside scope of tax
+)
+
+// InvoiceLine is a single item of an invoice, all amounts are excluding VAT
+type InvoiceLine struct {
+	Code           string  `json:"code,omitempty"`           // Article code
+	Description    string  `json:"description"`              // Description of the item
+	Quantity       float64 `json:"quantity"`                 // Number of items
+	Uom            string  `json:"uom,omitempty"`            // Unit of measure (eg. C62 for pieces, HUR for hours)
+	UnitPrice      float64 `json:"unitprice"`                // Price of a single item excluding VAT
+	VatCategory    string  `json:"vatcode,omitempty"`        // VAT category (see VatCategoryStandard, ..), standard by default
+	VatRate        float64 `json:"vatrate"`                  // VAT percentage eg. 21
+	Discount       float64 `json:"discount,omitempty"`       // Discount percentage on the line
+	DiscountAmount float64 `json:"discountAmount,omitempty"` // Fixed discount on the line (after the percentage)
+	LineTotal      float64 `json:"total"`                    // Total of the line excluding VAT (see Invoice.CalculateTotals)
+}
+
+// VatSubtotal groups the taxable amount and VAT of all lines with the same category and rate
+type VatSubtotal struct {
+	Category      string  `json:"vatcode"`
+	Rate          float64 `json:"vatrate"`
+	TaxableAmount float64 `json:"taxable"`
+	VatAmount     float64 `json:"vat"`
+}
+
+// category returns the VAT category of the line, defaulting to the standard rate
+func (line *InvoiceLine) category() string {
+	if line.VatCategory == "" {
+		return VatCategoryStandard
+	}
+	return line.VatCategory
+}
+
+// NetAmount computes the total of the line excluding VAT after discounts
+func (line *InvoiceLine) NetAmount() float64 {
+	return roundAmount(line.Quantity*line.UnitPrice*(1-line.Discount/100) - line.DiscountAmount)
+}
+
+// CalculateTotals (re)computes the line totals, the VAT subtotals, the net and VAT amount and the
+// total amount (including VAT) of an invoice with lines. VAT is computed per subtotal as required by EN 16931.
+func (inv *Invoice) CalculateTotals() {
+	inv.VatSubtotals = nil
+	inv.NetAmount = 0
+	inv.VatAmount = 0
+	if len(inv.Lines) == 0 {
+		return
+	}
+	for i := range inv.Lines {
+		inv.Lines[i].LineTotal = inv.Lines[i].NetAmount()
+	}
+	inv.VatSubtotals = vatSubtotals(inv.Lines)
+	for _, subtotal := range inv.VatSubtotals {
+		inv.NetAmount += subtotal.TaxableAmount
+		inv.VatAmount += subtotal.VatAmount
+	}
+	inv.NetAmount = roundAmount(inv.NetAmount)
+	inv.VatAmount = roundAmount(inv.VatAmount)
+	inv.Amount = roundAmount(inv.NetAmount + inv.VatAmount)
+}
+
+// fillTotals computes the totals of an invoice with lines that were left empty, the ones that were passed are
+// kept so they can still be checked against the lines
+func (inv *Invoice) fillTotals() {
+	if len(inv.Lines) == 0 {
+		return
+	}
+	expected := Invoice{Lines: make([]InvoiceLine, len(inv.Lines))}
+	copy(expected.Lines, inv.Lines)
+	expected.CalculateTotals()
+
+	for i := range inv.Lines {
+		if inv.Lines[i].LineTotal == 0 {
+			inv.Lines[i].LineTotal = expected.Lines[i].LineTotal
+		}
+	}
+	if inv.VatSubtotals == nil {
+		inv.VatSubtotals = expected.VatSubtotals
+	}
+	if inv.NetAmount == 0 {
+		inv.NetAmount = expected.NetAmount
+	}
+	if inv.VatAmount == 0 {
+		inv.VatAmount = expected.VatAmount
+	}
+	if inv.Amount == 0 {
+		inv.Amount = expected.Amount
+	}
+}
+
+// validateTotals verifies that the totals of an invoice with lines are consistent with its lines
+func (inv *Invoice) validateTotals(v *validation, prefix string) {
+	if len(inv.Lines) == 0 {
+		return
+	}
+
+	expected := Invoice{Lines: make([]InvoiceLine, len(inv.Lines))}
+	copy(expected.Lines, inv.Lines)
+	expected.CalculateTotals()
+
+	for i, line := range inv.Lines {
+		field := fmt.Sprintf("%sLines[%d].", prefix, i)
+		if line.Description == "" {
+			v.fail(field+"Description", "is required")
+		}
+		if line.Quantity == 0 {
+			v.fail(field+"Quantity", "is required")
+		}
+		if line.VatRate < 0 || line.VatRate > 100 {
+			v.fail(field+"VatRate", "should be a percentage")
+		}
+		if line.Discount < 0 || line.Discount > 100 {
+			v.fail(field+"Discount", "should be a percentage")
+		}
+		if line.LineTotal != 0 && !sameAmount(line.LineTotal, expected.Lines[i].LineTotal) {
+			v.fail(field+"LineTotal", fmt.Sprintf("doesn't match quantity and price (expected %.2f)", expected.Lines[i].LineTotal))
+		}
+	}
+	if inv.VatSubtotals != nil && !sameSubtotals(inv.VatSubtotals, expected.VatSubtotals) {
+		v.fail(prefix+"VatSubtotals", "don't match the lines")
+	}
+	if inv.NetAmount != 0 && !sameAmount(inv.NetAmount, expected.NetAmount) {
+		v.fail(prefix+"NetAmount", fmt.Sprintf("doesn't match the lines (expected %.2f)", expected.NetAmount))
+	}
+	if inv.VatAmount != 0 && !sameAmount(inv.VatAmount, expected.VatAmount) {
+		v.fail(prefix+"VatAmount", fmt.Sprintf("doesn't match the lines (expected %.2f)", expected.VatAmount))
+	}
+	if !sameAmount(inv.Amount, expected.Amount) {
+		v.fail(prefix+"Amount", fmt.Sprintf("doesn't match the lines (expected %.2f)", expected.Amount))
+	}
+}
+
+func vatSubtotals(lines []InvoiceLine) []VatSubtotal {
+	var subtotals []VatSubtotal
+	index := map[string]int{}
+	for _, line := range lines {
+		key := fmt.Sprintf("%s/%.2f", line.category(), line.VatRate)
+		i, found := index[key]
+		if !found {
+			i = len(subtotals)
+			index[key] = i
+			subtotals = append(subtotals, VatSubtotal{Category: line.category(), Rate: line.VatRate})
+		}
+		subtotals[i].TaxableAmount += line.NetAmount()
+	}
+	for i := range subtotals {
+		subtotals[i].TaxableAmount = roundAmount(subtotals[i].TaxableAmount)
+		subtotals[i].VatAmount = roundAmount(subtotals[i].TaxableAmount * subtotals[i].Rate / 100)
+	}
+	sortSubtotals(subtotals)
+	return subtotals
+}
+
+func sortSubtotals(subtotals []VatSubtotal) {
+	sort.Slice(subtotals, func(i, j int) bool {
+		if subtotals[i].Category != subtotals[j].Category {
+			return subtotals[i].Category < subtotals[j].Category
+		}
+		return subtotals[i].Rate < subtotals[j].Rate
+	})
+}
+
+func sameSubtotals(actual []VatSubtotal, expected []VatSubtotal) bool {
+	if len(actual) != len(expected) {
+		return false
+	}
+	sorted := make([]VatSubtotal, len(actual))
+	copy(sorted, actual)
+	sortSubtotals(sorted)
+	for i := range sorted {
+		if sorted[i].Category != expected[i].Category || !sameAmount(sorted[i].Rate, expected[i].Rate) ||
+			!sameAmount(sorted[i].TaxableAmount, expected[i].TaxableAmount) || !sameAmount(sorted[i].VatAmount, expected[i].VatAmount) {
+			return false
+		}
+	}
+	return true
+}
+
+// roundAmount rounds to cents (half away from zero)
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// sameAmount compares 2 amounts up to the cent
+func sameAmount(a float64, b float64) bool {
+	return math.Abs(a-b) < 0.005
+}
@@ -0,0 +1,98 @@
+package twikey
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newInvoiceWithLines() *Invoice {
+	return &Invoice{
+		Number:  "INV1",
+		Date:    "2024-01-01",
+		Duedate: "2024-02-01",
+		Customer: &Customer{
+			CustomerNumber: "cst1",
+		},
+		Lines: []InvoiceLine{
+			{Description: "Consultancy", Quantity: 3, UnitPrice: 100, VatRate: 21},
+			{Description: "Travel", Quantity: 1, UnitPrice: 50.50, VatRate: 21, Discount: 10},
+			{Description: "Book", Quantity: 2, UnitPrice: 19.99, VatRate: 6, DiscountAmount: 5},
+		},
+	}
+}
+
+func TestInvoiceCalculateTotals(t *testing.T) {
+	invoice := newInvoiceWithLines()
+	invoice.CalculateTotals()
+
+	AssertEquals(t, 300.0, invoice.Lines[0].LineTotal)
+	AssertEquals(t, 45.45, invoice.Lines[1].LineTotal)
+	AssertEquals(t, 34.98, invoice.Lines[2].LineTotal)
+	AssertEquals(t, 2, len(invoice.VatSubtotals))
+	AssertEquals(t, 6.0, invoice.VatSubtotals[0].Rate)
+	AssertEquals(t, 2.10, invoice.VatSubtotals[0].VatAmount)
+	AssertEquals(t, 345.45, invoice.VatSubtotals[1].TaxableAmount)
+	AssertEquals(t, 72.54, invoice.VatSubtotals[1].VatAmount)
+	AssertEquals(t, 380.43, invoice.NetAmount)
+	AssertEquals(t, 74.64, invoice.VatAmount)
+	AssertEquals(t, 455.07, invoice.Amount)
+
+	if err := (&NewInvoiceRequest{Invoice: invoice}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInvoiceTotalsValidation(t *testing.T) {
+	invoice := newInvoiceWithLines()
+	invoice.Amount = 450
+	invoice.VatSubtotals = []VatSubtotal{{Category: VatCategoryStandard, Rate: 21, TaxableAmount: 380.43, VatAmount: 79.89}}
+
+	err := (&NewInvoiceRequest{Invoice: invoice}).Validate()
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+	AssertEquals(t, true, verr.HasField("Invoice.Amount"))
+	AssertEquals(t, true, verr.HasField("Invoice.VatSubtotals"))
+}
+
+func TestInvoiceLinesDecoding(t *testing.T) {
+	payload := `{"id":"a1","number":"INV1","amount":121.0,"netAmount":100.0,"vatAmount":21.0,
+		"lines":[{"description":"Consultancy","quantity":1,"unitprice":100,"vatcode":"S","vatrate":21,"total":100}],
+		"vatSubtotals":[{"vatcode":"S","vatrate":21,"taxable":100,"vat":21}]}`
+	var invoice Invoice
+	if err := json.Unmarshal([]byte(payload), &invoice); err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 1, len(invoice.Lines))
+	AssertEquals(t, "Consultancy", invoice.Lines[0].Description)
+	AssertEquals(t, 21.0, invoice.VatSubtotals[0].VatAmount)
+	AssertEquals(t, 100.0, invoice.NetAmount)
+}
+
+func TestInvoiceAddFillsTotals(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var invoice Invoice
+		if err := json.NewDecoder(r.Body).Decode(&invoice); err != nil {
+			t.Fatal(err)
+		}
+		AssertEquals(t, 455.07, invoice.Amount)
+		AssertEquals(t, 380.43, invoice.NetAmount)
+		AssertEquals(t, 74.64, invoice.VatAmount)
+		AssertEquals(t, 2, len(invoice.VatSubtotals))
+		AssertEquals(t, 45.45, invoice.Lines[1].LineTotal)
+		_, _ = w.Write([]byte(`{"id":"a1","number":"INV1","amount":455.07}`))
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+	WithoutValidation()(cl)
+
+	invoice, err := cl.InvoiceAdd(context.Background(), &NewInvoiceRequest{Invoice: newInvoiceWithLines()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, "a1", invoice.Id)
+}
@@ -2,7 +2,10 @@ package twikey
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
@@ -163,3 +166,121 @@ func TestInvoiceUpdateWithInvalidRequest(t *testing.T) {
 		}
 	})
 }
+
+func TestInvoiceQuery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AssertEquals(t, http.MethodGet, r.Method)
+		AssertEquals(t, "/creditor/invoice/query", r.URL.Path)
+		AssertEquals(t, "PENDING", r.URL.Query().Get("state"))
+		AssertEquals(t, "cst1", r.URL.Query().Get("customerNumber"))
+		AssertEquals(t, "2024-01-31", r.URL.Query().Get("toDueDate"))
+
+		if r.URL.Query().Get("page") == "" {
+			_, _ = w.Write([]byte(`{
+  "Invoices": [
+    {"id": "a1", "number": "INV1", "state": "PENDING", "amount": 10.0, "duedate": "2024-01-15"},
+    {"id": "a2", "number": "INV2", "state": "PENDING", "amount": 20.0, "duedate": "2024-01-20"}
+  ],
+  "_links": {"next": "/creditor/invoice/query?page=1"}
+}`))
+		} else {
+			AssertEquals(t, "1", r.URL.Query().Get("page"))
+			_, _ = w.Write([]byte(`{"Invoices": [{"id": "a3", "number": "INV3", "state": "PENDING", "amount": 30.0}], "_links": {}}`))
+		}
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+	ctx := context.Background()
+
+	request := &InvoiceQueryRequest{State: "PENDING", CustomerNumber: "cst1", ToDueDate: "2024-01-31"}
+	first, err := cl.InvoiceQuery(ctx, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 2, len(first.Invoices))
+	AssertEquals(t, true, first.HasNext())
+
+	total := 0.0
+	err = cl.InvoiceQueryAll(ctx, request, func(invoice *Invoice) error {
+		total += invoice.Amount
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 60.0, total)
+	AssertEquals(t, 0, request.Page)
+
+	if _, err = cl.InvoiceQuery(ctx, &InvoiceQueryRequest{FromDate: "01-01-2024"}); err == nil {
+		t.Error("Expected an invalid date to be refused")
+	}
+}
+
+func TestCreditNoteNew(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/creditor/invoice/INV1":
+			AssertEquals(t, "customer", r.URL.Query().Get("include"))
+			_, _ = w.Write([]byte(`{"id":"a1","number":"INV1","state":"PAID","amount":100.0,"customer":{"customerNumber":"cst1"}}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/creditor/invoice/INV0":
+			_, _ = w.Write([]byte(`{"id":"a0","number":"INV0","state":"PAID","amount":0,"customer":{"customerNumber":"cst1"}}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/creditor/invoice":
+			AssertEquals(t, "true", r.Header.Get("X-REFUND"))
+			var invoice Invoice
+			if err := json.NewDecoder(r.Body).Decode(&invoice); err != nil {
+				t.Fatal(err)
+			}
+			AssertEquals(t, "INV1", invoice.RelatedInvoice)
+			AssertEquals(t, -40.0, invoice.Amount)
+			AssertEquals(t, "cst1", invoice.Customer.CustomerNumber)
+			invoice.Id = "cn1"
+			_ = json.NewEncoder(w).Encode(invoice)
+		default:
+			t.Errorf("Unexpected call %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+	ctx := context.Background()
+
+	_, err := cl.CreditNoteNew(ctx, &CreditNoteRequest{Invoice: "INV1", Number: "CN1", Amount: 140})
+	if verr, ok := err.(*ValidationError); !ok || !verr.HasField("Amount") {
+		t.Fatalf("Expected the amount to exceed the invoice, got %v", err)
+	}
+
+	_, err = cl.CreditNoteNew(ctx, &CreditNoteRequest{Invoice: "INV0", Number: "CN0", Amount: 10})
+	if verr, ok := err.(*ValidationError); !ok || !verr.HasField("Invoice") || verr.Fields[0].Message != "has no amount to credit" {
+		t.Fatalf("Expected an invoice without amount to be refused, got %v", err)
+	}
+
+	creditNote, err := cl.CreditNoteNew(ctx, &CreditNoteRequest{Invoice: "INV1", Number: "CN1", Amount: 40, Refund: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, "cn1", creditNote.Id)
+	AssertEquals(t, "CN1", creditNote.Number)
+}
+
+func TestInvoiceCancelAndDelete(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			AssertEquals(t, "/creditor/invoice/INV1/action", r.URL.Path)
+			_ = r.ParseForm()
+			AssertEquals(t, "cancel", r.Form.Get("type"))
+			AssertEquals(t, "duplicate", r.Form.Get("rsn"))
+		} else {
+			AssertEquals(t, http.MethodDelete, r.Method)
+			AssertEquals(t, "/creditor/invoice/INV2", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+
+	if err := cl.InvoiceCancel(context.Background(), "INV1", "duplicate"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.InvoiceDelete(context.Background(), "INV2"); err != nil {
+		t.Fatal(err)
+	}
+}
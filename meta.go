@@ -0,0 +1,78 @@
+package twikey
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ResponseMeta contains the metadata of a response from Twikey. It is captured by passing a context created with
+// WithResponseMeta to any call. When a call needs multiple requests (eg. feeds) the metadata of the last response
+// is kept, except for the warnings which are collected over all responses. Use a separate ResponseMeta per
+// goroutine as it is not safe for concurrent use.
+type ResponseMeta struct {
+	StatusCode         int
+	Warnings           []string    // X-Warning headers, eg. when an invoice was accepted with remarks
+	RequestId          string      // Identifier of the request at Twikey (useful when contacting support)
+	RateLimitLimit     int         // Maximum number of requests in the current window (-1 if unknown)
+	RateLimitRemaining int         // Remaining number of requests in the current window (-1 if unknown)
+	RateLimitReset     int64       // Seconds until the current window resets (-1 if unknown)
+	IdempotencyKey     string      // Idempotency-Key sent with the request (if any)
+	IdempotentReplay   bool        // true if Twikey replayed the response of a previous request with the same key
+	ServerTime         time.Time   // Date of the response according to Twikey
+	Header             http.Header // All headers of the response
+}
+
+type responseMetaKey struct{}
+
+// WithResponseMeta returns a context that makes every call using it store the metadata of its response in meta
+func WithResponseMeta(ctx context.Context, meta *ResponseMeta) context.Context {
+	return context.WithValue(ctx, responseMetaKey{}, meta)
+}
+
+// HasWarnings returns true when Twikey returned at least one warning
+func (meta *ResponseMeta) HasWarnings() bool {
+	return len(meta.Warnings) != 0
+}
+
+// warnedResult is implemented by the results that expose the warnings of the response
+type warnedResult interface {
+	setWarnings(warnings []string)
+}
+
+// do executes the request and records the metadata of the response when requested via the context
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return res, err
+	}
+	if meta, ok := req.Context().Value(responseMetaKey{}).(*ResponseMeta); ok && meta != nil {
+		meta.record(req, res)
+	}
+	if warnings := res.Header.Values("X-Warning"); len(warnings) != 0 {
+		c.Debug.Debugf("Warning for %s %s : %s", req.Method, req.URL.Path, warnings)
+	}
+	return res, nil
+}
+
+func (meta *ResponseMeta) record(req *http.Request, res *http.Response) {
+	meta.StatusCode = res.StatusCode
+	meta.Warnings = append(meta.Warnings, res.Header.Values("X-Warning")...)
+	meta.RequestId = res.Header.Get("X-Request-Id")
+	meta.RateLimitLimit = headerAsInt(res.Header, "X-RateLimit-Limit")
+	meta.RateLimitRemaining = headerAsInt(res.Header, "X-RateLimit-Remaining")
+	meta.RateLimitReset = int64(headerAsInt(res.Header, "X-RateLimit-Reset"))
+	meta.IdempotencyKey = req.Header.Get("Idempotency-Key")
+	meta.IdempotentReplay = isReplayed(res)
+	meta.ServerTime, _ = http.ParseTime(res.Header.Get("Date"))
+	meta.Header = res.Header
+}
+
+func headerAsInt(header http.Header, key string) int {
+	value, err := strconv.Atoi(header.Get(key))
+	if err != nil {
+		return -1
+	}
+	return value
+}
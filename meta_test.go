@@ -0,0 +1,52 @@
+package twikey
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestResponseMeta(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("X-Warning", "Customer has no email")
+		w.Header().Set("X-Request-Id", "req-123")
+		w.Header().Set("X-RateLimit-Limit", "100")
+		w.Header().Set("X-RateLimit-Remaining", "42")
+		w.Header().Set("X-RateLimit-Reset", "30")
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.Header().Set("Date", "Mon, 15 Jan 2024 10:00:00 GMT")
+		_, _ = w.Write([]byte(`{"id":"a1","number":"INV1","amount":10}`))
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+
+	var meta ResponseMeta
+	ctx := WithResponseMeta(context.Background(), &meta)
+	_, err := cl.InvoiceAdd(ctx, &NewInvoiceRequest{
+		IdempotencyKey: "key-1",
+		Invoice:        &Invoice{Number: "INV1", Date: "2024-01-01", Duedate: "2024-02-01", Amount: 10, Customer: &Customer{CustomerNumber: "cst1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	AssertEquals(t, http.StatusOK, meta.StatusCode)
+	AssertEquals(t, true, meta.HasWarnings())
+	AssertEquals(t, "Customer has no email", meta.Warnings[0])
+	AssertEquals(t, "req-123", meta.RequestId)
+	AssertEquals(t, 100, meta.RateLimitLimit)
+	AssertEquals(t, 42, meta.RateLimitRemaining)
+	AssertEquals(t, int64(30), meta.RateLimitReset)
+	AssertEquals(t, "key-1", meta.IdempotencyKey)
+	AssertEquals(t, true, meta.IdempotentReplay)
+	AssertEquals(t, time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC), meta.ServerTime.UTC())
+
+	// calls via sendRequest are captured as well
+	var subscriptionMeta ResponseMeta
+	if err = cl.SubscriptionCancel(WithResponseMeta(context.Background(), &subscriptionMeta), "MNDT1", "REF1"); err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, "req-123", subscriptionMeta.RequestId)
+}
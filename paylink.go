@@ -2,11 +2,10 @@ package twikey
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 )
 
@@ -44,15 +43,76 @@ func (request *PaylinkRequest) Add(key string, value string) {
 	request.Extra[key] = value
 }
 
+// Validate checks the request locally and returns a ValidationError listing all invalid fields
+func (request *PaylinkRequest) Validate() error {
+	v := validation{}
+	if request.Invoice == "" && request.Txref == "" {
+		v.required("Title", request.Title)
+		if request.Amount <= 0 {
+			v.fail("Amount", "should be positive")
+		}
+	} else if request.Amount < 0 {
+		v.fail("Amount", "should be positive")
+	}
+	v.email("Email", request.Email)
+	v.isoCode("Language", request.Language)
+	v.isoCode("Country", request.Country)
+	v.date("Expiry", request.Expiry)
+	if request.RedirectUrl != "" && !strings.HasPrefix(request.RedirectUrl, "http://") && !strings.HasPrefix(request.RedirectUrl, "https://") {
+		v.fail("RedirectUrl", "should start with http:// or https://")
+	}
+	v.oneOf("SendInvite", request.SendInvite, "email", "sms")
+	if request.SendInvite == "email" {
+		v.required("Email", request.Email)
+	} else if request.SendInvite == "sms" {
+		v.required("Mobile", request.Mobile)
+	}
+	return v.err()
+}
+
 // Paylink is the response receiving from Twikey upon a request
 type Paylink struct {
-	Id     int64   `json:"id,omitempty"`
-	Seq    int64   `json:"seq,omitempty"`
-	Amount float64 `json:"amount,omitempty"`
-	Msg    string  `json:"msg,omitempty"`
-	Ref    string  `json:"ref,omitempty"`
-	State  string  `json:"state,omitempty"`
-	Url    string  `json:"url,omitempty"`
+	Id       int64     `json:"id,omitempty"`
+	Seq      int64     `json:"seq,omitempty"`
+	Amount   float64   `json:"amount,omitempty"`
+	Msg      string    `json:"msg,omitempty"`
+	Ref      string    `json:"ref,omitempty"`
+	State    string    `json:"state,omitempty"`
+	Url      string    `json:"url,omitempty"`
+	Customer *Customer `json:"customer,omitempty"`
+	Expiry   string    `json:"expiry,omitempty"`   // Date after which the link can no longer be paid
+	Method   string    `json:"method,omitempty"`   // Payment method used (or imposed) eg. bancontact, ideal, visa
+	PaidDate string    `json:"paidDate,omitempty"` // Date on which the link was paid
+	Invoice  string    `json:"invoice,omitempty"`  // Number of the invoice paid by the link
+	Txref    string    `json:"txref,omitempty"`    // References of the transactions paid by the link
+
+	IdempotencyKey   string `json:"-"` // key used when creating the paylink
+	IdempotentReplay bool   `json:"-"` // true if Twikey returned the result of a previous call with the same key
+}
+
+// Paylink states
+const (
+	PaylinkStateCreated  = "created"  // Link was created but not yet opened
+	PaylinkStateStarted  = "started"  // Customer started the payment
+	PaylinkStatePending  = "pending"  // Payment is waiting for confirmation of the PSP
+	PaylinkStatePaid     = "paid"     // Payment succeeded
+	PaylinkStateDeclined = "declined" // Payment was refused
+	PaylinkStateExpired  = "expired"  // Link expired or was cancelled
+)
+
+// IsPaid convenience method
+func (paylink *Paylink) IsPaid() bool {
+	return paylink.State == PaylinkStatePaid
+}
+
+// IsFinal returns true if the state of the link will no longer change (apart from a refund)
+func (paylink *Paylink) IsFinal() bool {
+	return paylink.State == PaylinkStatePaid || paylink.State == PaylinkStateDeclined || paylink.State == PaylinkStateExpired
+}
+
+func (paylink *Paylink) setIdempotency(key string, replayed bool) {
+	paylink.IdempotencyKey = key
+	paylink.IdempotentReplay = replayed
 }
 
 type PaylinkList struct {
@@ -62,11 +122,17 @@ type PaylinkList struct {
 // PaylinkNew sends the new paylink to Twikey for creation
 func (c *Client) PaylinkNew(ctx context.Context, paylinkRequest *PaylinkRequest) (*Paylink, error) {
 
+	if err := c.validate(paylinkRequest); err != nil {
+		return nil, err
+	}
+
 	params := url.Values{}
 	addIfExists(params, "ct", paylinkRequest.Template)
 	addIfExists(params, "title", paylinkRequest.Title)
 	addIfExists(params, "remittance", paylinkRequest.Remittance)
-	addIfExists(params, "amount", fmt.Sprintf("%.2f", paylinkRequest.Amount))
+	if paylinkRequest.Amount != 0 {
+		params.Add("amount", fmt.Sprintf("%.2f", paylinkRequest.Amount))
+	}
 	addIfExists(params, "redirectUrl", paylinkRequest.RedirectUrl)
 	addIfExists(params, "place", paylinkRequest.Place)
 	addIfExists(params, "expiry", paylinkRequest.Expiry)
@@ -94,9 +160,7 @@ func (c *Client) PaylinkNew(ctx context.Context, paylinkRequest *PaylinkRequest)
 	c.Debug.Debugf("New link : %s", params.Encode())
 
 	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/creditor/payment/link", strings.NewReader(params.Encode()))
-	if paylinkRequest.IdempotencyKey != "" {
-		req.Header.Add("Idempotency-Key", paylinkRequest.IdempotencyKey)
-	}
+	c.addIdempotencyKey(req, &paylinkRequest.IdempotencyKey, []byte(params.Encode()))
 
 	var paylink Paylink
 	err := c.sendRequest(req, &paylink)
@@ -106,56 +170,143 @@ func (c *Client) PaylinkNew(ctx context.Context, paylinkRequest *PaylinkRequest)
 	return &paylink, nil
 }
 
+// PaylinkStream delivers the paylink feed over a channel, allowing it to be consumed by eg. a pool of workers.
+// The channel is closed when the feed is empty, when an error occurred (see FeedStream.Errors) or when the context is cancelled.
+func (c *Client) PaylinkStream(ctx context.Context, options ...FeedOption) (<-chan *Paylink, *FeedStream) {
+	events := make(chan *Paylink)
+	stream := newFeedStream(options)
+	go func() {
+		defer close(events)
+		stream.finish(ctx, c.HandlePaylinkFeed(ctx, func(paylink *Paylink) error {
+			stream.delivering(paylink.Seq)
+			select {
+			case events <- paylink:
+				return nil
+			case <-ctx.Done():
+				stream.undelivered()
+				return ctx.Err()
+			}
+		}, options...))
+	}()
+	return events, stream
+}
+
 // PaylinkFeed retrieves the feed of updated paylinks since last call
 func (c *Client) PaylinkFeed(ctx context.Context, callback func(paylink *Paylink), options ...FeedOption) error {
+	return c.HandlePaylinkFeed(ctx, func(paylink *Paylink) error {
+		callback(paylink)
+		return nil
+	}, options...)
+}
 
-	if err := c.refreshTokenIfRequired(); err != nil {
-		return err
-	}
-
-	feedOptions := parseFeedOptions(options)
-	_url := c.BaseURL + "/creditor/payment/link/feed"
-	for i, sideload := range feedOptions.includes {
-		if i == 0 {
-			_url = _url + "?include=" + sideload
-		} else {
-			_url = _url + "&include=" + sideload
-		}
-	}
-
+// HandlePaylinkFeed retrieves the feed of updated paylinks since last call, the first error returned by the handler
+// stops the feed and is returned as a FeedError containing the Seq of the last handled paylink.
+func (c *Client) HandlePaylinkFeed(ctx context.Context, handler func(paylink *Paylink) error, options ...FeedOption) error {
+	feed := c.newFeedReader(ctx, "/creditor/payment/link/feed", options)
 	for {
-		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, _url, nil)
-		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-		req.Header.Add("Authorization", c.apiToken)
-		req.Header.Set("User-Agent", c.UserAgent)
-		if feedOptions.start != -1 {
-			req.Header.Set("X-RESUME-AFTER", fmt.Sprintf("%d", feedOptions.start))
-			feedOptions.start = -1
-		}
-
-		res, err := c.HTTPClient.Do(req)
-		if err != nil {
+		var paylinks PaylinkList
+		if err := feed.next(&paylinks); err != nil {
 			return err
 		}
-		if res.StatusCode == 200 {
-			payload, _ := io.ReadAll(res.Body)
-			_ = res.Body.Close()
-			var paylinks PaylinkList
-			err := json.Unmarshal(payload, &paylinks)
-			if err == nil {
-				c.Debug.Debugf("Fetched %d links", len(paylinks.Links))
-				for _, paylink := range paylinks.Links {
-					callback(&paylink)
-				}
-			} else {
+		c.Debug.Debugf("Fetched %d links", len(paylinks.Links))
+		for i := range paylinks.Links {
+			paylink := &paylinks.Links[i]
+			if err := feed.handled(paylink.Seq, handler(paylink)); err != nil {
 				return err
 			}
-			if len(paylinks.Links) == 0 {
-				return nil
-			}
-		} else {
-			c.Debug.Debugf("Error response from Twikey: %d", res.StatusCode)
-			return NewTwikeyErrorFromResponse(res)
 		}
+		if len(paylinks.Links) == 0 {
+			return nil
+		}
+	}
+}
+
+// PaylinkLookup identifies a paylink by its id or by its reference
+type PaylinkLookup struct {
+	Id  int64
+	Ref string
+}
+
+func (lookup *PaylinkLookup) asUrlParams() (string, error) {
+	params := url.Values{}
+	if lookup.Id != 0 {
+		params.Add("id", strconv.FormatInt(lookup.Id, 10))
+	}
+	addIfExists(params, "ref", lookup.Ref)
+	if len(params) == 0 {
+		v := validation{}
+		v.fail("Id", "or Ref is required")
+		return "", v.err()
+	}
+	return params.Encode(), nil
+}
+
+// PaylinkDetail retrieves the current state of a paylink
+func (c *Client) PaylinkDetail(ctx context.Context, lookup *PaylinkLookup) (*Paylink, error) {
+	params, err := lookup.asUrlParams()
+	if err != nil {
+		return nil, err
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/creditor/payment/link?"+params, nil)
+	var paylink Paylink
+	if err := c.sendRequest(req, &paylink); err != nil {
+		return nil, err
+	}
+	return &paylink, nil
+}
+
+// PaylinkCancel cancels a paylink which is not yet paid, so it can no longer be used
+func (c *Client) PaylinkCancel(ctx context.Context, lookup *PaylinkLookup) error {
+	params, err := lookup.asUrlParams()
+	if err != nil {
+		return err
+	}
+
+	c.Debug.Debugf("Cancel link : %s", params)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodDelete, c.BaseURL+"/creditor/payment/link?"+params, nil)
+	return c.sendRequest(req, nil)
+}
+
+type PaylinkRefundRequest struct {
+	IdempotencyKey string  // Avoid double refunds
+	Id             int64   // Id of the paid link
+	Message        string  // Message to the customer, by default the message of the paylink is used
+	Amount         float64 // Amount to refund, the full amount of the paylink when 0
+}
+
+// Validate checks the request locally and returns a ValidationError listing all invalid fields
+func (request *PaylinkRefundRequest) Validate() error {
+	v := validation{}
+	if request.Id == 0 {
+		v.fail("Id", "is required")
+	}
+	if request.Amount < 0 {
+		v.fail("Amount", "should be positive")
+	}
+	return v.err()
+}
+
+// PaylinkRefund refunds a paid link (fully or partially) to the account or card it was paid with
+func (c *Client) PaylinkRefund(ctx context.Context, request *PaylinkRefundRequest) (*Refund, error) {
+	if err := c.validate(request); err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Add("id", strconv.FormatInt(request.Id, 10))
+	addIfExists(params, "message", request.Message)
+	if request.Amount != 0 {
+		params.Add("amount", fmt.Sprintf("%.2f", request.Amount))
+	}
+
+	c.Debug.Debugf("Refund link : %s", params.Encode())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/creditor/payment/link/refund", strings.NewReader(params.Encode()))
+	c.addIdempotencyKey(req, &request.IdempotencyKey, []byte(params.Encode()))
+
+	var refund Refund
+	if err := c.sendRequest(req, &refund); err != nil {
+		return nil, err
 	}
+	return &refund, nil
 }
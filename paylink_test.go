@@ -2,6 +2,8 @@ package twikey
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 )
@@ -38,3 +40,51 @@ func TestPaylinkFeed(t *testing.T) {
 		}
 	})
 }
+
+func TestPaylinkDetailCancelAndRefund(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /creditor/payment/link":
+			AssertEquals(t, "42", r.URL.Query().Get("id"))
+			_, _ = w.Write([]byte(`{"id":42,"amount":10,"state":"paid","method":"bancontact","paidDate":"2024-01-02","invoice":"INV1","customer":{"customerNumber":"cst1"}}`))
+		case "DELETE /creditor/payment/link":
+			AssertEquals(t, "ORDER1", r.URL.Query().Get("ref"))
+			w.WriteHeader(http.StatusNoContent)
+		case "POST /creditor/payment/link/refund":
+			_ = r.ParseForm()
+			AssertEquals(t, "42", r.Form.Get("id"))
+			AssertEquals(t, "2.50", r.Form.Get("amount"))
+			AssertEquals(t, "refund-1", r.Header.Get("Idempotency-Key"))
+			_, _ = w.Write([]byte(`{"id":"R1","amount":2.5,"state":"PAID"}`))
+		default:
+			t.Errorf("Unexpected call %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+	ctx := context.Background()
+
+	paylink, err := cl.PaylinkDetail(ctx, &PaylinkLookup{Id: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, true, paylink.IsPaid())
+	AssertEquals(t, true, paylink.IsFinal())
+	AssertEquals(t, "bancontact", paylink.Method)
+	AssertEquals(t, "INV1", paylink.Invoice)
+	AssertEquals(t, "cst1", paylink.Customer.CustomerNumber)
+
+	if err = cl.PaylinkCancel(ctx, &PaylinkLookup{Ref: "ORDER1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cl.PaylinkCancel(ctx, &PaylinkLookup{}).(*ValidationError); !ok {
+		t.Error("Expected a validation error without id or ref")
+	}
+
+	refund, err := cl.PaylinkRefund(ctx, &PaylinkRefundRequest{IdempotencyKey: "refund-1", Id: 42, Amount: 2.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, "R1", refund.Id)
+	AssertEquals(t, "refund-1", refund.IdempotencyKey)
+}
@@ -0,0 +1,103 @@
+package twikey
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Delivery channels of an invoice
+const (
+	DeliveryPeppol   = "peppol"
+	DeliveryEmail    = "email"
+	DeliveryPrint    = "print"
+	DeliveryDisabled = "disabled"
+)
+
+// Peppol delivery states as reported in InvoiceFeedMeta
+const (
+	PeppolStateQueued    = "QUEUED"    // Waiting to be sent
+	PeppolStateSent      = "SENT"      // Handed over to the access point of the receiver
+	PeppolStateDelivered = "DELIVERED" // Acknowledged by the receiver
+	PeppolStateRejected  = "REJECTED"  // Refused by the receiver (see Reason)
+	PeppolStateFailed    = "FAILED"    // Could not be sent (see Reason)
+)
+
+// PeppolParticipant is the result of a lookup in the Peppol directory
+type PeppolParticipant struct {
+	Registered    bool     `json:"registered"`
+	ParticipantId string   `json:"participantId"` // eg. 0208:0123456789
+	Name          string   `json:"name"`
+	Country       string   `json:"country"`
+	DocumentTypes []string `json:"documentTypes"` // Document types the participant accepts
+}
+
+// PeppolStatus contains the delivery status of an invoice sent over Peppol
+type PeppolStatus struct {
+	State     string `json:"state"`               // See PeppolStateQueued, ..
+	MessageId string `json:"messageId,omitempty"` // Identifier of the message in the Peppol network
+	Date      string `json:"date,omitempty"`      // Date of the last change of the state
+	Reason    string `json:"reason,omitempty"`    // Reason in case of a rejection or failure
+}
+
+// IsDelivered convenience method
+func (status *PeppolStatus) IsDelivered() bool {
+	return status.State == PeppolStateDelivered
+}
+
+// IsFailed returns true if the invoice was refused or could not be sent, in which case another delivery is required
+func (status *PeppolStatus) IsFailed() bool {
+	return status.State == PeppolStateRejected || status.State == PeppolStateFailed
+}
+
+// PeppolLookup checks whether a company is reachable via Peppol using its VAT or enterprise number (Coc).
+// An unknown company is not an error but results in a participant that isn't registered.
+func (c *Client) PeppolLookup(ctx context.Context, coc string) (*PeppolParticipant, error) {
+	coc = strings.ToUpper(strings.Join(strings.FieldsFunc(coc, func(r rune) bool {
+		return r == ' ' || r == '.' || r == '-'
+	}), ""))
+	if coc == "" {
+		return nil, NewTwikeyError("err_invalid_coc", "A vat or enterprise number is required", "")
+	}
+
+	params := url.Values{}
+	params.Add("coc", coc)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/creditor/peppol/lookup?"+params.Encode(), nil)
+	var participant PeppolParticipant
+	if err := c.sendRequest(req, &participant); err != nil {
+		if twikeyError, ok := err.(*TwikeyError); ok && twikeyError.Status == http.StatusNotFound {
+			return &PeppolParticipant{}, nil
+		}
+		return nil, err
+	}
+	return &participant, nil
+}
+
+// InvoiceDelivery selects the delivery channel for a customer, falling back from peppol (when the customer has a
+// Peppol id or its Coc is registered in the Peppol directory) to email (when an email address is known) to print
+// (when an address is known). When none is possible the delivery is disabled.
+func (c *Client) InvoiceDelivery(ctx context.Context, customer *Customer) (string, error) {
+	if customer == nil {
+		return DeliveryDisabled, nil
+	}
+	if customer.Peppol != "" {
+		return DeliveryPeppol, nil
+	}
+	if customer.Coc != "" {
+		participant, err := c.PeppolLookup(ctx, customer.Coc)
+		if err != nil {
+			return "", err
+		}
+		if participant.Registered {
+			return DeliveryPeppol, nil
+		}
+	}
+	if customer.Email != "" {
+		return DeliveryEmail, nil
+	}
+	if customer.Address != "" && customer.City != "" {
+		return DeliveryPrint, nil
+	}
+	return DeliveryDisabled, nil
+}
@@ -0,0 +1,80 @@
+package twikey
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPeppolLookupAndDelivery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/creditor/peppol/lookup" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		switch r.URL.Query().Get("coc") {
+		case "BE0123456749":
+			_, _ = w.Write([]byte(`{"registered":true,"participantId":"0208:0123456749","name":"Acme","country":"BE","documentTypes":["invoice"]}`))
+		case "BE0555555555":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"code":"err_not_found","message":"Not found"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	c := NewMockedTestClient(server)
+	ctx := context.Background()
+
+	participant, err := c.PeppolLookup(ctx, "BE 0123.456.749")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, true, participant.Registered)
+	AssertEquals(t, "0208:0123456749", participant.ParticipantId)
+
+	participant, err = c.PeppolLookup(ctx, "BE0987654321")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, false, participant.Registered)
+
+	// Twikey itself answers with an error in json
+	participant, err = c.PeppolLookup(ctx, "BE0555555555")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, false, participant.Registered)
+
+	tests := []struct {
+		customer *Customer
+		delivery string
+	}{
+		{&Customer{Coc: "BE0123456749", Email: "info@acme.be"}, DeliveryPeppol},
+		{&Customer{Peppol: "0208:0987654321"}, DeliveryPeppol},
+		{&Customer{Coc: "BE0987654321", Email: "info@acme.be"}, DeliveryEmail},
+		{&Customer{Address: "Main street 1", City: "Gent"}, DeliveryPrint},
+		{&Customer{}, DeliveryDisabled},
+	}
+	for _, test := range tests {
+		delivery, err := c.InvoiceDelivery(ctx, test.customer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		AssertEquals(t, test.delivery, delivery)
+	}
+}
+
+func TestInvoiceFeedMetaPeppol(t *testing.T) {
+	var invoice Invoice
+	err := json.Unmarshal([]byte(`{"id":"a1","meta":{"peppol":{"state":"REJECTED","messageId":"m1","reason":"Unknown buyer"}}}`), &invoice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, true, invoice.HasMeta())
+	AssertEquals(t, true, invoice.Meta.Peppol.IsFailed())
+	AssertEquals(t, false, invoice.Meta.Peppol.IsDelivered())
+	AssertEquals(t, "Unknown buyer", invoice.Meta.Peppol.Reason)
+}
@@ -0,0 +1,216 @@
+package twikey
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Plan is a named base plan for subscriptions, see SubscriptionAddRequest.Plan
+type Plan struct {
+	Id         int        `json:"id"`
+	Name       string     `json:"name"`
+	Amount     float64    `json:"amount"`
+	Message    string     `json:"message"`
+	Recurrence Recurrence `json:"recurrence"`
+	StopAfter  int        `json:"stopAfter"`
+	Archived   bool       `json:"archived"`
+}
+
+type PlanRequest struct {
+	// Name of the plan, used to reference it when adding or updating a subscription.
+	Name string
+	// Amount of every transaction created by subscriptions on this plan.
+	Amount float64
+	// The message the subscriber will see.
+	Message string
+	// The frequency of the subscriptions, by default it will be monthly.
+	Recurrence Recurrence
+	// Number of times subscriptions on this plan are executed. Set to a value lower than 1 for unbounded subscriptions.
+	StopAfter int
+}
+
+// Validate checks the request locally and returns a ValidationError listing all invalid fields
+func (r *PlanRequest) Validate() error {
+	v := validation{}
+	v.required("Name", r.Name)
+	v.required("Message", r.Message)
+	if r.Amount <= 0 {
+		v.fail("Amount", "should be positive")
+	}
+	v.oneOf("Recurrence", string(r.Recurrence), recurrences...)
+	return v.err()
+}
+
+func (r *PlanRequest) asUrlParams() string {
+	params := url.Values{}
+	params.Add("name", r.Name)
+	params.Add("amount", fmt.Sprintf("%.2f", r.Amount))
+	params.Add("message", r.Message)
+	if r.Recurrence != "" {
+		params.Add("recurrence", string(r.Recurrence))
+	}
+	if r.StopAfter > 0 {
+		params.Add("stopAfter", strconv.Itoa(r.StopAfter))
+	}
+	return params.Encode()
+}
+
+// PlanCreate creates a new plan to which subscriptions can refer by its name
+func (c *Client) PlanCreate(ctx context.Context, payload *PlanRequest) (*Plan, error) {
+	if err := c.validate(payload); err != nil {
+		return nil, err
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/creditor/plan", strings.NewReader(payload.asUrlParams()))
+	var output Plan
+	if err := c.sendRequest(req, &output); err != nil {
+		return nil, err
+	}
+	return &output, nil
+}
+
+type PlanListResponse struct {
+	Plans []Plan `json:"Plans"`
+}
+
+// PlanList retrieves all plans, archived plans are only included when asked for
+func (c *Client) PlanList(ctx context.Context, includeArchived bool) ([]Plan, error) {
+	endpoint := c.BaseURL + "/creditor/plan"
+	if includeArchived {
+		endpoint += "?archived=true"
+	}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	var output PlanListResponse
+	if err := c.sendRequest(req, &output); err != nil {
+		return nil, err
+	}
+	return output.Plans, nil
+}
+
+// PlanUpdate changes the plan, existing subscriptions on the plan keep their current values until they are
+// migrated (see MigratePlanOperation).
+func (c *Client) PlanUpdate(ctx context.Context, name string, payload *PlanRequest) (*Plan, error) {
+	if name == "" {
+		return nil, NewTwikeyError("err_invalid_params", "The name of the plan is required", "")
+	}
+	if err := c.validate(payload); err != nil {
+		return nil, err
+	}
+
+	endpoint := fmt.Sprintf("%s/creditor/plan/%s", c.BaseURL, url.PathEscape(name))
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(payload.asUrlParams()))
+	var output Plan
+	if err := c.sendRequest(req, &output); err != nil {
+		return nil, err
+	}
+	return &output, nil
+}
+
+// PlanArchive archives the plan so no new subscriptions can be added to it, existing subscriptions continue to run.
+func (c *Client) PlanArchive(ctx context.Context, name string) error {
+	if name == "" {
+		return NewTwikeyError("err_invalid_params", "The name of the plan is required", "")
+	}
+	endpoint := fmt.Sprintf("%s/creditor/plan/%s", c.BaseURL, url.PathEscape(name))
+	req, _ := http.NewRequestWithContext(ctx, http.MethodDelete, endpoint, nil)
+	return c.sendRequest(req, nil) // no content response
+}
+
+// OnPlan returns a filter for SubscriptionBulk selecting the subscriptions on the plan
+func OnPlan(planId int) func(subscription *Subscription) bool {
+	return func(subscription *Subscription) bool {
+		return subscription.Plan == planId
+	}
+}
+
+// PlanSubscriptions retrieves all subscriptions on the plan. As the query doesn't support plans, all subscriptions
+// of the creditor are walked through and filtered locally.
+func (c *Client) PlanSubscriptions(ctx context.Context, planId int) ([]Subscription, error) {
+	all, err := c.Subscriptions(ctx, nil).All()
+	if err != nil {
+		return nil, err
+	}
+	onPlan := OnPlan(planId)
+	var subscriptions []Subscription
+	for i := range all {
+		if onPlan(&all[i]) {
+			subscriptions = append(subscriptions, all[i])
+		}
+	}
+	return subscriptions, nil
+}
+
+// MigratePlanOperation moves active or suspended subscriptions to another plan using SubscriptionUpdate, which
+// replaces the subscription by a new one on the plan starting at the next run. A bounded subscription keeps the
+// number of runs it had left. Use it with a SubscriptionBulk
+// and the OnPlan filter to roll out a new version of a plan:
+//
+//	bulk := client.NewSubscriptionBulk()
+//	bulk.Filter = OnPlan(oldPlan.Id)
+//	report, err := bulk.Run(ctx, nil, &MigratePlanOperation{Plan: newPlan})
+type MigratePlanOperation struct {
+	Plan *Plan
+}
+
+func (op *MigratePlanOperation) name() string { return "migrate plan" }
+
+func (op *MigratePlanOperation) skip(subscription *Subscription) string {
+	if reason := skipIfEnded(subscription); reason != "" {
+		return reason
+	}
+	if op.Plan != nil && subscription.Plan == op.Plan.Id {
+		return "already on plan " + op.Plan.Name
+	}
+	if subscription.StopAfter > 0 && subscription.Runs >= subscription.StopAfter {
+		return "no runs left"
+	}
+	return ""
+}
+
+// remainingRuns returns the number of runs left of a bounded subscription (0 when unbounded), as the subscription
+// replacing it starts counting from 0 runs
+func remainingRuns(subscription *Subscription) int {
+	if subscription.StopAfter <= 0 {
+		return 0
+	}
+	return subscription.StopAfter - subscription.Runs
+}
+
+func (op *MigratePlanOperation) apply(ctx context.Context, c *Client, subscription *Subscription, dryRun bool) (*Subscription, error) {
+	if op.Plan == nil || op.Plan.Name == "" {
+		return nil, NewTwikeyError("err_invalid_params", "The plan to migrate to is required", "")
+	}
+
+	// only future start dates are accepted, so the next run is used unless it has passed already
+	today := dateOf(c.TimeProvider.Now())
+	start := subscription.Next
+	if next, err := time.Parse("2006-01-02", start); err != nil || !next.After(today) {
+		start = today.AddDate(0, 0, 1).Format("2006-01-02")
+	}
+	if dryRun {
+		after := *subscription
+		after.Plan = op.Plan.Id
+		after.Amount = op.Plan.Amount
+		after.Message = op.Plan.Message
+		after.Recurrence = op.Plan.Recurrence
+		after.StopAfter = op.Plan.StopAfter
+		if remaining := remainingRuns(subscription); remaining > 0 {
+			after.StopAfter = remaining
+		}
+		after.Runs = 0
+		after.Start = start
+		after.Next = start
+		return &after, nil
+	}
+	return c.SubscriptionUpdate(ctx, subscription.MndtId, subscription.Ref, &UpdateSubscriptionRequest{
+		MndtId:    subscription.MndtId,
+		Start:     start,
+		Plan:      op.Plan.Name,
+		StopAfter: remainingRuns(subscription),
+	})
+}
@@ -0,0 +1,152 @@
+package twikey
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPlanCrud(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "POST /creditor/plan":
+			_ = r.ParseForm()
+			AssertEquals(t, "premium", r.Form.Get("name"))
+			AssertEquals(t, "9.99", r.Form.Get("amount"))
+			AssertEquals(t, "12", r.Form.Get("stopAfter"))
+			_, _ = w.Write([]byte(`{"id":3,"name":"premium","amount":9.99,"message":"Premium","recurrence":"1m","stopAfter":12}`))
+		case "GET /creditor/plan":
+			AssertEquals(t, "true", r.URL.Query().Get("archived"))
+			_, _ = w.Write([]byte(`{"Plans":[{"id":3,"name":"premium"},{"id":1,"name":"basic","archived":true}]}`))
+		case "POST /creditor/plan/premium":
+			_ = r.ParseForm()
+			AssertEquals(t, "10.99", r.Form.Get("amount"))
+			_, _ = w.Write([]byte(`{"id":3,"name":"premium","amount":10.99}`))
+		case "DELETE /creditor/plan/premium":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("Unexpected call %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+	ctx := context.Background()
+
+	_, err := cl.PlanCreate(ctx, &PlanRequest{Name: "premium", Recurrence: "1y"})
+	if validationError, ok := err.(*ValidationError); !ok || !validationError.HasField("Amount") || !validationError.HasField("Recurrence") {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+
+	request := &PlanRequest{Name: "premium", Amount: 9.99, Message: "Premium", Recurrence: RecurrenceMonthly, StopAfter: 12}
+	plan, err := cl.PlanCreate(ctx, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 3, plan.Id)
+
+	plans, err := cl.PlanList(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 2, len(plans))
+	AssertEquals(t, true, plans[1].Archived)
+
+	request.Amount = 10.99
+	plan, err = cl.PlanUpdate(ctx, "premium", request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 10.99, plan.Amount)
+
+	if err = cl.PlanArchive(ctx, "premium"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlanMigration(t *testing.T) {
+	var updated []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/creditor/subscription/query" {
+			_, _ = w.Write([]byte(`{"Subscriptions":[
+				{"mndtId":"M1","ref":"A","state":"active","plan":1,"next":"2099-05-01"},
+				{"mndtId":"M2","ref":"B","state":"cancelled","plan":1},
+				{"mndtId":"M3","ref":"C","state":"active","plan":2},
+				{"mndtId":"M4","ref":"D","state":"active","plan":1,"next":"2099-05-01","stopAfter":12,"runs":10},
+				{"mndtId":"M5","ref":"E","state":"active","plan":1,"stopAfter":12,"runs":12}
+			],"_links":{}}`))
+			return
+		}
+		AssertEquals(t, http.MethodPost, r.Method)
+		_ = r.ParseForm()
+		AssertEquals(t, "premium-v2", r.Form.Get("plan"))
+		AssertEquals(t, "2099-05-01", r.Form.Get("start"))
+		if r.Form.Get("mndtId") == "M4" {
+			AssertEquals(t, "2", r.Form.Get("stopAfter"))
+		} else {
+			AssertEquals(t, "", r.Form.Get("stopAfter"))
+		}
+		updated = append(updated, r.URL.Path)
+		_, _ = w.Write([]byte(`{"mndtId":"M1","ref":"A","state":"active","plan":4}`))
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+	ctx := context.Background()
+
+	subscriptions, err := cl.PlanSubscriptions(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 4, len(subscriptions))
+
+	bulk := cl.NewSubscriptionBulk()
+	bulk.Concurrency = 1
+	bulk.Filter = OnPlan(1)
+	operation := &MigratePlanOperation{Plan: &Plan{Id: 4, Name: "premium-v2", StopAfter: 24}}
+
+	// the dry run keeps the remaining runs of a bounded subscription
+	bulk.DryRun = true
+	report, err := bulk.Run(ctx, nil, operation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 4, len(report.Items))
+	AssertEquals(t, 24, report.Items[0].After.StopAfter)
+	AssertEquals(t, 2, report.Items[2].After.StopAfter)
+	AssertEquals(t, 0, len(updated))
+
+	bulk.DryRun = false
+	report, err = bulk.Run(ctx, nil, operation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 4, len(report.Items))
+	AssertEquals(t, 4, report.Items[0].After.Plan)
+	AssertEquals(t, true, report.Items[1].Skipped)
+	AssertEquals(t, true, report.Items[3].Skipped)
+	AssertEquals(t, "no runs left", report.Items[3].Reason)
+	AssertEquals(t, 2, len(updated))
+	AssertEquals(t, "/creditor/subscription/M1/A", updated[0])
+	AssertEquals(t, "/creditor/subscription/M4/D", updated[1])
+}
+
+func TestPlanMigrationStartUsesTimeProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"Subscriptions":[{"mndtId":"M1","ref":"A","state":"active","plan":1,"next":"2024-02-01"}],"_links":{}}`))
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	cl.TimeProvider = &TestTimeProvider{currentTime: now}
+	cl.lastLogin = now
+
+	bulk := cl.NewSubscriptionBulk()
+	bulk.DryRun = true
+	report, err := bulk.Run(context.Background(), nil, &MigratePlanOperation{Plan: &Plan{Id: 4, Name: "premium-v2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the next run has passed, so the migrated subscription starts the day after the date of the client
+	AssertEquals(t, "2024-03-02", report.Items[0].After.Start)
+}
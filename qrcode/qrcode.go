@@ -0,0 +1,470 @@
+// Package qrcode encodes binary data as a QR code (ISO/IEC 18004) using byte mode and renders it as png or svg
+// without any dependency outside the standard library.
+package qrcode
+
+import (
+	"errors"
+)
+
+// Level is the error correction level of a QR code
+type Level int
+
+const (
+	Low      Level = iota // Recovers 7% of the data
+	Medium                // Recovers 15% of the data, required for EPC (SEPA) QR codes
+	Quartile              // Recovers 25% of the data
+	High                  // Recovers 30% of the data
+)
+
+// ErrTooLong is returned when the data doesn't fit in a QR code of the highest version
+var ErrTooLong = errors.New("data too long for a qr code")
+
+// formatBits are the bits used to encode the level in the format information
+var formatBits = [4]int{1, 0, 3, 2}
+
+// eccCodewordsPerBlock per level and version (index 0 is unused)
+var eccCodewordsPerBlock = [4][41]int{
+	{-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+// numErrorCorrectionBlocks per level and version (index 0 is unused)
+var numErrorCorrectionBlocks = [4][41]int{
+	{-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// Code is an encoded QR code, a square grid of dark and light modules
+type Code struct {
+	Version int   // 1 to 40
+	Size    int   // Number of modules per side (17 + 4 * Version)
+	Level   Level // Error correction level
+	Mask    int   // Mask pattern applied (0 to 7)
+
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+// Encode encodes the data in byte mode using the smallest version that fits at the given level
+func Encode(data []byte, level Level) (*Code, error) {
+	if level < Low || level > High {
+		return nil, errors.New("invalid error correction level")
+	}
+	version := 1
+	for ; ; version++ {
+		if version > 40 {
+			return nil, ErrTooLong
+		}
+		if 4+charCountBits(version)+len(data)*8 <= numDataCodewords(version, level)*8 {
+			break
+		}
+	}
+
+	// mode indicator, character count and data
+	var bits bitBuffer
+	bits.append(0x4, 4)
+	bits.append(len(data), charCountBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	capacity := numDataCodewords(version, level) * 8
+	terminator := capacity - len(bits)
+	if terminator > 4 {
+		terminator = 4
+	}
+	bits.append(0, terminator)
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	codewords := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			codewords[i>>3] |= 1 << (7 - uint(i&7))
+		}
+	}
+
+	code := newCode(version, level)
+	code.drawFunctionPatterns()
+	code.drawCodewords(code.addEccAndInterleave(codewords))
+
+	// select the mask with the lowest penalty
+	bestMask, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		code.applyMask(mask)
+		code.drawFormatBits(mask)
+		if penalty := code.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			bestMask, bestPenalty = mask, penalty
+		}
+		code.applyMask(mask) // masks are xor-ed so applying again undoes it
+	}
+	code.Mask = bestMask
+	code.applyMask(bestMask)
+	code.drawFormatBits(bestMask)
+	return code, nil
+}
+
+// Black returns true if the module at column x and row y is dark, coordinates outside the code are light
+func (code *Code) Black(x int, y int) bool {
+	return x >= 0 && x < code.Size && y >= 0 && y < code.Size && code.modules[y][x]
+}
+
+func newCode(version int, level Level) *Code {
+	size := version*4 + 17
+	code := &Code{Version: version, Size: size, Level: level}
+	code.modules = make([][]bool, size)
+	code.isFunction = make([][]bool, size)
+	for i := range code.modules {
+		code.modules[i] = make([]bool, size)
+		code.isFunction[i] = make([]bool, size)
+	}
+	return code
+}
+
+func charCountBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// numRawDataModules returns the number of modules available for data and error correction
+func numRawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		numAlign := version/7 + 2
+		result -= (25*numAlign-10)*numAlign - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+func numDataCodewords(version int, level Level) int {
+	return numRawDataModules(version)/8 - eccCodewordsPerBlock[level][version]*numErrorCorrectionBlocks[level][version]
+}
+
+type bitBuffer []bool
+
+func (b *bitBuffer) append(value int, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*b = append(*b, (value>>uint(i))&1 != 0)
+	}
+}
+
+func (code *Code) setFunction(x int, y int, dark bool) {
+	code.modules[y][x] = dark
+	code.isFunction[y][x] = true
+}
+
+func (code *Code) drawFunctionPatterns() {
+	for i := 0; i < code.Size; i++ {
+		code.setFunction(6, i, i%2 == 0)
+		code.setFunction(i, 6, i%2 == 0)
+	}
+
+	code.drawFinderPattern(3, 3)
+	code.drawFinderPattern(code.Size-4, 3)
+	code.drawFinderPattern(3, code.Size-4)
+
+	positions := alignmentPatternPositions(code.Version)
+	n := len(positions)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			// skip the ones overlapping the finder patterns
+			if (i == 0 && j == 0) || (i == 0 && j == n-1) || (i == n-1 && j == 0) {
+				continue
+			}
+			code.drawAlignmentPattern(positions[i], positions[j])
+		}
+	}
+
+	// reserve the format area, the real bits are drawn once the mask is known
+	code.drawFormatBits(0)
+	code.drawVersion()
+}
+
+func (code *Code) drawFinderPattern(x int, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			dist := max(abs(dx), abs(dy))
+			xx, yy := x+dx, y+dy
+			if xx >= 0 && xx < code.Size && yy >= 0 && yy < code.Size {
+				code.setFunction(xx, yy, dist != 2 && dist != 4)
+			}
+		}
+	}
+}
+
+func (code *Code) drawAlignmentPattern(x int, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			code.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// alignmentPatternPositions returns the row/column positions of the centers of the alignment patterns
+func alignmentPatternPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	numAlign := version/7 + 2
+	step := (version*4 + numAlign*2 + 1) / (numAlign*2 - 2) * 2
+	if version == 32 {
+		step = 26
+	}
+	result := make([]int, numAlign)
+	result[0] = 6
+	for i, pos := numAlign-1, version*4+17-7; i >= 1; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+	return result
+}
+
+// formatInformation returns the 15 bits of format information for the level and mask
+func formatInformation(level Level, mask int) int {
+	data := formatBits[level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// versionInformation returns the 18 bits of version information (only used from version 7)
+func versionInformation(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	return version<<12 | rem
+}
+
+func bit(value int, i int) bool {
+	return (value>>uint(i))&1 != 0
+}
+
+func (code *Code) drawFormatBits(mask int) {
+	bits := formatInformation(code.Level, mask)
+	size := code.Size
+
+	// around the top left finder
+	for i := 0; i <= 5; i++ {
+		code.setFunction(8, i, bit(bits, i))
+	}
+	code.setFunction(8, 7, bit(bits, 6))
+	code.setFunction(8, 8, bit(bits, 7))
+	code.setFunction(7, 8, bit(bits, 8))
+	for i := 9; i < 15; i++ {
+		code.setFunction(14-i, 8, bit(bits, i))
+	}
+
+	// split between the other finders
+	for i := 0; i < 8; i++ {
+		code.setFunction(size-1-i, 8, bit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		code.setFunction(8, size-15+i, bit(bits, i))
+	}
+	code.setFunction(8, size-8, true) // always dark
+}
+
+func (code *Code) drawVersion() {
+	if code.Version < 7 {
+		return
+	}
+	bits := versionInformation(code.Version)
+	for i := 0; i < 18; i++ {
+		a, b := code.Size-11+i%3, i/3
+		code.setFunction(a, b, bit(bits, i))
+		code.setFunction(b, a, bit(bits, i))
+	}
+}
+
+// addEccAndInterleave splits the data in blocks, adds the error correction codewords to every block and
+// interleaves the result
+func (code *Code) addEccAndInterleave(data []byte) []byte {
+	numBlocks := numErrorCorrectionBlocks[code.Level][code.Version]
+	blockEccLen := eccCodewordsPerBlock[code.Level][code.Version]
+	rawCodewords := numRawDataModules(code.Version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	divisor := reedSolomonDivisor(blockEccLen)
+	blocks := make([][]byte, numBlocks)
+	for i, k := 0, 0; i < numBlocks; i++ {
+		datLen := shortBlockLen - blockEccLen
+		if i >= numShortBlocks {
+			datLen++
+		}
+		block := make([]byte, 0, shortBlockLen+1)
+		block = append(block, data[k:k+datLen]...)
+		k += datLen
+		ecc := reedSolomonRemainder(block, divisor)
+		if i < numShortBlocks {
+			block = append(block, 0) // placeholder, skipped when interleaving
+		}
+		blocks[i] = append(block, ecc...)
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			if i != shortBlockLen-blockEccLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+// drawCodewords places the codewords in zigzag over the modules which aren't part of a function pattern
+func (code *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := code.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // skip the vertical timing pattern
+		}
+		for vert := 0; vert < code.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = code.Size - 1 - vert // upwards
+				}
+				if !code.isFunction[y][x] && i < len(data)*8 {
+					code.modules[y][x] = bit(int(data[i>>3]), 7-(i&7))
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (code *Code) applyMask(mask int) {
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !code.isFunction[y][x] {
+				code.modules[y][x] = !code.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores the modules according to the 4 rules of the specification, lower is better
+func (code *Code) penalty() int {
+	size := code.Size
+	result := 0
+	finderLike := func(line []bool, i int) bool {
+		// 1:1:3:1:1 dark/light pattern preceded or followed by 4 light modules
+		pattern := []bool{true, false, true, true, true, false, true}
+		for k, dark := range pattern {
+			if line[i+k] != dark {
+				return false
+			}
+		}
+		before, after := true, true
+		for k := 1; k <= 4; k++ {
+			if i-k >= 0 && line[i-k] {
+				before = false
+			}
+			if i+6+k < len(line) && line[i+6+k] {
+				after = false
+			}
+		}
+		return before || after
+	}
+
+	line := make([]bool, size)
+	for horizontal := 0; horizontal < 2; horizontal++ {
+		for a := 0; a < size; a++ {
+			for b := 0; b < size; b++ {
+				if horizontal == 0 {
+					line[b] = code.modules[a][b]
+				} else {
+					line[b] = code.modules[b][a]
+				}
+			}
+			// runs of 5 or more modules of the same color
+			run := 1
+			for b := 1; b <= size; b++ {
+				if b < size && line[b] == line[b-1] {
+					run++
+					continue
+				}
+				if run >= 5 {
+					result += run - 2
+				}
+				run = 1
+			}
+			for b := 0; b+7 <= size; b++ {
+				if finderLike(line, b) {
+					result += 40
+				}
+			}
+		}
+	}
+
+	// 2x2 blocks of the same color
+	for y := 0; y < size-1; y++ {
+		for x := 0; x < size-1; x++ {
+			color := code.modules[y][x]
+			if color == code.modules[y][x+1] && color == code.modules[y+1][x] && color == code.modules[y+1][x+1] {
+				result += 3
+			}
+		}
+	}
+
+	// balance of dark and light modules
+	dark := 0
+	for _, row := range code.modules {
+		for _, module := range row {
+			if module {
+				dark++
+			}
+		}
+	}
+	total := size * size
+	result += abs(dark*100/total-50) / 5 * 10
+	return result
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func max(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
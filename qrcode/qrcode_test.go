@@ -0,0 +1,272 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// "HELLO WORLD" as 1-M from the specification examples
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	expected := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if ecc := reedSolomonRemainder(data, reedSolomonDivisor(10)); !bytes.Equal(expected, ecc) {
+		t.Errorf("Expected %v got %v", expected, ecc)
+	}
+}
+
+func TestFormatAndVersionInformation(t *testing.T) {
+	tests := []struct {
+		level    Level
+		mask     int
+		expected string
+	}{
+		{Low, 0, "111011111000100"},
+		{Medium, 0, "101010000010010"},
+		{Medium, 5, "100000011001110"},
+		{Quartile, 0, "011010101011111"},
+		{High, 7, "000100000111011"},
+	}
+	for _, test := range tests {
+		if actual := strconv.FormatInt(int64(formatInformation(test.level, test.mask)), 2); leftPad(actual, 15) != test.expected {
+			t.Errorf("Format %d/%d: expected %s got %s", test.level, test.mask, test.expected, actual)
+		}
+	}
+	if actual := leftPad(strconv.FormatInt(int64(versionInformation(7)), 2), 18); actual != "000111110010010100" {
+		t.Errorf("Version 7: got %s", actual)
+	}
+	if actual := leftPad(strconv.FormatInt(int64(versionInformation(40)), 2), 18); actual != "101000110001101001" {
+		t.Errorf("Version 40: got %s", actual)
+	}
+}
+
+func leftPad(value string, length int) string {
+	return strings.Repeat("0", length-len(value)) + value
+}
+
+func TestCapacity(t *testing.T) {
+	tests := []struct {
+		level    Level
+		version  int
+		capacity int // bytes in byte mode
+	}{
+		{Low, 1, 17},
+		{Medium, 1, 14},
+		{Quartile, 1, 11},
+		{High, 1, 7},
+		{Medium, 10, 213},
+		{Medium, 13, 331},
+		{Low, 40, 2953},
+	}
+	for _, test := range tests {
+		code, err := Encode(make([]byte, test.capacity), test.level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code.Version != test.version {
+			t.Errorf("%d bytes at level %d: expected version %d got %d", test.capacity, test.level, test.version, code.Version)
+		}
+		if test.version < 40 {
+			code, _ = Encode(make([]byte, test.capacity+1), test.level)
+			if code.Version != test.version+1 {
+				t.Errorf("%d bytes at level %d: expected version %d got %d", test.capacity+1, test.level, test.version+1, code.Version)
+			}
+		}
+	}
+	if _, err := Encode(make([]byte, 2954), Low); err != ErrTooLong {
+		t.Errorf("Expected ErrTooLong got %v", err)
+	}
+}
+
+func TestAlignmentPatternPositions(t *testing.T) {
+	expected := map[int]string{
+		1:  "[]",
+		2:  "[6 18]",
+		7:  "[6 22 38]",
+		32: "[6 34 60 86 112 138]",
+		36: "[6 24 50 76 102 128 154]",
+		40: "[6 30 58 86 114 142 170]",
+	}
+	for version, positions := range expected {
+		if actual := intsToString(alignmentPatternPositions(version)); actual != positions {
+			t.Errorf("Version %d: expected %s got %s", version, positions, actual)
+		}
+	}
+}
+
+func intsToString(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+// decode reads the data back from the modules, which verifies the format information, the masking, the
+// placement of the codewords and the interleaving of the blocks
+func decode(t *testing.T, code *Code) []byte {
+	t.Helper()
+	var format int
+	for i := 0; i <= 5; i++ {
+		if code.modules[i][8] {
+			format |= 1 << uint(i)
+		}
+	}
+	if code.modules[7][8] {
+		format |= 1 << 6
+	}
+	if code.modules[8][8] {
+		format |= 1 << 7
+	}
+	if code.modules[8][7] {
+		format |= 1 << 8
+	}
+	for i := 9; i < 15; i++ {
+		if code.modules[8][14-i] {
+			format |= 1 << uint(i)
+		}
+	}
+	if format != formatInformation(code.Level, code.Mask) {
+		t.Fatalf("Invalid format information %b", format)
+	}
+
+	// unmask on a copy
+	clone := newCode(code.Version, code.Level)
+	clone.drawFunctionPatterns()
+	for y := range code.modules {
+		copy(clone.modules[y], code.modules[y])
+	}
+	clone.applyMask(code.Mask)
+
+	var raw []byte
+	var current byte
+	count := 0
+	for right := clone.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < clone.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = clone.Size - 1 - vert
+				}
+				if clone.isFunction[y][x] {
+					continue
+				}
+				current <<= 1
+				if clone.modules[y][x] {
+					current |= 1
+				}
+				if count++; count%8 == 0 {
+					raw = append(raw, current)
+				}
+			}
+		}
+	}
+
+	// de-interleave and check the error correction of every block
+	numBlocks := numErrorCorrectionBlocks[code.Level][code.Version]
+	eccLen := eccCodewordsPerBlock[code.Level][code.Version]
+	rawCodewords := numRawDataModules(code.Version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortDataLen := rawCodewords/numBlocks - eccLen
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i < shortDataLen+1; i++ {
+		for j := 0; j < numBlocks; j++ {
+			if i < shortDataLen || j >= numShortBlocks {
+				blocks[j] = append(blocks[j], raw[k])
+				k++
+			}
+		}
+	}
+	divisor := reedSolomonDivisor(eccLen)
+	var data []byte
+	for i := 0; i < eccLen; i++ {
+		for j := 0; j < numBlocks; j++ {
+			blocks[j] = append(blocks[j], raw[k])
+			k++
+		}
+	}
+	for _, block := range blocks {
+		dataLen := len(block) - eccLen
+		if !bytes.Equal(reedSolomonRemainder(block[:dataLen], divisor), block[dataLen:]) {
+			t.Fatal("Invalid error correction")
+		}
+		data = append(data, block[:dataLen]...)
+	}
+
+	// byte mode segment
+	if data[0]>>4 != 0x4 {
+		t.Fatalf("Expected byte mode got %x", data[0]>>4)
+	}
+	bits := func(offset int, length int) int {
+		value := 0
+		for i := 0; i < length; i++ {
+			value = value<<1 | int(data[(offset+i)/8]>>(7-uint((offset+i)%8))&1)
+		}
+		return value
+	}
+	length := bits(4, charCountBits(code.Version))
+	offset := 4 + charCountBits(code.Version)
+	result := make([]byte, length)
+	for i := range result {
+		result[i] = byte(bits(offset+i*8, 8))
+	}
+	return result
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	payloads := []string{
+		"Hello, world!",
+		"BCD\n002\n1\nSCT\nGEBABEBB\nTwikey NV\nBE68539007547034\nEUR12.30\n\n\nInvoice 2024-001",
+		strings.Repeat("0123456789abcdef", 40),
+	}
+	for _, payload := range payloads {
+		for level := Low; level <= High; level++ {
+			code, err := Encode([]byte(payload), level)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded := string(decode(t, code)); decoded != payload {
+				t.Errorf("Expected %q got %q", payload, decoded)
+			}
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	code, err := Encode([]byte("Hello"), Medium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err = code.PNG(&out, 4); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != (21+2*QuietZone)*4 {
+		t.Errorf("Unexpected width %d", img.Bounds().Dx())
+	}
+	// top left module of the finder pattern is dark, the quiet zone is light
+	if r, _, _, _ := img.At(QuietZone*4, QuietZone*4).RGBA(); r != 0 {
+		t.Error("Expected a dark finder module")
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r == 0 {
+		t.Error("Expected a light quiet zone")
+	}
+
+	out.Reset()
+	if err = code.SVG(&out, 4); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "<svg") || !strings.Contains(out.String(), "M4,4h1v1h-1z") {
+		t.Errorf("Unexpected svg %s", out.String())
+	}
+}
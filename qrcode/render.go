@@ -0,0 +1,57 @@
+package qrcode
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// QuietZone is the number of light modules around the code required by the specification
+const QuietZone = 4
+
+// Image returns the code as a black and white image of scale pixels per module, including the quiet zone
+func (code *Code) Image(scale int) image.Image {
+	if scale < 1 {
+		scale = 1
+	}
+	size := (code.Size + 2*QuietZone) * scale
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{color.White, color.Black})
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if code.Black(x/scale-QuietZone, y/scale-QuietZone) {
+				img.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+	return img
+}
+
+// PNG writes the code as png image of scale pixels per module, including the quiet zone
+func (code *Code) PNG(w io.Writer, scale int) error {
+	return png.Encode(w, code.Image(scale))
+}
+
+// SVG writes the code as svg image of scale pixels per module, including the quiet zone. The dark modules are
+// drawn as a single path so the image scales without artifacts.
+func (code *Code) SVG(w io.Writer, scale int) error {
+	if scale < 1 {
+		scale = 1
+	}
+	size := code.Size + 2*QuietZone
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, `<svg xmlns="http://www.w3.org/2000/svg" version="1.1" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size*scale, size*scale, size, size)
+	fmt.Fprintf(out, `<rect width="100%%" height="100%%" fill="#FFFFFF"/><path fill="#000000" d="`)
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.modules[y][x] {
+				fmt.Fprintf(out, "M%d,%dh1v1h-1z", x+QuietZone, y+QuietZone)
+			}
+		}
+	}
+	fmt.Fprint(out, `"/></svg>`)
+	return out.Flush()
+}
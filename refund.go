@@ -2,10 +2,6 @@ package twikey
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"io"
-	"net/http"
 	"time"
 )
 
@@ -22,6 +18,14 @@ type Refund struct {
 	Date   string    `json:"date"`
 	State  string    `json:"state"`
 	Bkdate time.Time `json:"bkdate"`
+
+	IdempotencyKey   string `json:"-"` // key used when creating the refund
+	IdempotentReplay bool   `json:"-"` // true if Twikey returned the result of a previous call with the same key
+}
+
+func (refund *Refund) setIdempotency(key string, replayed bool) {
+	refund.IdempotencyKey = key
+	refund.IdempotentReplay = replayed
 }
 
 type RefundList struct {
@@ -30,54 +34,51 @@ type RefundList struct {
 
 // RefundFeed retrieves the feed of updated refunds since last call
 func (c *Client) RefundFeed(ctx context.Context, callback func(refund *Refund), options ...FeedOption) error {
+	return c.HandleRefundFeed(ctx, func(refund *Refund) error {
+		callback(refund)
+		return nil
+	}, options...)
+}
 
-	if err := c.refreshTokenIfRequired(); err != nil {
-		return err
-	}
-
-	feedOptions := parseFeedOptions(options)
-	_url := c.BaseURL + "/creditor/transfer"
-	for i, sideload := range feedOptions.includes {
-		if i == 0 {
-			_url = _url + "?include=" + sideload
-		} else {
-			_url = _url + "&include=" + sideload
-		}
-	}
-
+// HandleRefundFeed retrieves the feed of updated refunds since last call, the first error returned by the handler
+// stops the feed and is returned as a FeedError containing the Seq of the last handled refund.
+func (c *Client) HandleRefundFeed(ctx context.Context, handler func(refund *Refund) error, options ...FeedOption) error {
+	feed := c.newFeedReader(ctx, "/creditor/transfer", options)
 	for {
-		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, _url, nil)
-		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-		req.Header.Add("Authorization", c.apiToken)
-		req.Header.Set("User-Agent", c.UserAgent)
-		if feedOptions.start != -1 {
-			req.Header.Set("X-RESUME-AFTER", fmt.Sprintf("%d", feedOptions.start))
-			feedOptions.start = -1
-		}
-
-		res, err := c.HTTPClient.Do(req)
-		if err != nil {
+		var refunds RefundList
+		if err := feed.next(&refunds); err != nil {
 			return err
 		}
-		if res.StatusCode == 200 {
-			payload, _ := io.ReadAll(res.Body)
-			_ = res.Body.Close()
-			var refunds RefundList
-			err := json.Unmarshal(payload, &refunds)
-			if err == nil {
-				c.Debug.Debugf("Fetched %d refunds", len(refunds.Entries))
-				for _, refund := range refunds.Entries {
-					callback(&refund)
-				}
-			} else {
+		c.Debug.Debugf("Fetched %d refunds", len(refunds.Entries))
+		for i := range refunds.Entries {
+			refund := &refunds.Entries[i]
+			if err := feed.handled(refund.Seq, handler(refund)); err != nil {
 				return err
 			}
-			if len(refunds.Entries) == 0 {
-				return nil
-			}
-		} else {
-			c.Debug.Debugf("Error response from Twikey: %d", res.StatusCode)
-			return NewTwikeyErrorFromResponse(res)
+		}
+		if len(refunds.Entries) == 0 {
+			return nil
 		}
 	}
 }
+
+// RefundStream delivers the refund feed over a channel, allowing it to be consumed by eg. a pool of workers.
+// The channel is closed when the feed is empty, when an error occurred (see FeedStream.Errors) or when the context is cancelled.
+func (c *Client) RefundStream(ctx context.Context, options ...FeedOption) (<-chan *Refund, *FeedStream) {
+	events := make(chan *Refund)
+	stream := newFeedStream(options)
+	go func() {
+		defer close(events)
+		stream.finish(ctx, c.HandleRefundFeed(ctx, func(refund *Refund) error {
+			stream.delivering(refund.Seq)
+			select {
+			case events <- refund:
+				return nil
+			case <-ctx.Done():
+				stream.undelivered()
+				return ctx.Err()
+			}
+		}, options...))
+	}()
+	return events, stream
+}
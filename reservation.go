@@ -0,0 +1,223 @@
+package twikey
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IsExpired returns true when the reservation can no longer be captured
+func (reservation *Reservation) IsExpired() bool {
+	return reservation.IsExpiredAt(time.Now())
+}
+
+// IsExpiredAt returns true when the reservation can no longer be captured at the given moment
+func (reservation *Reservation) IsExpiredAt(now time.Time) bool {
+	return !reservation.Expires.IsZero() && !reservation.Expires.After(now)
+}
+
+// ReservationList is a struct to contain the response coming from Twikey, should be considered internal
+type ReservationList struct {
+	Reservations []Reservation
+}
+
+// ReservationList returns the reservations on a mandate as known by Twikey, use IsExpiredAt to leave out the ones
+// that expired since
+func (c *Client) ReservationList(ctx context.Context, mndtId string) ([]Reservation, error) {
+	if mndtId == "" {
+		return nil, NewTwikeyError("err_no_contract", "A mandate is required", "")
+	}
+	params := url.Values{}
+	params.Add("mndtId", mndtId)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/creditor/reservation?"+params.Encode(), nil)
+	var list ReservationList
+	if err := c.sendRequest(req, &list); err != nil {
+		return nil, err
+	}
+	return list.Reservations, nil
+}
+
+// ReservationCaptureRequest turns (part of) a reservation into a transaction
+type ReservationCaptureRequest struct {
+	IdempotencyKey string
+	Reservation    *Reservation // Reservation to capture
+	Amount         float64      // Amount to capture, 0 captures the full reserved amount
+	Msg            string       // Message of the transaction
+	Ref            string       // Reference of the transaction
+}
+
+// Validate checks the request locally and returns a ValidationError listing all invalid fields
+func (request *ReservationCaptureRequest) Validate() error {
+	return request.validateAt(time.Now())
+}
+
+func (request *ReservationCaptureRequest) validateAt(now time.Time) error {
+	v := validation{}
+	if request.Reservation == nil || request.Reservation.Id == "" {
+		v.fail("Reservation", "is required")
+	} else {
+		if request.Reservation.IsExpiredAt(now) {
+			v.fail("Reservation", "is expired")
+		}
+		if request.Amount > request.Reservation.ReservedAmount && !sameAmount(request.Amount, request.Reservation.ReservedAmount) {
+			v.fail("Amount", "exceeds the reserved amount")
+		}
+	}
+	if request.Amount < 0 {
+		v.fail("Amount", "should be positive")
+	}
+	v.required("Msg", request.Msg)
+	return v.err()
+}
+
+// ReservationCapture creates a transaction for the captured amount which consumes the reservation
+func (c *Client) ReservationCapture(ctx context.Context, request *ReservationCaptureRequest) (*Transaction, error) {
+	if err := c.validate(request); err != nil {
+		return nil, err
+	}
+	amount := request.Amount
+	if amount == 0 {
+		amount = request.Reservation.ReservedAmount
+	}
+	transaction := &TransactionRequest{
+		IdempotencyKey:    request.IdempotencyKey,
+		DocumentReference: request.Reservation.MndtId,
+		Msg:               request.Msg,
+		Ref:               request.Ref,
+		Amount:            roundAmount(amount),
+		Reservation:       request.Reservation.Id,
+	}
+	captured, err := c.TransactionNew(ctx, transaction)
+	request.IdempotencyKey = transaction.IdempotencyKey
+	return captured, err
+}
+
+// ReservationRelease cancels the reservation so the amount becomes available again to the customer
+func (c *Client) ReservationRelease(ctx context.Context, id string) error {
+	if id == "" {
+		return NewTwikeyError("err_invalid_reservation", "A reservation is required", "")
+	}
+	return c.DeleteTransaction(ctx, &TransactionDeleteRequest{Reservation: id})
+}
+
+// ReservationExtend moves the expiry of the reservation to a later moment and returns the updated reservation
+func (c *Client) ReservationExtend(ctx context.Context, id string, expires time.Time) (*Reservation, error) {
+	if id == "" {
+		return nil, NewTwikeyError("err_invalid_reservation", "A reservation is required", "")
+	}
+	if !c.skipValidation && !expires.After(c.TimeProvider.Now()) {
+		return nil, &ValidationError{Fields: []FieldError{{Field: "Expiration", Message: "should be in the future"}}}
+	}
+	params := url.Values{}
+	params.Add("reservationExpiration", expires.UTC().Format(time.RFC3339))
+	c.Debug.Debugf("Extending reservation %s until %s", id, expires)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/creditor/reservation/"+url.PathEscape(id), strings.NewReader(params.Encode()))
+	reservation := &Reservation{}
+	if err := c.sendRequest(req, reservation); err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// ReservationTracker keeps track of reservations locally and warns before they expire, so they can be captured or
+// extended in time. It doesn't call Twikey, register the reservations after creating or extending them.
+type ReservationTracker struct {
+	// WarnBefore is how long before the expiry OnExpiring is called (default 24h)
+	WarnBefore time.Duration
+	// OnExpiring is called once for every reservation about to expire
+	OnExpiring func(reservation *Reservation)
+	// OnExpired is called once for every reservation that expired while being tracked, after which it is forgotten
+	OnExpired func(reservation *Reservation)
+
+	mu           sync.Mutex
+	reservations map[string]*trackedReservation
+}
+
+type trackedReservation struct {
+	reservation *Reservation
+	warned      bool
+}
+
+// Track adds or replaces a reservation, tracking it again after an extension resets the warning
+func (tracker *ReservationTracker) Track(reservation *Reservation) {
+	if reservation == nil || reservation.Id == "" || reservation.Expires.IsZero() {
+		return
+	}
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	if tracker.reservations == nil {
+		tracker.reservations = make(map[string]*trackedReservation)
+	}
+	tracker.reservations[reservation.Id] = &trackedReservation{reservation: reservation}
+}
+
+// Forget stops tracking the reservation, eg. after it was captured or released
+func (tracker *ReservationTracker) Forget(id string) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	delete(tracker.reservations, id)
+}
+
+// Len returns the number of tracked reservations
+func (tracker *ReservationTracker) Len() int {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	return len(tracker.reservations)
+}
+
+// Check calls OnExpiring and OnExpired for the reservations that reached that point at the given moment and
+// returns the reservations that are about to expire
+func (tracker *ReservationTracker) Check(now time.Time) []*Reservation {
+	warnBefore := tracker.WarnBefore
+	if warnBefore <= 0 {
+		warnBefore = 24 * time.Hour
+	}
+
+	var expiring, expired []*Reservation
+	tracker.mu.Lock()
+	for id, tracked := range tracker.reservations {
+		expires := tracked.reservation.Expires
+		if !expires.After(now) {
+			expired = append(expired, tracked.reservation)
+			delete(tracker.reservations, id)
+		} else if !tracked.warned && !expires.After(now.Add(warnBefore)) {
+			tracked.warned = true
+			expiring = append(expiring, tracked.reservation)
+		}
+	}
+	tracker.mu.Unlock()
+
+	// callbacks are called without holding the lock so they can track or forget reservations
+	for _, reservation := range expiring {
+		if tracker.OnExpiring != nil {
+			tracker.OnExpiring(reservation)
+		}
+	}
+	for _, reservation := range expired {
+		if tracker.OnExpired != nil {
+			tracker.OnExpired(reservation)
+		}
+	}
+	return expiring
+}
+
+// Run checks the tracked reservations every interval until the context is done
+func (tracker *ReservationTracker) Run(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid interval %s", interval)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		tracker.Check(time.Now())
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package twikey
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReservationLifecycle(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		switch r.Method + " " + r.URL.Path {
+		case "GET /creditor/reservation":
+			AssertEquals(t, "MNDT1", r.Form.Get("mndtId"))
+			_, _ = w.Write([]byte(`{"Reservations":[
+				{"id":"r1","mndtId":"MNDT1","reservedAmount":50,"expires":"2024-03-03T00:00:00Z"},
+				{"id":"r2","mndtId":"MNDT1","reservedAmount":20,"expires":"2024-03-01T00:00:00Z"}
+			]}`))
+		case "POST /creditor/transaction":
+			AssertEquals(t, "r1", r.Header.Get("X-RESERVATION"))
+			AssertEquals(t, "MNDT1", r.Form.Get("mndtId"))
+			_, _ = w.Write([]byte(`{"Entries":[{"id":1,"mndtId":"MNDT1","amount":` + r.Form.Get("amount") + `}]}`))
+		case "POST /creditor/reservation/r1":
+			AssertEquals(t, "2030-01-01T00:00:00Z", r.Form.Get("reservationExpiration"))
+			_, _ = w.Write([]byte(`{"id":"r1","mndtId":"MNDT1","reservedAmount":50,"expires":"2030-01-01T00:00:00Z"}`))
+		case "DELETE /creditor/transaction":
+			AssertEquals(t, "r1", r.URL.Query().Get("reservation"))
+			AssertEquals(t, "r1", r.Header.Get("X-RESERVATION"))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("Unexpected call %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	c := NewMockedTestClient(server)
+	c.TimeProvider = &TestTimeProvider{currentTime: now}
+	c.lastLogin = now
+	ctx := context.Background()
+
+	reservations, err := c.ReservationList(ctx, "MNDT1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 2, len(reservations))
+	reservation := &reservations[0]
+	AssertEquals(t, false, reservation.IsExpiredAt(now))
+	AssertEquals(t, true, reservations[1].IsExpiredAt(now))
+
+	_, err = c.ReservationCapture(ctx, &ReservationCaptureRequest{Reservation: &reservations[1], Msg: "Expired"})
+	if verr, ok := err.(*ValidationError); !ok || !verr.HasField("Reservation") {
+		t.Errorf("Expected an expired reservation got %v", err)
+	}
+
+	tx, err := c.ReservationCapture(ctx, &ReservationCaptureRequest{Reservation: reservation, Amount: 12.5, Msg: "Partial"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 12.5, tx.Amount)
+	tx, err = c.ReservationCapture(ctx, &ReservationCaptureRequest{Reservation: reservation, Msg: "Full"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 50.0, tx.Amount)
+
+	_, err = c.ReservationCapture(ctx, &ReservationCaptureRequest{Reservation: reservation, Amount: 60, Msg: "Too much"})
+	if verr, ok := err.(*ValidationError); !ok || !verr.HasField("Amount") {
+		t.Errorf("Expected an invalid amount got %v", err)
+	}
+
+	if _, err = c.ReservationExtend(ctx, "r1", now.Add(-time.Hour)); err == nil {
+		t.Error("Expected an expiry before the time of the client to be refused")
+	}
+	extended, err := c.ReservationExtend(ctx, "r1", time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 2030, extended.Expires.Year())
+
+	if err = c.ReservationRelease(ctx, "r1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReservationTracker(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var warned, expired []string
+	tracker := &ReservationTracker{
+		WarnBefore: time.Hour,
+		OnExpiring: func(reservation *Reservation) {
+			warned = append(warned, reservation.Id)
+		},
+		OnExpired: func(reservation *Reservation) {
+			expired = append(expired, reservation.Id)
+		},
+	}
+	tracker.Track(&Reservation{Id: "soon", Expires: now.Add(30 * time.Minute)})
+	tracker.Track(&Reservation{Id: "later", Expires: now.Add(3 * time.Hour)})
+	tracker.Track(&Reservation{Id: "captured", Expires: now.Add(10 * time.Minute)})
+	tracker.Forget("captured")
+
+	AssertEquals(t, 1, len(tracker.Check(now)))
+	AssertEquals(t, "soon", warned[0])
+	// only warned once
+	AssertEquals(t, 0, len(tracker.Check(now.Add(time.Minute))))
+
+	tracker.Check(now.Add(time.Hour))
+	AssertEquals(t, 1, len(warned))
+	AssertEquals(t, "soon", expired[0])
+	AssertEquals(t, 1, tracker.Len())
+
+	// extending resets the warning
+	tracker.Track(&Reservation{Id: "later", Expires: now.Add(5 * time.Hour)})
+	AssertEquals(t, 0, len(tracker.Check(now.Add(3*time.Hour))))
+	AssertEquals(t, 1, len(tracker.Check(now.Add(4*time.Hour+30*time.Minute))))
+	AssertEquals(t, "later", warned[1])
+}
@@ -0,0 +1,201 @@
+package sepa
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/twikey/twikey-api-go"
+)
+
+// CreditTransfer is a refund paid to the account of the refund (Iban and Bic)
+type CreditTransfer struct {
+	Refund *twikey.Refund
+	Name   string // Name of the beneficiary
+}
+
+// CreditTransferFile is a pain.001.001.03 file paying refunds from a single account
+type CreditTransferFile struct {
+	MessageId     string    // Unique id of the file (max 35 characters)
+	Created       time.Time // Creation date of the file, default now
+	Debtor        Account   // Account from which the refunds are paid
+	ExecutionDate string    // Requested date of execution (yyyy-mm-dd)
+	Transfers     []CreditTransfer
+}
+
+const pain001Namespace = "urn:iso:std:iso:20022:tech:xsd:pain.001.001.03"
+
+type pain001Document struct {
+	XMLName xml.Name               `xml:"urn:iso:std:iso:20022:tech:xsd:pain.001.001.03 Document"`
+	GrpHdr  groupHeader            `xml:"CstmrCdtTrfInitn>GrpHdr"`
+	PmtInf  []creditTransferPmtInf `xml:"CstmrCdtTrfInitn>PmtInf"`
+}
+
+type creditTransferPmtInf struct {
+	PmtInfId    string                `xml:"PmtInfId"`
+	PmtMtd      string                `xml:"PmtMtd"`
+	NbOfTxs     string                `xml:"NbOfTxs"`
+	CtrlSum     string                `xml:"CtrlSum"`
+	SvcLvl      code                  `xml:"PmtTpInf>SvcLvl"`
+	ReqdExctnDt string                `xml:"ReqdExctnDt"`
+	Dbtr        party                 `xml:"Dbtr"`
+	DbtrAcct    account               `xml:"DbtrAcct"`
+	DbtrAgt     agent                 `xml:"DbtrAgt"`
+	ChrgBr      string                `xml:"ChrgBr"`
+	CdtTrfTxInf []creditTransferTxInf `xml:"CdtTrfTxInf"`
+}
+
+type creditTransferTxInf struct {
+	PmtId    paymentId `xml:"PmtId"`
+	InstdAmt amount    `xml:"Amt>InstdAmt"`
+	CdtrAgt  *agent    `xml:"CdtrAgt,omitempty"`
+	Cdtr     party     `xml:"Cdtr"`
+	CdtrAcct account   `xml:"CdtrAcct"`
+	Ustrd    string    `xml:"RmtInf>Ustrd,omitempty"`
+}
+
+// document converts the file to its xml structure
+func (file *CreditTransferFile) document() *pain001Document {
+	doc := &pain001Document{GrpHdr: newGroupHeader(file.MessageId, file.Created, file.Debtor.Name)}
+	block := creditTransferPmtInf{
+		PmtInfId:    paymentInfoId(file.MessageId, 1),
+		PmtMtd:      "TRF",
+		SvcLvl:      code{Cd: "SEPA"},
+		ReqdExctnDt: file.ExecutionDate,
+		Dbtr:        party{Nm: file.Debtor.Name},
+		DbtrAcct:    account{IBAN: normalizeIban(file.Debtor.Iban)},
+		DbtrAgt:     newAgent(file.Debtor.Bic),
+		ChrgBr:      "SLEV",
+	}
+	var cents int64
+	for _, transfer := range file.Transfers {
+		refund := transfer.Refund
+		if refund == nil {
+			refund = &twikey.Refund{}
+		}
+		tx := creditTransferTxInf{
+			PmtId:    paymentId{InstrId: refund.Id, EndToEndId: endToEndId(refund.Ref)},
+			InstdAmt: euro(refund.Amount),
+			Cdtr:     party{Nm: transfer.Name},
+			CdtrAcct: account{IBAN: normalizeIban(refund.Iban)},
+			Ustrd:    refund.Msg,
+		}
+		if refund.Bic != "" {
+			cdtrAgt := newAgent(refund.Bic)
+			tx.CdtrAgt = &cdtrAgt
+		}
+		block.CdtTrfTxInf = append(block.CdtTrfTxInf, tx)
+		cents += toCents(tx.InstdAmt.Value)
+	}
+	block.NbOfTxs = strconv.Itoa(len(block.CdtTrfTxInf))
+	block.CtrlSum = formatAmount(float64(cents) / 100)
+	doc.GrpHdr.NbOfTxs = block.NbOfTxs
+	doc.GrpHdr.CtrlSum = block.CtrlSum
+	doc.PmtInf = []creditTransferPmtInf{block}
+	return doc
+}
+
+// validate checks the structural rules of the document, returning a twikey.ValidationError listing all problems
+func (doc *pain001Document) validate() error {
+	v := &validation{}
+	v.identifier("GrpHdr.MsgId", doc.GrpHdr.MsgId, true)
+	v.dateTime("GrpHdr.CreDtTm", doc.GrpHdr.CreDtTm)
+	v.text("GrpHdr.InitgPty.Nm", doc.GrpHdr.InitgPty.Nm, 70, true)
+	if len(doc.PmtInf) == 0 {
+		v.fail("PmtInf", "is required")
+	}
+
+	count := 0
+	var cents int64
+	ids := map[string]bool{}
+	for i, block := range doc.PmtInf {
+		prefix := fmt.Sprintf("PmtInf[%d].", i)
+		v.identifier(prefix+"PmtInfId", block.PmtInfId, true)
+		if ids[block.PmtInfId] {
+			v.fail(prefix+"PmtInfId", "is not unique")
+		}
+		ids[block.PmtInfId] = true
+		v.oneOf(prefix+"PmtMtd", block.PmtMtd, "TRF")
+		v.oneOf(prefix+"PmtTpInf.SvcLvl.Cd", block.SvcLvl.Cd, "SEPA")
+		v.date(prefix+"ReqdExctnDt", block.ReqdExctnDt)
+		v.text(prefix+"Dbtr.Nm", block.Dbtr.Nm, 70, true)
+		v.iban(prefix+"DbtrAcct.Id.IBAN", block.DbtrAcct.IBAN)
+		v.agent(prefix+"DbtrAgt", &block.DbtrAgt, true)
+		v.oneOf(prefix+"ChrgBr", block.ChrgBr, "SLEV")
+		if len(block.CdtTrfTxInf) == 0 {
+			v.fail(prefix+"CdtTrfTxInf", "is required")
+		}
+
+		var blockCents int64
+		for j, tx := range block.CdtTrfTxInf {
+			txPrefix := fmt.Sprintf("%sCdtTrfTxInf[%d].", prefix, j)
+			v.identifier(txPrefix+"PmtId.InstrId", tx.PmtId.InstrId, false)
+			v.identifier(txPrefix+"PmtId.EndToEndId", tx.PmtId.EndToEndId, true)
+			blockCents += v.amount(txPrefix+"Amt.InstdAmt", tx.InstdAmt)
+			v.agent(txPrefix+"CdtrAgt", tx.CdtrAgt, false)
+			v.text(txPrefix+"Cdtr.Nm", tx.Cdtr.Nm, 70, true)
+			v.iban(txPrefix+"CdtrAcct.Id.IBAN", tx.CdtrAcct.IBAN)
+			v.text(txPrefix+"RmtInf.Ustrd", tx.Ustrd, 140, false)
+		}
+		v.totals(prefix, block.NbOfTxs, block.CtrlSum, len(block.CdtTrfTxInf), blockCents)
+		count += len(block.CdtTrfTxInf)
+		cents += blockCents
+	}
+	v.totals("GrpHdr.", doc.GrpHdr.NbOfTxs, doc.GrpHdr.CtrlSum, count, cents)
+	return v.err()
+}
+
+// Validate checks the file against the structural rules of pain.001.001.03, the fields of the returned
+// twikey.ValidationError are the paths of the invalid elements in the xml
+func (file *CreditTransferFile) Validate() error {
+	return file.document().validate()
+}
+
+// WriteXML validates the file and writes it as pain.001.001.03 xml
+func (file *CreditTransferFile) WriteXML(w io.Writer) error {
+	doc := file.document()
+	if err := doc.validate(); err != nil {
+		return err
+	}
+	return writeDocument(w, doc)
+}
+
+// ParseCreditTransferFile reads a pain.001.001.03 file, it fails when the file doesn't follow the structural rules.
+// The execution date of the transfers is returned as Date of the refunds.
+func ParseCreditTransferFile(r io.Reader) (*CreditTransferFile, error) {
+	doc := &pain001Document{}
+	if err := xml.NewDecoder(r).Decode(doc); err != nil {
+		return nil, fmt.Errorf("not a %s document: %w", pain001Namespace, err)
+	}
+	if err := doc.validate(); err != nil {
+		return nil, err
+	}
+
+	file := &CreditTransferFile{
+		MessageId: doc.GrpHdr.MsgId,
+		Created:   doc.GrpHdr.created(),
+	}
+	for i, block := range doc.PmtInf {
+		if i == 0 {
+			file.Debtor = Account{Name: block.Dbtr.Nm, Iban: block.DbtrAcct.IBAN, Bic: block.DbtrAgt.bic()}
+			file.ExecutionDate = block.ReqdExctnDt
+		}
+		for _, tx := range block.CdtTrfTxInf {
+			file.Transfers = append(file.Transfers, CreditTransfer{
+				Name: tx.Cdtr.Nm,
+				Refund: &twikey.Refund{
+					Id:     tx.PmtId.InstrId,
+					Iban:   tx.CdtrAcct.IBAN,
+					Bic:    tx.CdtrAgt.bic(),
+					Amount: float64(toCents(tx.InstdAmt.Value)) / 100,
+					Msg:    tx.Ustrd,
+					Ref:    tx.PmtId.ref(),
+					Date:   block.ReqdExctnDt,
+				},
+			})
+		}
+	}
+	return file, nil
+}
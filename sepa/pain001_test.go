@@ -0,0 +1,90 @@
+package sepa
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/twikey/twikey-api-go"
+)
+
+func TestCreditTransferRoundTrip(t *testing.T) {
+	file := &CreditTransferFile{
+		MessageId:     "REFUNDS-20240115",
+		Created:       time.Date(2024, 1, 15, 9, 0, 0, 0, time.Local),
+		Debtor:        Account{Name: "Twikey NV", Iban: "BE68539007547034"},
+		ExecutionDate: "2024-01-16",
+		Transfers: []CreditTransfer{
+			{Name: "John Doe", Refund: &twikey.Refund{Id: "rf1", Iban: "BE09363107700857", Bic: "BBRUBEBB", Amount: 12.5, Msg: "Refund order 1", Ref: "R1"}},
+			{Name: "Jane Doe", Refund: &twikey.Refund{Id: "rf2", Iban: "NL91ABNA0417164300", Amount: 7}},
+		},
+	}
+	var out bytes.Buffer
+	if err := file.WriteXML(&out); err != nil {
+		t.Fatal(err)
+	}
+	content := out.String()
+	for _, expected := range []string{
+		`<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.001.001.03">`,
+		`<PmtMtd>TRF</PmtMtd>`,
+		`<CtrlSum>19.50</CtrlSum>`,
+		`<Id>NOTPROVIDED</Id>`,
+		`<InstdAmt Ccy="EUR">7.00</InstdAmt>`,
+	} {
+		if !strings.Contains(content, expected) {
+			t.Errorf("Expected %s in %s", expected, content)
+		}
+	}
+	// the bank of the beneficiary is optional
+	if count := strings.Count(content, "<CdtrAgt>"); count != 1 {
+		t.Errorf("Expected 1 creditor agent got %d", count)
+	}
+
+	parsed, err := ParseCreditTransferFile(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.MessageId != file.MessageId || parsed.ExecutionDate != "2024-01-16" || parsed.Debtor.Name != "Twikey NV" || parsed.Debtor.Bic != "" {
+		t.Errorf("Unexpected file %+v", parsed)
+	}
+	if len(parsed.Transfers) != 2 {
+		t.Fatalf("Expected 2 transfers got %d", len(parsed.Transfers))
+	}
+	refund := parsed.Transfers[0].Refund
+	if parsed.Transfers[0].Name != "John Doe" || refund.Id != "rf1" || refund.Iban != "BE09363107700857" || refund.Bic != "BBRUBEBB" ||
+		refund.Amount != 12.5 || refund.Msg != "Refund order 1" || refund.Ref != "R1" || refund.Date != "2024-01-16" {
+		t.Errorf("Unexpected refund %+v", refund)
+	}
+	if refund = parsed.Transfers[1].Refund; refund.Ref != "" || refund.Bic != "" || refund.Amount != 7 {
+		t.Errorf("Unexpected refund %+v", refund)
+	}
+}
+
+func TestCreditTransferValidation(t *testing.T) {
+	file := &CreditTransferFile{
+		Debtor: Account{Name: "Twikey NV", Iban: "BE68539007547034", Bic: "GEB"},
+		Transfers: []CreditTransfer{
+			{Refund: &twikey.Refund{Iban: "US64SVBKUS6S3300958879", Amount: 1, Msg: strings.Repeat("x", 141)}},
+			{Name: "Jane Doe"},
+		},
+	}
+	err := file.Validate()
+	verr, ok := err.(*twikey.ValidationError)
+	if !ok {
+		t.Fatalf("Expected a validation error got %v", err)
+	}
+	for _, field := range []string{
+		"GrpHdr.MsgId",
+		"PmtInf[0].ReqdExctnDt",
+		"PmtInf[0].DbtrAgt.BIC",
+		"PmtInf[0].CdtTrfTxInf[0].Cdtr.Nm",
+		"PmtInf[0].CdtTrfTxInf[0].CdtrAcct.Id.IBAN",
+		"PmtInf[0].CdtTrfTxInf[0].RmtInf.Ustrd",
+		"PmtInf[0].CdtTrfTxInf[1].Amt.InstdAmt",
+	} {
+		if !verr.HasField(field) {
+			t.Errorf("Expected %s to be invalid: %v", field, err)
+		}
+	}
+}
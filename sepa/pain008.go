@@ -0,0 +1,283 @@
+package sepa
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/twikey/twikey-api-go"
+)
+
+// Sequence types of a direct debit
+const (
+	SequenceFirst     = "FRST" // First collection of a recurring mandate
+	SequenceRecurring = "RCUR" // Next collections of a recurring mandate
+	SequenceOneOff    = "OOFF" // Only collection of a one-off mandate
+	SequenceFinal     = "FNAL" // Last collection of a recurring mandate
+)
+
+// Schemes of a direct debit
+const (
+	SchemeCore = "CORE" // Consumers and businesses
+	SchemeB2B  = "B2B"  // Business to business only
+)
+
+// DirectDebit is a transaction collected from the debtor of a mandate
+type DirectDebit struct {
+	Transaction   *twikey.Transaction
+	Mandate       *twikey.Mndt // debtor name (Dbtr.Nm), account (DbtrAcct) and bank (DbtrAgt)
+	SignatureDate string       // Date on which the mandate was signed (yyyy-mm-dd)
+	SequenceType  string       // SequenceFirst, SequenceRecurring (default), SequenceOneOff or SequenceFinal
+}
+
+// DirectDebitFile is a pain.008.001.02 file collecting transactions of a single creditor. Transactions are grouped
+// per sequence type and collection date, as required by the format.
+type DirectDebitFile struct {
+	MessageId        string    // Unique id of the file (max 35 characters)
+	Created          time.Time // Creation date of the file, default now
+	Creditor         Account
+	CreditorSchemeId string // Creditor identifier (eg. BE69ZZZ050D000000008)
+	Scheme           string // SchemeCore (default) or SchemeB2B
+	// CollectionDate is the requested date of collection (yyyy-mm-dd) for transactions without RequestedCollection
+	CollectionDate string
+	DirectDebits   []DirectDebit
+}
+
+const pain008Namespace = "urn:iso:std:iso:20022:tech:xsd:pain.008.001.02"
+
+type pain008Document struct {
+	XMLName xml.Name            `xml:"urn:iso:std:iso:20022:tech:xsd:pain.008.001.02 Document"`
+	GrpHdr  groupHeader         `xml:"CstmrDrctDbtInitn>GrpHdr"`
+	PmtInf  []directDebitPmtInf `xml:"CstmrDrctDbtInitn>PmtInf"`
+}
+
+type directDebitPmtInf struct {
+	PmtInfId     string             `xml:"PmtInfId"`
+	PmtMtd       string             `xml:"PmtMtd"`
+	NbOfTxs      string             `xml:"NbOfTxs"`
+	CtrlSum      string             `xml:"CtrlSum"`
+	SvcLvl       code               `xml:"PmtTpInf>SvcLvl"`
+	LclInstrm    code               `xml:"PmtTpInf>LclInstrm"`
+	SeqTp        string             `xml:"PmtTpInf>SeqTp"`
+	ReqdColltnDt string             `xml:"ReqdColltnDt"`
+	Cdtr         party              `xml:"Cdtr"`
+	CdtrAcct     account            `xml:"CdtrAcct"`
+	CdtrAgt      agent              `xml:"CdtrAgt"`
+	ChrgBr       string             `xml:"ChrgBr"`
+	CdtrSchmeId  string             `xml:"CdtrSchmeId>Id>PrvtId>Othr>Id"`
+	SchmeNm      string             `xml:"CdtrSchmeId>Id>PrvtId>Othr>SchmeNm>Prtry"`
+	DrctDbtTxInf []directDebitTxInf `xml:"DrctDbtTxInf"`
+}
+
+type directDebitTxInf struct {
+	PmtId     paymentId `xml:"PmtId"`
+	InstdAmt  amount    `xml:"InstdAmt"`
+	MndtId    string    `xml:"DrctDbtTx>MndtRltdInf>MndtId"`
+	DtOfSgntr string    `xml:"DrctDbtTx>MndtRltdInf>DtOfSgntr"`
+	DbtrAgt   agent     `xml:"DbtrAgt"`
+	Dbtr      party     `xml:"Dbtr"`
+	DbtrAcct  account   `xml:"DbtrAcct"`
+	Ustrd     string    `xml:"RmtInf>Ustrd,omitempty"`
+}
+
+// document converts the file to its xml structure
+func (file *DirectDebitFile) document() *pain008Document {
+	scheme := file.Scheme
+	if scheme == "" {
+		scheme = SchemeCore
+	}
+	doc := &pain008Document{GrpHdr: newGroupHeader(file.MessageId, file.Created, file.Creditor.Name)}
+	blocks := map[string]int{}
+	var cents int64
+	for _, debit := range file.DirectDebits {
+		transaction := debit.Transaction
+		if transaction == nil {
+			transaction = &twikey.Transaction{}
+		}
+		mandate := debit.Mandate
+		if mandate == nil {
+			mandate = &twikey.Mndt{}
+		}
+		sequenceType := debit.SequenceType
+		if sequenceType == "" {
+			sequenceType = SequenceRecurring
+		}
+		collectionDate := transaction.RequestedCollection
+		if collectionDate == "" {
+			collectionDate = file.CollectionDate
+		}
+
+		key := sequenceType + "/" + collectionDate
+		block, found := blocks[key]
+		if !found {
+			block = len(doc.PmtInf)
+			blocks[key] = block
+			doc.PmtInf = append(doc.PmtInf, directDebitPmtInf{
+				PmtInfId:     paymentInfoId(file.MessageId, block+1),
+				PmtMtd:       "DD",
+				SvcLvl:       code{Cd: "SEPA"},
+				LclInstrm:    code{Cd: scheme},
+				SeqTp:        sequenceType,
+				ReqdColltnDt: collectionDate,
+				Cdtr:         party{Nm: file.Creditor.Name},
+				CdtrAcct:     account{IBAN: normalizeIban(file.Creditor.Iban)},
+				CdtrAgt:      newAgent(file.Creditor.Bic),
+				ChrgBr:       "SLEV",
+				CdtrSchmeId:  file.CreditorSchemeId,
+				SchmeNm:      "SEPA",
+			})
+		}
+
+		tx := directDebitTxInf{
+			PmtId:     paymentId{EndToEndId: endToEndId(transaction.Ref)},
+			InstdAmt:  euro(transaction.Amount),
+			MndtId:    mandate.MndtId,
+			DtOfSgntr: debit.SignatureDate,
+			DbtrAgt:   newAgent(mandate.DbtrAgt.FinInstnId.BICFI),
+			Dbtr:      party{Nm: mandate.Dbtr.Nm},
+			DbtrAcct:  account{IBAN: normalizeIban(mandate.DbtrAcct)},
+			Ustrd:     transaction.Message,
+		}
+		if transaction.Id != 0 {
+			tx.PmtId.InstrId = strconv.FormatInt(transaction.Id, 10)
+		}
+		doc.PmtInf[block].DrctDbtTxInf = append(doc.PmtInf[block].DrctDbtTxInf, tx)
+		cents += toCents(tx.InstdAmt.Value)
+	}
+
+	for i := range doc.PmtInf {
+		block := &doc.PmtInf[i]
+		var blockCents int64
+		for _, tx := range block.DrctDbtTxInf {
+			blockCents += toCents(tx.InstdAmt.Value)
+		}
+		block.NbOfTxs = strconv.Itoa(len(block.DrctDbtTxInf))
+		block.CtrlSum = formatAmount(float64(blockCents) / 100)
+	}
+	doc.GrpHdr.NbOfTxs = strconv.Itoa(len(file.DirectDebits))
+	doc.GrpHdr.CtrlSum = formatAmount(float64(cents) / 100)
+	return doc
+}
+
+// validate checks the structural rules of the document, returning a twikey.ValidationError listing all problems
+func (doc *pain008Document) validate() error {
+	v := &validation{}
+	v.identifier("GrpHdr.MsgId", doc.GrpHdr.MsgId, true)
+	v.dateTime("GrpHdr.CreDtTm", doc.GrpHdr.CreDtTm)
+	v.text("GrpHdr.InitgPty.Nm", doc.GrpHdr.InitgPty.Nm, 70, true)
+	if len(doc.PmtInf) == 0 {
+		v.fail("PmtInf", "is required")
+	}
+
+	count := 0
+	var cents int64
+	ids := map[string]bool{}
+	for i, block := range doc.PmtInf {
+		prefix := fmt.Sprintf("PmtInf[%d].", i)
+		v.identifier(prefix+"PmtInfId", block.PmtInfId, true)
+		if ids[block.PmtInfId] {
+			v.fail(prefix+"PmtInfId", "is not unique")
+		}
+		ids[block.PmtInfId] = true
+		v.oneOf(prefix+"PmtMtd", block.PmtMtd, "DD")
+		v.oneOf(prefix+"PmtTpInf.SvcLvl.Cd", block.SvcLvl.Cd, "SEPA")
+		v.oneOf(prefix+"PmtTpInf.LclInstrm.Cd", block.LclInstrm.Cd, SchemeCore, SchemeB2B)
+		v.oneOf(prefix+"PmtTpInf.SeqTp", block.SeqTp, SequenceFirst, SequenceRecurring, SequenceOneOff, SequenceFinal)
+		v.date(prefix+"ReqdColltnDt", block.ReqdColltnDt)
+		v.text(prefix+"Cdtr.Nm", block.Cdtr.Nm, 70, true)
+		v.iban(prefix+"CdtrAcct.Id.IBAN", block.CdtrAcct.IBAN)
+		v.agent(prefix+"CdtrAgt", &block.CdtrAgt, true)
+		v.oneOf(prefix+"ChrgBr", block.ChrgBr, "SLEV")
+		v.creditorId(prefix+"CdtrSchmeId", block.CdtrSchmeId)
+		v.oneOf(prefix+"CdtrSchmeId.SchmeNm.Prtry", block.SchmeNm, "SEPA")
+		if len(block.DrctDbtTxInf) == 0 {
+			v.fail(prefix+"DrctDbtTxInf", "is required")
+		}
+
+		var blockCents int64
+		for j, tx := range block.DrctDbtTxInf {
+			txPrefix := fmt.Sprintf("%sDrctDbtTxInf[%d].", prefix, j)
+			v.identifier(txPrefix+"PmtId.InstrId", tx.PmtId.InstrId, false)
+			v.identifier(txPrefix+"PmtId.EndToEndId", tx.PmtId.EndToEndId, true)
+			blockCents += v.amount(txPrefix+"InstdAmt", tx.InstdAmt)
+			v.identifier(txPrefix+"MndtRltdInf.MndtId", tx.MndtId, true)
+			v.date(txPrefix+"MndtRltdInf.DtOfSgntr", tx.DtOfSgntr)
+			if tx.DtOfSgntr > block.ReqdColltnDt && block.ReqdColltnDt != "" {
+				v.fail(txPrefix+"MndtRltdInf.DtOfSgntr", "should not be after the collection date")
+			}
+			v.agent(txPrefix+"DbtrAgt", &tx.DbtrAgt, true)
+			v.text(txPrefix+"Dbtr.Nm", tx.Dbtr.Nm, 70, true)
+			v.iban(txPrefix+"DbtrAcct.Id.IBAN", tx.DbtrAcct.IBAN)
+			v.text(txPrefix+"RmtInf.Ustrd", tx.Ustrd, 140, false)
+		}
+		v.totals(prefix, block.NbOfTxs, block.CtrlSum, len(block.DrctDbtTxInf), blockCents)
+		count += len(block.DrctDbtTxInf)
+		cents += blockCents
+	}
+	v.totals("GrpHdr.", doc.GrpHdr.NbOfTxs, doc.GrpHdr.CtrlSum, count, cents)
+	return v.err()
+}
+
+// Validate checks the file against the structural rules of pain.008.001.02, the fields of the returned
+// twikey.ValidationError are the paths of the invalid elements in the xml
+func (file *DirectDebitFile) Validate() error {
+	return file.document().validate()
+}
+
+// WriteXML validates the file and writes it as pain.008.001.02 xml
+func (file *DirectDebitFile) WriteXML(w io.Writer) error {
+	doc := file.document()
+	if err := doc.validate(); err != nil {
+		return err
+	}
+	return writeDocument(w, doc)
+}
+
+// ParseDirectDebitFile reads a pain.008.001.02 file, it fails when the file doesn't follow the structural rules
+func ParseDirectDebitFile(r io.Reader) (*DirectDebitFile, error) {
+	doc := &pain008Document{}
+	if err := xml.NewDecoder(r).Decode(doc); err != nil {
+		return nil, fmt.Errorf("not a %s document: %w", pain008Namespace, err)
+	}
+	if err := doc.validate(); err != nil {
+		return nil, err
+	}
+
+	file := &DirectDebitFile{
+		MessageId: doc.GrpHdr.MsgId,
+		Created:   doc.GrpHdr.created(),
+	}
+	for i, block := range doc.PmtInf {
+		if i == 0 {
+			file.Creditor = Account{Name: block.Cdtr.Nm, Iban: block.CdtrAcct.IBAN, Bic: block.CdtrAgt.bic()}
+			file.CreditorSchemeId = block.CdtrSchmeId
+			file.Scheme = block.LclInstrm.Cd
+			file.CollectionDate = block.ReqdColltnDt
+		}
+		for _, tx := range block.DrctDbtTxInf {
+			transaction := &twikey.Transaction{
+				DocumentReference:   tx.MndtId,
+				Amount:              float64(toCents(tx.InstdAmt.Value)) / 100,
+				Message:             tx.Ustrd,
+				Ref:                 tx.PmtId.ref(),
+				RequestedCollection: block.ReqdColltnDt,
+			}
+			transaction.Id, _ = strconv.ParseInt(tx.PmtId.InstrId, 10, 64)
+			mandate := &twikey.Mndt{
+				MndtId:   tx.MndtId,
+				Dbtr:     twikey.Prty{Nm: tx.Dbtr.Nm},
+				DbtrAcct: tx.DbtrAcct.IBAN,
+				DbtrAgt:  twikey.DbtrAgt{FinInstnId: twikey.FinInstnId{BICFI: tx.DbtrAgt.bic()}},
+			}
+			file.DirectDebits = append(file.DirectDebits, DirectDebit{
+				Transaction:   transaction,
+				Mandate:       mandate,
+				SignatureDate: tx.DtOfSgntr,
+				SequenceType:  block.SeqTp,
+			})
+		}
+	}
+	return file, nil
+}
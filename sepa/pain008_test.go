@@ -0,0 +1,153 @@
+package sepa
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/twikey/twikey-api-go"
+)
+
+func directDebitFile() *DirectDebitFile {
+	mandate := func(id string, name string, account string, bic string) *twikey.Mndt {
+		return &twikey.Mndt{
+			MndtId:   id,
+			Dbtr:     twikey.Prty{Nm: name},
+			DbtrAcct: account,
+			DbtrAgt:  twikey.DbtrAgt{FinInstnId: twikey.FinInstnId{BICFI: bic}},
+		}
+	}
+	return &DirectDebitFile{
+		MessageId:        "TWIKEY-20240115-1",
+		Created:          time.Date(2024, 1, 10, 8, 30, 0, 0, time.Local),
+		Creditor:         Account{Name: "Twikey NV", Iban: "BE68 5390 0754 7034", Bic: "GEBABEBB"},
+		CreditorSchemeId: "BE69ZZZ050D000000008",
+		CollectionDate:   "2024-01-15",
+		DirectDebits: []DirectDebit{
+			{
+				Transaction:   &twikey.Transaction{Id: 101, Ref: "INV-1", Amount: 10.5, Message: "Invoice 1"},
+				Mandate:       mandate("MNDT1", "John Doe", "BE09363107700857", "BBRUBEBB"),
+				SignatureDate: "2023-12-01",
+			},
+			{
+				Transaction:   &twikey.Transaction{Id: 102, Amount: 20, Message: "Invoice 2"},
+				Mandate:       mandate("MNDT2", "Jane Doe", "NL91ABNA0417164300", ""),
+				SignatureDate: "2024-01-02",
+				SequenceType:  SequenceFirst,
+			},
+			{
+				Transaction:   &twikey.Transaction{Id: 103, Ref: "INV-3", Amount: 30.25, Message: "Invoice 3", RequestedCollection: "2024-01-20"},
+				Mandate:       mandate("MNDT3", "Acme", "BE09363107700857", "BBRUBEBB"),
+				SignatureDate: "2023-06-01",
+			},
+		},
+	}
+}
+
+func TestDirectDebitRoundTrip(t *testing.T) {
+	file := directDebitFile()
+	var out bytes.Buffer
+	if err := file.WriteXML(&out); err != nil {
+		t.Fatal(err)
+	}
+	content := out.String()
+	for _, expected := range []string{
+		`<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.008.001.02">`,
+		`<NbOfTxs>3</NbOfTxs>`,
+		`<CtrlSum>60.75</CtrlSum>`,
+		`<SeqTp>FRST</SeqTp>`,
+		`<InstdAmt Ccy="EUR">10.50</InstdAmt>`,
+		`<EndToEndId>NOTPROVIDED</EndToEndId>`,
+		`<Prtry>SEPA</Prtry>`,
+		`<Id>BE69ZZZ050D000000008</Id>`,
+		`<IBAN>BE68539007547034</IBAN>`,
+	} {
+		if !strings.Contains(content, expected) {
+			t.Errorf("Expected %s in %s", expected, content)
+		}
+	}
+	// one block per sequence type and collection date
+	if count := strings.Count(content, "<PmtInf>"); count != 3 {
+		t.Errorf("Expected 3 payment blocks got %d", count)
+	}
+
+	parsed, err := ParseDirectDebitFile(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.MessageId != file.MessageId || !parsed.Created.Equal(file.Created) || parsed.CreditorSchemeId != file.CreditorSchemeId {
+		t.Errorf("Unexpected header %+v", parsed)
+	}
+	if parsed.Creditor.Iban != "BE68539007547034" || parsed.Creditor.Bic != "GEBABEBB" || parsed.Scheme != SchemeCore {
+		t.Errorf("Unexpected creditor %+v", parsed.Creditor)
+	}
+	if len(parsed.DirectDebits) != 3 {
+		t.Fatalf("Expected 3 debits got %d", len(parsed.DirectDebits))
+	}
+	byId := map[int64]DirectDebit{}
+	for _, debit := range parsed.DirectDebits {
+		byId[debit.Transaction.Id] = debit
+	}
+	first := byId[101]
+	if first.Transaction.Ref != "INV-1" || first.Transaction.Amount != 10.5 || first.Transaction.Message != "Invoice 1" ||
+		first.Transaction.DocumentReference != "MNDT1" || first.Transaction.RequestedCollection != "2024-01-15" {
+		t.Errorf("Unexpected transaction %+v", first.Transaction)
+	}
+	if first.Mandate.Dbtr.Nm != "John Doe" || first.Mandate.DbtrAcct != "BE09363107700857" || first.Mandate.DbtrAgt.FinInstnId.BICFI != "BBRUBEBB" {
+		t.Errorf("Unexpected mandate %+v", first.Mandate)
+	}
+	second := byId[102]
+	if second.Transaction.Ref != "" || second.Mandate.DbtrAgt.FinInstnId.BICFI != "" || second.SequenceType != SequenceFirst || second.SignatureDate != "2024-01-02" {
+		t.Errorf("Unexpected debit %+v %+v", second.Transaction, second.Mandate)
+	}
+	if byId[103].Transaction.RequestedCollection != "2024-01-20" {
+		t.Errorf("Unexpected collection date %s", byId[103].Transaction.RequestedCollection)
+	}
+}
+
+func TestDirectDebitValidation(t *testing.T) {
+	file := directDebitFile()
+	file.CreditorSchemeId = "BE68ZZZ050D000000008"
+	file.DirectDebits[0].Transaction.Amount = -1
+	file.DirectDebits[0].Transaction.Ref = "INV//1"
+	file.DirectDebits[1].Mandate.DbtrAcct = "BE09363107700858"
+	file.DirectDebits[1].SignatureDate = "2024-02-01"
+	file.DirectDebits[2].SequenceType = "NEXT"
+
+	err := file.WriteXML(&bytes.Buffer{})
+	verr, ok := err.(*twikey.ValidationError)
+	if !ok {
+		t.Fatalf("Expected a validation error got %v", err)
+	}
+	for _, field := range []string{
+		"PmtInf[0].CdtrSchmeId",
+		"PmtInf[0].DrctDbtTxInf[0].InstdAmt",
+		"PmtInf[0].DrctDbtTxInf[0].PmtId.EndToEndId",
+		"PmtInf[1].DrctDbtTxInf[0].DbtrAcct.Id.IBAN",
+		"PmtInf[1].DrctDbtTxInf[0].MndtRltdInf.DtOfSgntr",
+		"PmtInf[2].PmtTpInf.SeqTp",
+	} {
+		if !verr.HasField(field) {
+			t.Errorf("Expected %s to be invalid: %v", field, err)
+		}
+	}
+}
+
+func TestParseDirectDebitFileChecksTotals(t *testing.T) {
+	var out bytes.Buffer
+	if err := directDebitFile().WriteXML(&out); err != nil {
+		t.Fatal(err)
+	}
+	tampered := strings.Replace(out.String(), `<InstdAmt Ccy="EUR">20.00</InstdAmt>`, `<InstdAmt Ccy="EUR">200.00</InstdAmt>`, 1)
+	_, err := ParseDirectDebitFile(strings.NewReader(tampered))
+	verr, ok := err.(*twikey.ValidationError)
+	if !ok || !verr.HasField("GrpHdr.CtrlSum") || !verr.HasField("PmtInf[1].CtrlSum") {
+		t.Errorf("Expected invalid control sums got %v", err)
+	}
+
+	other := strings.Replace(out.String(), "pain.008.001.02", "pain.008.001.08", 1)
+	if _, err = ParseDirectDebitFile(strings.NewReader(other)); err == nil {
+		t.Error("Expected an error for another version")
+	}
+}
@@ -0,0 +1,308 @@
+// Package sepa writes and reads the SEPA XML files exchanged with banks: pain.008.001.02 for the direct debits
+// of a collection and pain.001.001.03 for credit transfers like refunds. Files are checked against the
+// structural rules of the EPC implementation guidelines both when writing and when parsing, so the files sent
+// by Twikey to the bank can be cross-checked with the transactions and refunds of the api.
+package sepa
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/twikey/twikey-api-go"
+	"github.com/twikey/twikey-api-go/iban"
+)
+
+// NotProvided is used by SEPA for mandatory identifiers that are unknown, eg. an end to end id
+const NotProvided = "NOTPROVIDED"
+
+// Account identifies a party and its bank account
+type Account struct {
+	Name string
+	Iban string
+	Bic  string // optional for accounts within the EEA
+}
+
+func normalizeIban(value string) string {
+	return iban.Normalize(value)
+}
+
+// writeDocument writes the document as indented xml
+func writeDocument(w io.Writer, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// dateTimeLayout is the layout of the creation date of a file
+const dateTimeLayout = "2006-01-02T15:04:05"
+
+type party struct {
+	Nm string `xml:"Nm"`
+}
+
+type account struct {
+	IBAN string `xml:"Id>IBAN"`
+}
+
+type other struct {
+	Id string `xml:"Id"`
+}
+
+type agent struct {
+	BIC  string `xml:"FinInstnId>BIC,omitempty"`
+	Othr *other `xml:"FinInstnId>Othr,omitempty"`
+}
+
+// newAgent identifies the bank by its bic or as not provided as allowed within the EEA
+func newAgent(bic string) agent {
+	bic = strings.ToUpper(strings.TrimSpace(bic))
+	if bic == "" {
+		return agent{Othr: &other{Id: NotProvided}}
+	}
+	return agent{BIC: bic}
+}
+
+func (a *agent) bic() string {
+	if a == nil {
+		return ""
+	}
+	return a.BIC
+}
+
+type code struct {
+	Cd string `xml:"Cd"`
+}
+
+type amount struct {
+	Ccy   string `xml:"Ccy,attr"`
+	Value string `xml:",chardata"`
+}
+
+func euro(value float64) amount {
+	return amount{Ccy: "EUR", Value: formatAmount(value)}
+}
+
+func formatAmount(value float64) string {
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
+
+// paymentId contains the identifiers of a single payment
+type paymentId struct {
+	InstrId    string `xml:"InstrId,omitempty"`
+	EndToEndId string `xml:"EndToEndId"`
+}
+
+func endToEndId(ref string) string {
+	if ref == "" {
+		return NotProvided
+	}
+	return ref
+}
+
+func (id paymentId) ref() string {
+	if id.EndToEndId == NotProvided {
+		return ""
+	}
+	return id.EndToEndId
+}
+
+type groupHeader struct {
+	MsgId    string `xml:"MsgId"`
+	CreDtTm  string `xml:"CreDtTm"`
+	NbOfTxs  string `xml:"NbOfTxs"`
+	CtrlSum  string `xml:"CtrlSum"`
+	InitgPty party  `xml:"InitgPty"`
+}
+
+func newGroupHeader(msgId string, created time.Time, name string) groupHeader {
+	if created.IsZero() {
+		created = time.Now()
+	}
+	return groupHeader{
+		MsgId:    msgId,
+		CreDtTm:  created.Format(dateTimeLayout),
+		InitgPty: party{Nm: name},
+	}
+}
+
+func (header *groupHeader) created() time.Time {
+	created, _ := parseDateTime(header.CreDtTm)
+	return created
+}
+
+func parseDateTime(value string) (time.Time, error) {
+	if created, err := time.ParseInLocation(dateTimeLayout, value, time.Local); err == nil {
+		return created, nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// paymentInfoId returns the id of the n-th payment information block, based on the message id
+func paymentInfoId(msgId string, n int) string {
+	suffix := "-" + strconv.Itoa(n)
+	if len(msgId)+len(suffix) > 35 {
+		msgId = msgId[:35-len(suffix)]
+	}
+	return msgId + suffix
+}
+
+// validation collects the problems found in a file, the field is the path of the element in the xml
+type validation struct {
+	fields []twikey.FieldError
+}
+
+func (v *validation) fail(field string, msg string) {
+	v.fields = append(v.fields, twikey.FieldError{Field: field, Message: msg})
+}
+
+func (v *validation) err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &twikey.ValidationError{Fields: v.fields}
+}
+
+// text checks a free text element of at most max characters
+func (v *validation) text(field string, value string, max int, required bool) {
+	if strings.TrimSpace(value) == "" {
+		if required {
+			v.fail(field, "is required")
+		}
+		return
+	}
+	if utf8.RuneCountInString(value) > max {
+		v.fail(field, fmt.Sprintf("should be at most %d characters", max))
+	}
+}
+
+// sepaIdentifier contains the characters allowed in identifiers, which can't start or end with a slash
+// nor contain a double slash
+var sepaIdentifier = regexp.MustCompile(`^[A-Za-z0-9+?/:().,' -]+$`)
+
+func (v *validation) identifier(field string, value string, required bool) {
+	v.text(field, value, 35, required)
+	if value != "" && (!sepaIdentifier.MatchString(value) || strings.HasPrefix(value, "/") || strings.HasSuffix(value, "/") || strings.Contains(value, "//")) {
+		v.fail(field, "contains characters not allowed in a SEPA identifier")
+	}
+}
+
+func (v *validation) oneOf(field string, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.fail(field, "should be one of "+strings.Join(allowed, ", "))
+}
+
+func (v *validation) date(field string, value string) {
+	if value == "" {
+		v.fail(field, "is required")
+	} else if _, err := time.Parse("2006-01-02", value); err != nil {
+		v.fail(field, "should be formatted as yyyy-mm-dd")
+	}
+}
+
+func (v *validation) dateTime(field string, value string) {
+	if value == "" {
+		v.fail(field, "is required")
+	} else if _, err := parseDateTime(value); err != nil {
+		v.fail(field, "should be formatted as yyyy-mm-ddThh:mm:ss")
+	}
+}
+
+var (
+	instructedAmount = regexp.MustCompile(`^[0-9]{1,9}(\.[0-9]{1,2})?$`)
+	controlSum       = regexp.MustCompile(`^[0-9]{1,16}(\.[0-9]{1,2})?$`)
+)
+
+// amount checks an instructed amount and returns it in cents
+func (v *validation) amount(field string, value amount) int64 {
+	if value.Ccy != "EUR" {
+		v.fail(field, "should be in EUR")
+	}
+	if !instructedAmount.MatchString(value.Value) {
+		v.fail(field, "should be between 0.01 and 999999999.99 with at most 2 decimals")
+		return 0
+	}
+	cents := toCents(value.Value)
+	if cents == 0 {
+		v.fail(field, "should be between 0.01 and 999999999.99 with at most 2 decimals")
+	}
+	return cents
+}
+
+// totals checks the number of transactions and the control sum of a group or a payment information block
+func (v *validation) totals(prefix string, nbOfTxs string, ctrlSum string, count int, cents int64) {
+	if nbOfTxs != strconv.Itoa(count) {
+		v.fail(prefix+"NbOfTxs", fmt.Sprintf("is %s while the file contains %d transactions", nbOfTxs, count))
+	}
+	if !controlSum.MatchString(ctrlSum) {
+		v.fail(prefix+"CtrlSum", "is not a valid amount")
+	} else if toCents(ctrlSum) != cents {
+		v.fail(prefix+"CtrlSum", fmt.Sprintf("is %s while the transactions add up to %s", ctrlSum, formatAmount(float64(cents)/100)))
+	}
+}
+
+func toCents(value string) int64 {
+	parts := strings.SplitN(value, ".", 2)
+	units, _ := strconv.ParseInt(parts[0], 10, 64)
+	cents := int64(0)
+	if len(parts) == 2 {
+		decimals := (parts[1] + "0")[:2]
+		cents, _ = strconv.ParseInt(decimals, 10, 64)
+	}
+	return units*100 + cents
+}
+
+func (v *validation) iban(field string, value string) {
+	if value == "" {
+		v.fail(field, "is required")
+	} else if err := iban.Validate(value); err != nil {
+		v.fail(field, err.Error())
+	} else if !iban.IsSEPA(value) {
+		v.fail(field, "is not reachable via SEPA")
+	}
+}
+
+func (v *validation) agent(field string, value *agent, required bool) {
+	if value == nil {
+		if required {
+			v.fail(field, "is required")
+		}
+		return
+	}
+	if value.BIC != "" {
+		if err := iban.ValidateBIC(value.BIC); err != nil {
+			v.fail(field+".BIC", err.Error())
+		}
+	} else if value.Othr == nil || value.Othr.Id != NotProvided {
+		v.fail(field, "should contain a BIC or "+NotProvided)
+	}
+}
+
+// creditorSchemeId checks the format and the checksum of a creditor identifier (eg. BE69ZZZ050D000000008)
+var creditorSchemeId = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{3}[A-Z0-9]{1,28}$`)
+
+func (v *validation) creditorId(field string, value string) {
+	if value == "" {
+		v.fail(field, "is required")
+		return
+	}
+	if !creditorSchemeId.MatchString(value) || iban.Mod97(value[7:]+value[:4]) != 1 {
+		v.fail(field, "is not a valid creditor identifier")
+	}
+}
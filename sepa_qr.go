@@ -0,0 +1,191 @@
+package twikey
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/twikey/twikey-api-go/iban"
+	"github.com/twikey/twikey-api-go/qrcode"
+)
+
+// SepaQR holds the content of an EPC QR code (EPC069-12, also known as "GiroCode" or "Scan to pay"), which
+// allows a customer to pay via SEPA credit transfer by scanning it in a banking app
+type SepaQR struct {
+	Bic         string  // Bic of the beneficiary (optional within the EEA)
+	Name        string  // Name of the beneficiary
+	Iban        string  // Account of the beneficiary
+	Amount      float64 // Amount in euro, 0 lets the payer fill in the amount
+	Purpose     string  // Optional 4 letter purpose code (eg. GDDS)
+	Reference   string  // Structured creditor reference (RF...), can't be combined with Remittance
+	Remittance  string  // Unstructured remittance information, can't be combined with Reference
+	Information string  // Information shown to the payer
+}
+
+// sepaQRMaxPayload is the maximum size in bytes of the payload allowed by the EPC guidelines
+const sepaQRMaxPayload = 331
+
+func (qr *SepaQR) Validate() error {
+	v := &validation{}
+	v.required("Name", qr.Name)
+	maxLength(v, "Name", qr.Name, 70)
+	v.required("Iban", qr.Iban)
+	v.account("Iban", qr.Iban, "Bic", qr.Bic)
+	if qr.Iban != "" && iban.IsValid(qr.Iban) && !iban.IsSEPA(qr.Iban) {
+		v.fail("Iban", "is not reachable via SEPA")
+	}
+	if qr.Amount < 0 || qr.Amount > 999999999.99 || (qr.Amount != 0 && roundAmount(qr.Amount) < 0.01) {
+		v.fail("Amount", "should be between 0.01 and 999999999.99")
+	}
+	if qr.Purpose != "" && (len(qr.Purpose) != 4 || strings.IndexFunc(qr.Purpose, func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9')
+	}) != -1) {
+		v.fail("Purpose", "should be a 4 character purpose code")
+	}
+	if qr.Reference != "" {
+		if qr.Remittance != "" {
+			v.fail("Reference", "can't be combined with Remittance")
+		}
+		if !IsCreditorReference(qr.Reference) {
+			v.fail("Reference", "is not a valid creditor reference")
+		}
+	}
+	maxLength(v, "Remittance", qr.Remittance, 140)
+	maxLength(v, "Information", qr.Information, 70)
+	if len(v.fields) == 0 && len(qr.Payload()) > sepaQRMaxPayload {
+		v.fail("Payload", fmt.Sprintf("exceeds %d bytes", sepaQRMaxPayload))
+	}
+	return v.err()
+}
+
+func maxLength(v *validation, field string, value string, max int) {
+	if utf8.RuneCountInString(value) > max {
+		v.fail(field, fmt.Sprintf("should be at most %d characters", max))
+	}
+}
+
+// Payload returns the text encoded in the QR code
+func (qr *SepaQR) Payload() string {
+	amount := ""
+	if qr.Amount != 0 {
+		amount = fmt.Sprintf("EUR%.2f", roundAmount(qr.Amount))
+	}
+	lines := []string{
+		"BCD", // service tag
+		"002", // version
+		"1",   // character set (UTF-8)
+		"SCT", // identification
+		strings.ToUpper(strings.TrimSpace(qr.Bic)),
+		qr.Name,
+		iban.Normalize(qr.Iban),
+		amount,
+		strings.ToUpper(qr.Purpose),
+		strings.ToUpper(strings.Join(strings.Fields(qr.Reference), "")),
+		qr.Remittance,
+		qr.Information,
+	}
+	// trailing empty elements may be omitted
+	for lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Code validates the content and encodes it as QR code, using error correction level M as required by the EPC
+func (qr *SepaQR) Code() (*qrcode.Code, error) {
+	if err := qr.Validate(); err != nil {
+		return nil, err
+	}
+	return qrcode.Encode([]byte(qr.Payload()), qrcode.Medium)
+}
+
+// PNG writes the QR code as png image of scale pixels per module
+func (qr *SepaQR) PNG(w io.Writer, scale int) error {
+	code, err := qr.Code()
+	if err != nil {
+		return err
+	}
+	return code.PNG(w, scale)
+}
+
+// SVG writes the QR code as svg image of scale pixels per module
+func (qr *SepaQR) SVG(w io.Writer, scale int) error {
+	code, err := qr.Code()
+	if err != nil {
+		return err
+	}
+	return code.SVG(w, scale)
+}
+
+// IsCreditorReference checks the format and checksum of a structured creditor reference (ISO 11649, eg. RF18539007547034)
+func IsCreditorReference(ref string) bool {
+	ref = strings.ToUpper(strings.Join(strings.Fields(ref), ""))
+	if len(ref) < 5 || len(ref) > 25 || !strings.HasPrefix(ref, "RF") {
+		return false
+	}
+	return iban.Mod97(ref[4:]+ref[:4]) == 1
+}
+
+// SepaQR returns the QR code allowing the customer to pay the invoice via credit transfer to the given account.
+// The remittance (or the number) of the invoice is passed as structured reference when it is a creditor reference.
+func (inv *Invoice) SepaQR(name string, account string, bic string) *SepaQR {
+	qr := &SepaQR{
+		Bic:    bic,
+		Name:   name,
+		Iban:   account,
+		Amount: inv.Amount,
+	}
+	remittance := inv.Remittance
+	if remittance == "" {
+		remittance = inv.Number
+	}
+	if IsCreditorReference(remittance) {
+		qr.Reference = remittance
+	} else {
+		qr.Remittance = remittance
+	}
+	return qr
+}
+
+// SepaQR returns the QR code allowing the customer to pay the amount of the paylink via credit transfer to the
+// given account, as an alternative to opening the link
+func (paylink *Paylink) SepaQR(name string, account string, bic string) *SepaQR {
+	remittance := paylink.Msg
+	if remittance == "" {
+		remittance = paylink.Ref
+	}
+	return &SepaQR{
+		Bic:        bic,
+		Name:       name,
+		Iban:       account,
+		Amount:     paylink.Amount,
+		Remittance: remittance,
+	}
+}
+
+// QRCode returns the QR code of the url of the paylink, allowing the customer to open it by scanning it
+func (paylink *Paylink) QRCode() (*qrcode.Code, error) {
+	if paylink.Url == "" {
+		return nil, NewTwikeyError("err_invalid_paylink", "The paylink has no url", "")
+	}
+	return qrcode.Encode([]byte(paylink.Url), qrcode.Medium)
+}
+
+// PNG writes the QR code of the url of the paylink as png image of scale pixels per module
+func (paylink *Paylink) PNG(w io.Writer, scale int) error {
+	code, err := paylink.QRCode()
+	if err != nil {
+		return err
+	}
+	return code.PNG(w, scale)
+}
+
+// SVG writes the QR code of the url of the paylink as svg image of scale pixels per module
+func (paylink *Paylink) SVG(w io.Writer, scale int) error {
+	code, err := paylink.QRCode()
+	if err != nil {
+		return err
+	}
+	return code.SVG(w, scale)
+}
@@ -0,0 +1,118 @@
+package twikey
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestSepaQRPayload(t *testing.T) {
+	qr := &SepaQR{
+		Bic:         "gebabebb",
+		Name:        "Twikey NV",
+		Iban:        "BE68 5390 0754 7034",
+		Amount:      12.3,
+		Reference:   "RF18 5390 0754 7034",
+		Information: "Thank you",
+	}
+	if err := qr.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, "BCD\n002\n1\nSCT\nGEBABEBB\nTwikey NV\nBE68539007547034\nEUR12.30\n\nRF18539007547034\n\nThank you", qr.Payload())
+
+	// trailing empty elements are omitted
+	qr = &SepaQR{Name: "Twikey NV", Iban: "BE68539007547034"}
+	AssertEquals(t, "BCD\n002\n1\nSCT\n\nTwikey NV\nBE68539007547034", qr.Payload())
+}
+
+func TestSepaQRValidation(t *testing.T) {
+	qr := &SepaQR{
+		Bic:        "GEBA",
+		Iban:       "BE68539007547035",
+		Amount:     1000000000,
+		Purpose:    "GOODS",
+		Reference:  "RF19539007547034",
+		Remittance: strings.Repeat("x", 141),
+	}
+	err := qr.Validate()
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Expected a validation error got %v", err)
+	}
+	for _, field := range []string{"Name", "Iban", "Bic", "Amount", "Purpose", "Reference", "Remittance"} {
+		if !verr.HasField(field) {
+			t.Errorf("Expected %s to be invalid: %v", field, err)
+		}
+	}
+
+	// Swiss accounts are reachable via SEPA
+	qr = &SepaQR{Name: "Twikey", Iban: "CH9300762011623852957"}
+	if err = qr.Validate(); err != nil {
+		t.Error(err)
+	}
+	if _, err = qr.Code(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIsCreditorReference(t *testing.T) {
+	AssertEquals(t, true, IsCreditorReference("RF18 5390 0754 7034"))
+	AssertEquals(t, true, IsCreditorReference("rf18539007547034"))
+	AssertEquals(t, false, IsCreditorReference("RF19539007547034"))
+	AssertEquals(t, false, IsCreditorReference("+++090/9337/55493+++"))
+	AssertEquals(t, false, IsCreditorReference("Invoice 123"))
+}
+
+func TestInvoiceAndPaylinkSepaQR(t *testing.T) {
+	invoice := &Invoice{Number: "Inv-123", Amount: 100, Remittance: "RF18539007547034"}
+	qr := invoice.SepaQR("Twikey NV", "BE68539007547034", "")
+	AssertEquals(t, "RF18539007547034", qr.Reference)
+	AssertEquals(t, "", qr.Remittance)
+
+	invoice = &Invoice{Number: "Inv-123", Amount: 100}
+	qr = invoice.SepaQR("Twikey NV", "BE68539007547034", "")
+	AssertEquals(t, "Inv-123", qr.Remittance)
+	AssertEquals(t, 100.0, qr.Amount)
+
+	paylink := &Paylink{Id: 1, Amount: 10.5, Msg: "Order 42"}
+	qr = paylink.SepaQR("Twikey NV", "BE68539007547034", "GEBABEBB")
+	AssertEquals(t, "BCD\n002\n1\nSCT\nGEBABEBB\nTwikey NV\nBE68539007547034\nEUR10.50\n\n\nOrder 42", qr.Payload())
+
+	var out bytes.Buffer
+	if err := qr.PNG(&out, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := png.Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := qr.SVG(&out, 2); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "<svg") {
+		t.Error("Expected an svg")
+	}
+}
+
+func TestPaylinkQRCode(t *testing.T) {
+	paylink := &Paylink{Id: 1, Amount: 10.5, Url: "https://mycompany.twikey.com/payment/1"}
+	var out bytes.Buffer
+	if err := paylink.PNG(&out, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := png.Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := paylink.SVG(&out, 2); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "<svg") {
+		t.Error("Expected an svg")
+	}
+
+	if _, err := (&Paylink{Id: 2}).QRCode(); err == nil {
+		t.Error("Expected a paylink without url to be refused")
+	}
+}
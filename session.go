@@ -47,6 +47,8 @@ func generateOtp(_salt string, _privKey string) (int, error) {
 }
 
 func (c *Client) refreshTokenIfRequired() error {
+	c.session.Lock()
+	defer c.session.Unlock()
 
 	if c.TimeProvider.Now().Sub(c.lastLogin).Hours() < 23 {
 		return nil
@@ -92,11 +94,25 @@ func (c *Client) refreshTokenIfRequired() error {
 	return err
 }
 
+// token returns the api token of the current session
+func (c *Client) token() string {
+	c.session.Lock()
+	defer c.session.Unlock()
+	return c.apiToken
+}
+
+// forceLogin makes the next call authenticate again
+func (c *Client) forceLogin() {
+	c.session.Lock()
+	c.lastLogin = time.Time{}
+	c.session.Unlock()
+}
+
 func (c *Client) logout() {
 	req, _ := http.NewRequest(http.MethodGet, c.BaseURL+"/creditor", nil)
 	req.Header.Set("User-Agent", c.UserAgent)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Authorization", c.apiToken)
+	req.Header.Set("Authorization", c.token())
 
 	res, err := c.HTTPClient.Do(req)
 	if err != nil {
@@ -1,8 +1,12 @@
 package twikey
 
 import (
+	"context"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -53,3 +57,32 @@ func TestClient_refreshTokenIfRequired(t *testing.T) {
 		t.Error("First should not equal second")
 	}
 }
+
+func TestConcurrentLogin(t *testing.T) {
+	var logins int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/creditor" {
+			atomic.AddInt32(&logins, 1)
+			w.Header().Set("Authorization", "api-token")
+			return
+		}
+		AssertEquals(t, "api-token", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	c := NewClient("TEST_API_KEY", WithBaseURL(server.URL))
+
+	// all calls share the expired session, only one of them logs in
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.CustomerDetail(context.Background(), "cst1"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	AssertEquals(t, int32(1), atomic.LoadInt32(&logins))
+}
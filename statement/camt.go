@@ -0,0 +1,185 @@
+package statement
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// camtDocument covers both CAMT.053 (statements) and CAMT.054 (notifications) regardless of their version,
+// as the elements used are the same in all versions
+type camtDocument struct {
+	XMLName       xml.Name     `xml:"Document"`
+	Statements    []camtReport `xml:"BkToCstmrStmt>Stmt"`
+	Notifications []camtReport `xml:"BkToCstmrDbtCdtNtfctn>Ntfctn"`
+}
+
+type camtReport struct {
+	Iban    string      `xml:"Acct>Id>IBAN"`
+	Entries []camtEntry `xml:"Ntry"`
+}
+
+type camtAmount struct {
+	Ccy   string `xml:"Ccy,attr"`
+	Value string `xml:",chardata"`
+}
+
+// camtDate is either a date or a date time
+type camtDate struct {
+	Dt   string `xml:"Dt"`
+	DtTm string `xml:"DtTm"`
+}
+
+func (date camtDate) value() string {
+	if date.Dt != "" {
+		return isoDate(date.Dt)
+	}
+	return isoDate(date.DtTm)
+}
+
+// camtStatus is a plain code before version 6 and a Cd element afterwards
+type camtStatus struct {
+	Value string `xml:",chardata"`
+	Cd    string `xml:"Cd"`
+}
+
+func (status camtStatus) code() string {
+	if status.Cd != "" {
+		return status.Cd
+	}
+	return strings.TrimSpace(status.Value)
+}
+
+type camtEntry struct {
+	Amt         camtAmount      `xml:"Amt"`
+	CdtDbtInd   string          `xml:"CdtDbtInd"`
+	RvslInd     bool            `xml:"RvslInd"`
+	Sts         camtStatus      `xml:"Sts"`
+	BookgDt     camtDate        `xml:"BookgDt"`
+	ValDt       camtDate        `xml:"ValDt"`
+	AcctSvcrRef string          `xml:"AcctSvcrRef"`
+	Details     []camtTxDetails `xml:"NtryDtls>TxDtls"`
+}
+
+// camtParty holds the name of a party, directly (before version 8) or within Pty
+type camtParty struct {
+	Nm    string `xml:"Nm"`
+	PtyNm string `xml:"Pty>Nm"`
+}
+
+func (party camtParty) name() string {
+	if party.Nm != "" {
+		return party.Nm
+	}
+	return party.PtyNm
+}
+
+type camtTxDetails struct {
+	EndToEndId  string     `xml:"Refs>EndToEndId"`
+	AcctSvcrRef string     `xml:"Refs>AcctSvcrRef"`
+	MndtId      string     `xml:"Refs>MndtId"`
+	Amt         camtAmount `xml:"Amt"`
+	TxAmt       camtAmount `xml:"AmtDtls>TxAmt>Amt"`
+	CdtDbtInd   string     `xml:"CdtDbtInd"`
+	Dbtr        camtParty  `xml:"RltdPties>Dbtr"`
+	DbtrIban    string     `xml:"RltdPties>DbtrAcct>Id>IBAN"`
+	Cdtr        camtParty  `xml:"RltdPties>Cdtr"`
+	CdtrIban    string     `xml:"RltdPties>CdtrAcct>Id>IBAN"`
+	Ustrd       []string   `xml:"RmtInf>Ustrd"`
+	CdtrRef     string     `xml:"RmtInf>Strd>CdtrRefInf>Ref"`
+	RtrRsn      string     `xml:"RtrInf>Rsn>Cd"`
+}
+
+// ParseCAMT reads the booked entries of a CAMT.053 statement or CAMT.054 notification (any version). Pending
+// entries are left out.
+func ParseCAMT(r io.Reader) ([]Entry, error) {
+	doc := &camtDocument{}
+	if err := xml.NewDecoder(r).Decode(doc); err != nil {
+		return nil, fmt.Errorf("not a camt document: %w", err)
+	}
+	reports := append(doc.Statements, doc.Notifications...)
+	if len(reports) == 0 {
+		return nil, errors.New("not a camt.053 or camt.054 document")
+	}
+
+	var entries []Entry
+	position := 0
+	for _, report := range reports {
+		for _, ntry := range report.Entries {
+			position++
+			if status := ntry.Sts.code(); status != "" && status != "BOOK" {
+				continue
+			}
+			entry := Entry{
+				Account:       report.Iban,
+				Credit:        ntry.CdtDbtInd == "CRDT",
+				Currency:      ntry.Amt.Ccy,
+				BookingDate:   ntry.BookgDt.value(),
+				ValueDate:     ntry.ValDt.value(),
+				BankReference: ntry.AcctSvcrRef,
+				Reversal:      ntry.RvslInd,
+				Position:      position,
+			}
+			amount, err := parseCamtAmount(ntry.Amt)
+			if err != nil {
+				return nil, fmt.Errorf("entry %d: %w", position, err)
+			}
+			if len(ntry.Details) == 0 {
+				entry.Amount = amount
+				entries = append(entries, entry)
+				continue
+			}
+			for _, details := range ntry.Details {
+				detail := entry
+				if len(ntry.Details) == 1 {
+					detail.Amount = amount
+				} else if detail.Amount, err = details.amount(); err != nil {
+					return nil, fmt.Errorf("entry %d: %w", position, err)
+				}
+				if details.CdtDbtInd != "" {
+					detail.Credit = details.CdtDbtInd == "CRDT"
+				}
+				if details.AcctSvcrRef != "" {
+					detail.BankReference = details.AcctSvcrRef
+				}
+				detail.EndToEndId = details.EndToEndId
+				if detail.EndToEndId == "NOTPROVIDED" {
+					detail.EndToEndId = ""
+				}
+				detail.MandateId = details.MndtId
+				detail.Remittance = strings.TrimSpace(strings.Join(details.Ustrd, " "))
+				if detail.Remittance == "" {
+					detail.Remittance = details.CdtrRef
+				}
+				detail.ReturnReason = details.RtrRsn
+				// the counterparty of money received is the debtor
+				if detail.Credit {
+					detail.CounterpartyName, detail.CounterpartyIban = details.Dbtr.name(), details.DbtrIban
+				} else {
+					detail.CounterpartyName, detail.CounterpartyIban = details.Cdtr.name(), details.CdtrIban
+				}
+				entries = append(entries, detail)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// amount returns the amount of the details, which is only provided separately for batch bookings
+func (details *camtTxDetails) amount() (float64, error) {
+	if details.Amt.Value != "" {
+		return parseCamtAmount(details.Amt)
+	}
+	return parseCamtAmount(details.TxAmt)
+}
+
+func parseCamtAmount(amount camtAmount) (float64, error) {
+	value, err := strconv.ParseFloat(strings.TrimSpace(amount.Value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", amount.Value)
+	}
+	return value, nil
+}
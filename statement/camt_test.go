@@ -0,0 +1,132 @@
+package statement
+
+import (
+	"strings"
+	"testing"
+)
+
+const camt053 = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+  <BkToCstmrStmt>
+    <GrpHdr><MsgId>STMT-1</MsgId></GrpHdr>
+    <Stmt>
+      <Id>1</Id>
+      <Acct><Id><IBAN>BE68539007547034</IBAN></Id></Acct>
+      <Ntry>
+        <Amt Ccy="EUR">20.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><Dt>2024-01-16</Dt></BookgDt>
+        <ValDt><Dt>2024-01-16</Dt></ValDt>
+        <AcctSvcrRef>BANK-1</AcctSvcrRef>
+        <NtryDtls>
+          <TxDtls>
+            <Refs><EndToEndId>T1</EndToEndId><MndtId>MNDT1</MndtId></Refs>
+            <AmtDtls><TxAmt><Amt Ccy="EUR">12.50</Amt></TxAmt></AmtDtls>
+            <RltdPties><Dbtr><Nm>John Doe</Nm></Dbtr><DbtrAcct><Id><IBAN>BE09363107700857</IBAN></Id></DbtrAcct></RltdPties>
+            <RmtInf><Ustrd>Invoice 1</Ustrd></RmtInf>
+          </TxDtls>
+          <TxDtls>
+            <Refs><EndToEndId>NOTPROVIDED</EndToEndId></Refs>
+            <AmtDtls><TxAmt><Amt Ccy="EUR">7.50</Amt></TxAmt></AmtDtls>
+            <RmtInf><Strd><CdtrRefInf><Ref>RF18539007547034</Ref></CdtrRefInf></Strd></RmtInf>
+          </TxDtls>
+        </NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">5.00</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><DtTm>2024-01-17T10:00:00</DtTm></BookgDt>
+        <NtryDtls>
+          <TxDtls>
+            <Refs><EndToEndId>R1</EndToEndId></Refs>
+            <RltdPties><Cdtr><Nm>Jane Doe</Nm></Cdtr><CdtrAcct><Id><IBAN>NL91ABNA0417164300</IBAN></Id></CdtrAcct></RltdPties>
+          </TxDtls>
+        </NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">99.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts>PDNG</Sts>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>`
+
+const camt054 = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.054.001.08">
+  <BkToCstmrDbtCdtNtfctn>
+    <Ntfctn>
+      <Acct><Id><IBAN>BE68539007547034</IBAN></Id></Acct>
+      <Ntry>
+        <Amt Ccy="EUR">12.50</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <RvslInd>true</RvslInd>
+        <Sts><Cd>BOOK</Cd></Sts>
+        <BookgDt><Dt>2024-01-20</Dt></BookgDt>
+        <NtryDtls>
+          <TxDtls>
+            <Refs><EndToEndId>T1</EndToEndId></Refs>
+            <RltdPties><Dbtr><Pty><Nm>John Doe</Nm></Pty></Dbtr></RltdPties>
+            <RtrInf><Rsn><Cd>MD06</Cd></Rsn></RtrInf>
+          </TxDtls>
+        </NtryDtls>
+      </Ntry>
+    </Ntfctn>
+  </BkToCstmrDbtCdtNtfctn>
+</Document>`
+
+func TestParseCAMT053(t *testing.T) {
+	entries, err := ParseCAMT(strings.NewReader(camt053))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected the batch to be split and the pending entry to be skipped, got %d entries", len(entries))
+	}
+
+	first := entries[0]
+	if !first.Credit || first.Amount != 12.5 || first.EndToEndId != "T1" || first.MandateId != "MNDT1" {
+		t.Errorf("Unexpected first entry: %+v", first)
+	}
+	if first.Account != "BE68539007547034" || first.Currency != "EUR" || first.BookingDate != "2024-01-16" {
+		t.Errorf("Unexpected first entry: %+v", first)
+	}
+	if first.CounterpartyName != "John Doe" || first.CounterpartyIban != "BE09363107700857" || first.Remittance != "Invoice 1" {
+		t.Errorf("Unexpected counterparty of first entry: %+v", first)
+	}
+
+	second := entries[1]
+	if second.Amount != 7.5 || second.EndToEndId != "" || second.Remittance != "RF18539007547034" || second.BankReference != "BANK-1" {
+		t.Errorf("Unexpected second entry: %+v", second)
+	}
+
+	third := entries[2]
+	if third.Credit || third.Amount != 5 || third.BookingDate != "2024-01-17" || third.CounterpartyName != "Jane Doe" {
+		t.Errorf("Unexpected third entry: %+v", third)
+	}
+}
+
+func TestParseCAMT054(t *testing.T) {
+	entries, err := ParseCAMT(strings.NewReader(camt054))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(entries))
+	}
+	entry := entries[0]
+	if !entry.IsReturn() || entry.ReturnReason != "MD06" || entry.Credit || entry.Amount != 12.5 {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+}
+
+func TestParseCAMTInvalid(t *testing.T) {
+	if _, err := ParseCAMT(strings.NewReader("not xml")); err == nil {
+		t.Error("Expected an error for invalid xml")
+	}
+	if _, err := ParseCAMT(strings.NewReader(`<Document><CstmrDrctDbtInitn/></Document>`)); err == nil {
+		t.Error("Expected an error for a document that isn't a statement")
+	}
+}
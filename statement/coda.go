@@ -0,0 +1,174 @@
+package statement
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// codaRecord gives access to the fields of a CODA record by their (1 based, inclusive) positions as used in
+// the specification of Febelfin
+type codaRecord string
+
+func (record codaRecord) field(from int, to int) string {
+	if from > len(record) {
+		return ""
+	}
+	if to > len(record) {
+		to = len(record)
+	}
+	return string(record[from-1 : to])
+}
+
+func (record codaRecord) text(from int, to int) string {
+	return strings.TrimSpace(record.field(from, to))
+}
+
+// date converts a DDMMYY field to yyyy-mm-dd
+func (record codaRecord) date(from int) string {
+	date, err := time.Parse("020106", record.field(from, from+5))
+	if err != nil {
+		return ""
+	}
+	return date.Format("2006-01-02")
+}
+
+// codaMovement is a movement being read, spread over records 2.1, 2.2 and 2.3
+type codaMovement struct {
+	entry         Entry
+	sequence      string
+	detail        string
+	globalisation bool
+	structured    bool
+	communication strings.Builder
+}
+
+// ParseCODA reads the movements of a Belgian CODA file (version 2). When a batch booking is followed by its
+// details, only the details are returned.
+func ParseCODA(r io.Reader) ([]Entry, error) {
+	scanner := bufio.NewScanner(r)
+	var movements []*codaMovement
+	var current *codaMovement
+	account, currency := "", ""
+	line := 0
+	header := false
+	for scanner.Scan() {
+		line++
+		record := codaRecord(strings.TrimRight(scanner.Text(), "\r"))
+		if strings.TrimSpace(string(record)) == "" {
+			continue
+		}
+		if !header {
+			if record[0] != '0' {
+				return nil, errors.New("not a coda file: missing header record")
+			}
+			header = true
+		}
+		switch record.field(1, 1) {
+		case "1":
+			account, currency = codaAccount(record)
+		case "2":
+			switch record.field(2, 2) {
+			case "1":
+				movement, err := parseCodaMovement(record, line)
+				if err != nil {
+					return nil, err
+				}
+				movement.entry.Account = account
+				movement.entry.Currency = currency
+				movements = append(movements, movement)
+				current = movement
+			case "2":
+				if current != nil {
+					if !current.structured {
+						current.communication.WriteString(record.field(11, 63))
+					}
+					current.entry.EndToEndId = record.text(64, 98)
+					current.entry.ReturnReason = record.text(114, 117)
+				}
+			case "3":
+				if current != nil {
+					current.entry.CounterpartyIban = strings.TrimSpace(record.field(11, 44))
+					current.entry.CounterpartyName = record.text(48, 82)
+					if !current.structured {
+						current.communication.WriteString(record.field(83, 125))
+					}
+				}
+			}
+		case "3", "4", "8", "9":
+			current = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !header {
+		return nil, errors.New("not a coda file: empty")
+	}
+
+	// a batch booking is left out when its details are part of the file
+	detailed := map[string]bool{}
+	for _, movement := range movements {
+		if movement.detail != "0000" {
+			detailed[movement.sequence] = true
+		}
+	}
+	var entries []Entry
+	for _, movement := range movements {
+		if movement.globalisation && movement.detail == "0000" && detailed[movement.sequence] {
+			continue
+		}
+		if movement.entry.Remittance == "" {
+			movement.entry.Remittance = strings.Join(strings.Fields(movement.communication.String()), " ")
+		}
+		entries = append(entries, movement.entry)
+	}
+	return entries, nil
+}
+
+// codaAccount returns the account and currency of an old balance record
+func codaAccount(record codaRecord) (string, string) {
+	switch record.field(2, 2) {
+	case "2": // Belgian iban
+		return record.text(6, 21), record.text(40, 42)
+	case "3": // foreign iban
+		return record.text(6, 39), record.text(40, 42)
+	default: // account number followed by the currency
+		return record.text(6, 17), record.text(19, 21)
+	}
+}
+
+func parseCodaMovement(record codaRecord, line int) (*codaMovement, error) {
+	amount, err := strconv.ParseInt(record.field(33, 47), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: invalid amount %q", line, record.field(33, 47))
+	}
+	movement := &codaMovement{
+		sequence:      record.field(3, 6),
+		detail:        record.field(7, 10),
+		globalisation: record.text(125, 125) != "" && record.text(125, 125) != "0",
+		entry: Entry{
+			BankReference: record.text(11, 31),
+			Credit:        record.field(32, 32) == "0",
+			Amount:        float64(amount) / 1000,
+			ValueDate:     record.date(48),
+			BookingDate:   record.date(116),
+			Position:      line,
+		},
+	}
+	if record.field(62, 62) == "1" {
+		// structured communication, 101 and 102 hold a Belgian structured reference while others (eg. 127 for
+		// SEPA direct debits) hold details of the payment rather than a communication
+		movement.structured = true
+		if code, reference := record.field(63, 65), record.field(66, 77); (code == "101" || code == "102") && len(reference) == 12 {
+			movement.entry.Remittance = "+++" + reference[:3] + "/" + reference[3:7] + "/" + reference[7:] + "+++"
+		}
+		return movement, nil
+	}
+	movement.communication.WriteString(record.field(63, 115))
+	return movement, nil
+}
@@ -0,0 +1,85 @@
+package statement
+
+import (
+	"strings"
+	"testing"
+)
+
+// codaLine builds a record of 128 characters, putting the values at their (1 based) positions
+func codaLine(values map[int]string) string {
+	line := []byte(strings.Repeat(" ", 128))
+	for position, value := range values {
+		copy(line[position-1:], value)
+	}
+	return string(line)
+}
+
+func codaFile() string {
+	return strings.Join([]string{
+		codaLine(map[int]string{1: "0000016012472505        00000TWIKEY NV", 128: "2"}),
+		codaLine(map[int]string{1: "12001", 6: "BE68539007547034", 40: "EUR"}),
+		// batch booking of a collection with its two details
+		codaLine(map[int]string{1: "2100010000", 11: "BANKREF1", 32: "0", 33: "000000000020000", 48: "160124", 62: "0", 116: "160124", 125: "1"}),
+		codaLine(map[int]string{1: "2100010001", 11: "BANKREF1", 32: "0", 33: "000000000012500", 48: "160124", 62: "0", 63: "Invoice 1", 116: "160124", 125: "0"}),
+		codaLine(map[int]string{1: "2200010001", 64: "T1"}),
+		codaLine(map[int]string{1: "2300010001", 11: "BE09363107700857", 48: "John Doe"}),
+		codaLine(map[int]string{1: "2100010002", 11: "BANKREF1", 32: "0", 33: "000000000007500", 48: "160124", 62: "1", 63: "101", 66: "090933755493", 116: "160124", 125: "0"}),
+		// refund paid and a returned collection
+		codaLine(map[int]string{1: "2100020000", 11: "BANKREF2", 32: "1", 33: "000000000005000", 48: "170124", 62: "0", 63: "Refund R1", 116: "170124"}),
+		codaLine(map[int]string{1: "2200020000", 64: "R1"}),
+		codaLine(map[int]string{1: "2300020000", 11: "NL91ABNA0417164300", 48: "Jane Doe"}),
+		codaLine(map[int]string{1: "2100030000", 11: "BANKREF3", 32: "1", 33: "000000000012500", 48: "200124", 62: "0", 116: "200124"}),
+		codaLine(map[int]string{1: "2200030000", 64: "T1", 114: "MD06"}),
+		codaLine(map[int]string{1: "8001"}),
+		codaLine(map[int]string{1: "9"}),
+	}, "\r\n")
+}
+
+func TestParseCODA(t *testing.T) {
+	entries, err := ParseCODA(strings.NewReader(codaFile()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("Expected the batch booking to be replaced by its details, got %d entries", len(entries))
+	}
+
+	first := entries[0]
+	if !first.Credit || first.Amount != 12.5 || first.EndToEndId != "T1" || first.Remittance != "Invoice 1" {
+		t.Errorf("Unexpected first entry: %+v", first)
+	}
+	if first.Account != "BE68539007547034" || first.Currency != "EUR" || first.BookingDate != "2024-01-16" || first.ValueDate != "2024-01-16" {
+		t.Errorf("Unexpected first entry: %+v", first)
+	}
+	if first.CounterpartyIban != "BE09363107700857" || first.CounterpartyName != "John Doe" || first.BankReference != "BANKREF1" {
+		t.Errorf("Unexpected counterparty of first entry: %+v", first)
+	}
+
+	second := entries[1]
+	if second.Amount != 7.5 || second.Remittance != "+++090/9337/55493+++" {
+		t.Errorf("Unexpected second entry: %+v", second)
+	}
+
+	third := entries[2]
+	if third.Credit || third.Amount != 5 || third.EndToEndId != "R1" || third.CounterpartyName != "Jane Doe" || third.IsReturn() {
+		t.Errorf("Unexpected third entry: %+v", third)
+	}
+
+	fourth := entries[3]
+	if !fourth.IsReturn() || fourth.ReturnReason != "MD06" || fourth.BookingDate != "2024-01-20" {
+		t.Errorf("Unexpected fourth entry: %+v", fourth)
+	}
+}
+
+func TestParseCODAInvalid(t *testing.T) {
+	if _, err := ParseCODA(strings.NewReader("")); err == nil {
+		t.Error("Expected an error for an empty file")
+	}
+	if _, err := ParseCODA(strings.NewReader("<Document/>")); err == nil {
+		t.Error("Expected an error for a file without header")
+	}
+	invalid := codaLine(map[int]string{1: "0"}) + "\n" + codaLine(map[int]string{1: "2100010000", 33: "00000000000ABCD"})
+	if _, err := ParseCODA(strings.NewReader(invalid)); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected an error for the invalid amount on line 2, got %v", err)
+	}
+}
@@ -0,0 +1,268 @@
+package statement
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/twikey/twikey-api-go"
+	"github.com/twikey/twikey-api-go/iban"
+)
+
+// How an entry was matched, from the most to the least reliable
+const (
+	MatchedByEndToEndId = "endToEndId" // End to end id of the entry is the ref (or id) of the transaction or refund
+	MatchedByRef        = "ref"        // Remittance of the entry contains the ref (and amount) of the transaction or refund
+	MatchedByAmount     = "amount"     // Only transaction or refund with the amount (and date or account) of the entry
+)
+
+// Match links an entry of a statement to a transaction or a refund
+type Match struct {
+	Entry       Entry
+	Transaction *twikey.Transaction // Transaction collected or returned, nil when matched to a refund
+	Refund      *twikey.Refund      // Refund paid or returned, nil when matched to a transaction
+	By          string              // MatchedByEndToEndId, MatchedByRef or MatchedByAmount
+	Differences []string            // Booked amount or date known by Twikey that differs from the statement
+}
+
+// Reconciliation is the outcome of matching statement entries
+type Reconciliation struct {
+	Matches               []Match
+	UnmatchedEntries      []Entry               // Entries not linked to a transaction or refund
+	UnmatchedTransactions []*twikey.Transaction // Transactions of which no collection was found
+	UnmatchedRefunds      []*twikey.Refund      // Refunds of which no payment was found
+}
+
+// IsComplete returns true when everything was matched without differences
+func (reconciliation *Reconciliation) IsComplete() bool {
+	if len(reconciliation.UnmatchedEntries) > 0 || len(reconciliation.UnmatchedTransactions) > 0 || len(reconciliation.UnmatchedRefunds) > 0 {
+		return false
+	}
+	for _, match := range reconciliation.Matches {
+		if len(match.Differences) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// candidate is a transaction or a refund that can be matched
+type candidate struct {
+	transaction *twikey.Transaction
+	refund      *twikey.Refund
+}
+
+func (c candidate) ids() (string, string) {
+	if c.transaction != nil {
+		id := ""
+		if c.transaction.Id != 0 {
+			id = strconv.FormatInt(c.transaction.Id, 10)
+		}
+		return c.transaction.Ref, id
+	}
+	return c.refund.Ref, c.refund.Id
+}
+
+// cents returns the amount that should be on the statement
+func (c candidate) cents() int64 {
+	amount := 0.0
+	if c.transaction != nil {
+		amount = c.transaction.Amount
+		if c.transaction.BookedAmount != 0 {
+			amount = c.transaction.BookedAmount
+		}
+	} else {
+		amount = c.refund.Amount
+	}
+	return toCents(amount)
+}
+
+// bookedDate returns the date on which Twikey saw the booking (yyyy-mm-dd), if known
+func (c candidate) bookedDate() string {
+	if c.transaction != nil {
+		return isoDate(c.transaction.BookedDate)
+	}
+	if c.refund.Bkdate.IsZero() {
+		return ""
+	}
+	return c.refund.Bkdate.Format("2006-01-02")
+}
+
+func (c candidate) account() string {
+	if c.refund != nil {
+		return iban.Normalize(c.refund.Iban)
+	}
+	return ""
+}
+
+func toCents(amount float64) int64 {
+	return int64(math.Round(math.Abs(amount) * 100))
+}
+
+// Matcher links statement entries to the transactions and refunds they confirm
+type Matcher struct {
+	transactions []candidate
+	refunds      []candidate
+}
+
+// NewMatcher creates a matcher for the given transactions and refunds (eg. read from their feeds)
+func NewMatcher(transactions []*twikey.Transaction, refunds []*twikey.Refund) *Matcher {
+	matcher := &Matcher{}
+	for _, transaction := range transactions {
+		if transaction != nil {
+			matcher.transactions = append(matcher.transactions, candidate{transaction: transaction})
+		}
+	}
+	for _, refund := range refunds {
+		if refund != nil {
+			matcher.refunds = append(matcher.refunds, candidate{refund: refund})
+		}
+	}
+	return matcher
+}
+
+// Match links the entries to the transactions and refunds. Money received is matched with transactions and money
+// paid with refunds, while returns are matched the other way around. The end to end id is tried first for all
+// entries, then the ref in the remittance (as a separate word and with the same amount) and finally the amount,
+// which is only used when it points to a single transaction or refund booked on the same date or paid to the
+// same account.
+func (m *Matcher) Match(entries []Entry) *Reconciliation {
+	matched := make([]*Match, len(entries))
+	used := map[string]bool{}
+
+	find := func(i int, by string, accept func(entry *Entry, c candidate) bool) {
+		entry := &entries[i]
+		var found []candidate
+		for _, c := range m.candidates(entry) {
+			if !used[usedKey(entry, c)] && accept(entry, c) {
+				found = append(found, c)
+			}
+		}
+		if by == MatchedByAmount {
+			found = narrow(entry, found)
+		}
+		if len(found) == 1 {
+			used[usedKey(entry, found[0])] = true
+			matched[i] = newMatch(entry, found[0], by)
+		}
+	}
+
+	passes := []struct {
+		by     string
+		accept func(entry *Entry, c candidate) bool
+	}{
+		{MatchedByEndToEndId, func(entry *Entry, c candidate) bool {
+			ref, id := c.ids()
+			return entry.EndToEndId != "" && (entry.EndToEndId == ref || entry.EndToEndId == id)
+		}},
+		{MatchedByRef, func(entry *Entry, c candidate) bool {
+			ref, _ := c.ids()
+			return len(ref) >= 3 && containsWord(entry.Remittance, ref) && toCents(entry.Amount) == c.cents()
+		}},
+		{MatchedByAmount, func(entry *Entry, c candidate) bool {
+			return toCents(entry.Amount) == c.cents()
+		}},
+	}
+	for _, pass := range passes {
+		for i := range entries {
+			if matched[i] == nil {
+				find(i, pass.by, pass.accept)
+			}
+		}
+	}
+
+	reconciliation := &Reconciliation{}
+	for i, match := range matched {
+		if match == nil {
+			reconciliation.UnmatchedEntries = append(reconciliation.UnmatchedEntries, entries[i])
+		} else {
+			reconciliation.Matches = append(reconciliation.Matches, *match)
+		}
+	}
+	// a transaction or refund that was only returned was never seen as paid either
+	for _, c := range m.transactions {
+		if !used[candidateKey(c, false)] {
+			reconciliation.UnmatchedTransactions = append(reconciliation.UnmatchedTransactions, c.transaction)
+		}
+	}
+	for _, c := range m.refunds {
+		if !used[candidateKey(c, false)] {
+			reconciliation.UnmatchedRefunds = append(reconciliation.UnmatchedRefunds, c.refund)
+		}
+	}
+	return reconciliation
+}
+
+// candidates returns the transactions for money received and the refunds for money paid, or the other way
+// around for returns
+func (m *Matcher) candidates(entry *Entry) []candidate {
+	if entry.Credit != entry.IsReturn() {
+		return m.transactions
+	}
+	return m.refunds
+}
+
+// usedKey allows a transaction or refund to be matched once as paid and once as returned
+func usedKey(entry *Entry, c candidate) string {
+	return candidateKey(c, entry.IsReturn())
+}
+
+func candidateKey(c candidate, returned bool) string {
+	key := fmt.Sprintf("%p%p", c.transaction, c.refund)
+	if returned {
+		key += "/returned"
+	}
+	return key
+}
+
+// containsWord returns true if the text contains the word (ignoring the case) not preceded or followed by a
+// letter or digit, so a ref is not found inside a longer one (eg. INV-4 in INV-42)
+func containsWord(text string, word string) bool {
+	text, word = strings.ToUpper(text), strings.ToUpper(word)
+	for start := 0; start <= len(text)-len(word); {
+		i := strings.Index(text[start:], word)
+		if i == -1 {
+			return false
+		}
+		i += start
+		before, _ := utf8.DecodeLastRuneInString(text[:i])
+		after, _ := utf8.DecodeRuneInString(text[i+len(word):])
+		if !isWordRune(before) && !isWordRune(after) {
+			return true
+		}
+		start = i + 1
+	}
+	return false
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// narrow keeps the candidates booked on the date of the entry or paid to the account of the entry
+func narrow(entry *Entry, found []candidate) []candidate {
+	var narrowed []candidate
+	for _, c := range found {
+		date := c.bookedDate()
+		sameDate := date != "" && (date == entry.BookingDate || date == entry.ValueDate)
+		sameAccount := entry.CounterpartyIban != "" && c.account() == iban.Normalize(entry.CounterpartyIban)
+		if sameDate || sameAccount {
+			narrowed = append(narrowed, c)
+		}
+	}
+	return narrowed
+}
+
+func newMatch(entry *Entry, c candidate, by string) *Match {
+	match := &Match{Entry: *entry, Transaction: c.transaction, Refund: c.refund, By: by}
+	if cents := toCents(entry.Amount); cents != c.cents() {
+		match.Differences = append(match.Differences, fmt.Sprintf("amount: Twikey %.2f, bank %.2f", float64(c.cents())/100, float64(cents)/100))
+	}
+	if date := c.bookedDate(); date != "" && !entry.IsReturn() && date != entry.BookingDate && date != entry.ValueDate {
+		match.Differences = append(match.Differences, fmt.Sprintf("booked date: Twikey %s, bank %s", date, entry.date()))
+	}
+	return match
+}
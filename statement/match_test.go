@@ -0,0 +1,107 @@
+package statement
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/twikey/twikey-api-go"
+)
+
+func TestMatchStatement(t *testing.T) {
+	entries, err := ParseCAMT(strings.NewReader(camt053))
+	if err != nil {
+		t.Fatal(err)
+	}
+	returns, err := ParseCAMT(strings.NewReader(camt054))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries = append(entries, returns...)
+
+	t1 := &twikey.Transaction{Id: 1, Ref: "T1", Amount: 12.5, BookedDate: "2024-01-16T08:00:00Z", BookedAmount: 12.5}
+	t2 := &twikey.Transaction{Id: 2, Ref: "RF18539007547034", Amount: 7.5, BookedDate: "2024-01-15"}
+	t3 := &twikey.Transaction{Id: 3, Ref: "T3", Amount: 30}
+	r1 := &twikey.Refund{Id: "rf1", Ref: "R1", Amount: 5, Bkdate: time.Date(2024, 1, 17, 0, 0, 0, 0, time.UTC)}
+
+	reconciliation := NewMatcher([]*twikey.Transaction{t1, t2, t3}, []*twikey.Refund{r1}).Match(entries)
+	if len(reconciliation.Matches) != 4 {
+		t.Fatalf("Expected 4 matches, got %+v", reconciliation.Matches)
+	}
+	expected := []struct {
+		transaction *twikey.Transaction
+		refund      *twikey.Refund
+		by          string
+	}{
+		{t1, nil, MatchedByEndToEndId},
+		{t2, nil, MatchedByRef},
+		{nil, r1, MatchedByEndToEndId},
+		{t1, nil, MatchedByEndToEndId}, // the return of the collection
+	}
+	for i, match := range reconciliation.Matches {
+		if match.Transaction != expected[i].transaction || match.Refund != expected[i].refund || match.By != expected[i].by {
+			t.Errorf("Unexpected match %d: %+v", i, match)
+		}
+	}
+	if differences := reconciliation.Matches[1].Differences; len(differences) != 1 || !strings.HasPrefix(differences[0], "booked date") {
+		t.Errorf("Expected the booked date of T2 to differ, got %v", differences)
+	}
+	if len(reconciliation.Matches[0].Differences) != 0 || len(reconciliation.Matches[2].Differences) != 0 {
+		t.Error("Expected no differences for T1 and R1")
+	}
+
+	if len(reconciliation.UnmatchedEntries) != 0 || len(reconciliation.UnmatchedRefunds) != 0 {
+		t.Errorf("Expected all entries and refunds to be matched, got %+v", reconciliation)
+	}
+	if len(reconciliation.UnmatchedTransactions) != 1 || reconciliation.UnmatchedTransactions[0] != t3 {
+		t.Errorf("Expected T3 to be unmatched, got %+v", reconciliation.UnmatchedTransactions)
+	}
+	if reconciliation.IsComplete() {
+		t.Error("Expected an incomplete reconciliation")
+	}
+}
+
+func TestMatchByRefAndAmount(t *testing.T) {
+	entries := []Entry{
+		{Credit: true, Amount: 10, BookingDate: "2024-01-16", Remittance: "Payment for inv-42, thanks"},
+		{Credit: true, Amount: 10, BookingDate: "2024-01-16"},
+		{Credit: true, Amount: 25, BookingDate: "2024-01-16"},
+		{Credit: false, Amount: 3, BookingDate: "2024-01-16", CounterpartyIban: "be09 3631 0770 0857"},
+		{Credit: true, Amount: 8, BookingDate: "2024-01-16", Remittance: "Invoice ABC-1"},
+		{Credit: true, Amount: 9, BookingDate: "2024-01-16"},
+	}
+	inv42 := &twikey.Transaction{Ref: "INV-42", Amount: 10}
+	inv4 := &twikey.Transaction{Ref: "INV-4", Amount: 10}
+	other := &twikey.Transaction{Ref: "OTHER", Amount: 10, BookedDate: "2024-01-16"}
+	same1 := &twikey.Transaction{Ref: "S1", Amount: 25}
+	same2 := &twikey.Transaction{Ref: "S2", Amount: 25}
+	abc1 := &twikey.Transaction{Ref: "ABC-1", Amount: 80}
+	single := &twikey.Transaction{Ref: "SINGLE", Amount: 9}
+	r1 := &twikey.Refund{Ref: "R1", Amount: 3, Iban: "NL91ABNA0417164300"}
+	r2 := &twikey.Refund{Ref: "R2", Amount: 3, Iban: "BE09363107700857"}
+
+	transactions := []*twikey.Transaction{inv42, inv4, other, same1, same2, abc1, single}
+	reconciliation := NewMatcher(transactions, []*twikey.Refund{r1, r2}).Match(entries)
+	if len(reconciliation.Matches) != 3 {
+		t.Fatalf("Expected 3 matches, got %+v", reconciliation.Matches)
+	}
+	// INV-4 is part of the remittance, but not as a separate word
+	if match := reconciliation.Matches[0]; match.Transaction != inv42 || match.By != MatchedByRef || len(match.Differences) != 0 {
+		t.Errorf("Expected INV-42 to match by ref, got %+v", match)
+	}
+	if match := reconciliation.Matches[1]; match.Transaction != other || match.By != MatchedByAmount {
+		t.Errorf("Expected OTHER to match by amount, got %+v", match)
+	}
+	if match := reconciliation.Matches[2]; match.Refund != r2 || match.By != MatchedByAmount {
+		t.Errorf("Expected R2 to match by account, got %+v", match)
+	}
+	// two transactions of 25 without a date can't be told apart, the ref of ABC-1 is there but not its amount and
+	// the only transaction of 9 was neither booked on that date nor paid from a known account
+	if len(reconciliation.UnmatchedEntries) != 3 || reconciliation.UnmatchedEntries[0].Amount != 25 ||
+		reconciliation.UnmatchedEntries[1].Amount != 8 || reconciliation.UnmatchedEntries[2].Amount != 9 {
+		t.Errorf("Expected the entries of 25, 8 and 9 to be unmatched, got %+v", reconciliation.UnmatchedEntries)
+	}
+	if len(reconciliation.UnmatchedTransactions) != 5 || len(reconciliation.UnmatchedRefunds) != 1 {
+		t.Errorf("Unexpected unmatched items: %+v", reconciliation)
+	}
+}
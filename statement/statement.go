@@ -0,0 +1,53 @@
+// Package statement reads bank statements (CAMT.053, CAMT.054 and Belgian CODA files) into typed entries and
+// matches those with the transactions and refunds of Twikey, so the booked amounts and dates can be confirmed
+// against the real movements on the account.
+package statement
+
+import (
+	"strings"
+	"time"
+)
+
+// Entry is a single movement on a bank account. Batch bookings are split in their details when the bank
+// provides them, so a collection booked as a single amount results in an entry per transaction.
+type Entry struct {
+	Account          string  // Iban of the account of the statement
+	Credit           bool    // true for money received (eg. collected transactions), false for money paid (eg. refunds)
+	Amount           float64 // Amount of the movement, always positive
+	Currency         string
+	BookingDate      string // yyyy-mm-dd
+	ValueDate        string // yyyy-mm-dd
+	EndToEndId       string // End to end id of the SEPA payment
+	BankReference    string // Reference of the movement given by the bank
+	Remittance       string // Structured or unstructured communication
+	CounterpartyName string
+	CounterpartyIban string
+	MandateId        string // Mandate of a direct debit when provided by the bank
+	ReturnReason     string // ISO reason of a returned or refused payment (eg. AC04, MD06)
+	Reversal         bool   // true when the entry reverses an earlier one
+	Position         int    // Position in the file (entry for CAMT, line for CODA) to find it back
+}
+
+// IsReturn returns true when the entry is a return of an earlier payment
+func (entry *Entry) IsReturn() bool {
+	return entry.Reversal || entry.ReturnReason != ""
+}
+
+// date returns the booking or value date of the entry
+func (entry *Entry) date() string {
+	if entry.BookingDate != "" {
+		return entry.BookingDate
+	}
+	return entry.ValueDate
+}
+
+// isoDate converts the date part of an ISO date or date time to yyyy-mm-dd
+func isoDate(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 10 {
+		if _, err := time.Parse("2006-01-02", value[:10]); err == nil {
+			return value[:10]
+		}
+	}
+	return ""
+}
@@ -8,6 +8,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Recurrence string
@@ -22,6 +23,17 @@ const (
 	RecurrenceAnnual     Recurrence = "12m"
 )
 
+// recurrences lists all supported values of Recurrence
+var recurrences = []string{
+	string(RecurrenceWeekly),
+	string(RecurrenceMonthly),
+	string(RecurrenceBiMonthly),
+	string(RecurrenceQuarterly),
+	string(RecurrenceTrimestral),
+	string(RecurrenceSemiAnnual),
+	string(RecurrenceAnnual),
+}
+
 type SubscriptionState string
 
 const (
@@ -44,6 +56,14 @@ type Subscription struct {
 	Next       string            `json:"next"`
 	Recurrence Recurrence        `json:"recurrence"`
 	MndtId     string            `json:"mndtId"`
+
+	IdempotencyKey   string `json:"-"` // key used when creating the subscription
+	IdempotentReplay bool   `json:"-"` // true if Twikey returned the result of a previous call with the same key
+}
+
+func (s *Subscription) setIdempotency(key string, replayed bool) {
+	s.IdempotencyKey = key
+	s.IdempotentReplay = replayed
 }
 
 type SubscriptionAddRequest struct {
@@ -67,6 +87,31 @@ type SubscriptionAddRequest struct {
 	StartDate string
 }
 
+// Validate checks the request locally and returns a ValidationError listing all invalid fields
+func (r *SubscriptionAddRequest) Validate() error {
+	return r.validateAt(time.Now())
+}
+
+func (r *SubscriptionAddRequest) validateAt(now time.Time) error {
+	v := validation{}
+	v.required("MndtId", r.MndtId)
+	if r.Plan == "" {
+		v.required("Message", r.Message)
+		if r.Amount <= 0 {
+			v.fail("Amount", "should be positive")
+		}
+	}
+	v.date("StartDate", r.StartDate)
+	if date, err := time.Parse("2006-01-02", r.StartDate); err == nil && !date.After(dateOf(now)) {
+		v.fail("StartDate", "should be in the future")
+	}
+	if strings.ContainsAny(r.Ref, " \t") {
+		v.fail("Ref", "can't contain any spaces")
+	}
+	v.oneOf("Recurrence", string(r.Recurrence), recurrences...)
+	return v.err()
+}
+
 // asUrlParams returns the form URL encoded parameters for the incoming request.
 func (r *SubscriptionAddRequest) asUrlParams() string {
 	params := url.Values{}
@@ -92,13 +137,15 @@ func (r *SubscriptionAddRequest) asUrlParams() string {
 // SubscriptionAdd will add a subscription to an existing agreement. This means than when the subscription is run a
 // new transaction will automatically be created using the defined schedule.
 func (c *Client) SubscriptionAdd(ctx context.Context, payload *SubscriptionAddRequest) (*Subscription, error) {
-	input := strings.NewReader(payload.asUrlParams())
-	endpoint := c.BaseURL + "/creditor/subscription"
-	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, input)
-	if payload.IdempotencyKey != "" {
-		req.Header.Set("Idempotency-Key", payload.IdempotencyKey)
+	if err := c.validate(payload); err != nil {
+		return nil, err
 	}
 
+	params := payload.asUrlParams()
+	endpoint := c.BaseURL + "/creditor/subscription"
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(params))
+	c.addIdempotencyKey(req, &payload.IdempotencyKey, []byte(params))
+
 	var output Subscription
 	if err := c.sendRequest(req, &output); err != nil {
 		return nil, err
@@ -0,0 +1,343 @@
+package twikey
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// SubscriptionIterator walks lazily through all pages of subscriptions matching a query, Next returns io.EOF
+// when no more subscriptions are available.
+type SubscriptionIterator struct {
+	client  *Client
+	ctx     context.Context
+	request SubscriptionListRequest
+	page    []Subscription
+	pos     int
+	last    bool
+}
+
+// Subscriptions returns an iterator over all subscriptions matching the query (starting from the page in the
+// request). Pages are only fetched when needed and the request itself is not modified.
+func (c *Client) Subscriptions(ctx context.Context, request *SubscriptionListRequest) *SubscriptionIterator {
+	it := &SubscriptionIterator{client: c, ctx: ctx}
+	if request != nil {
+		it.request = *request
+	}
+	return it
+}
+
+// Next returns the next subscription, fetching the next page when required
+func (it *SubscriptionIterator) Next() (*Subscription, error) {
+	for it.pos >= len(it.page) {
+		if it.last {
+			return nil, io.EOF
+		}
+		if err := it.ctx.Err(); err != nil {
+			return nil, err
+		}
+		if it.page != nil {
+			it.request.NextPage()
+		}
+		response, err := it.client.SubscriptionList(it.ctx, &it.request)
+		if err != nil {
+			return nil, err
+		}
+		it.page = response.Subscriptions
+		if it.page == nil {
+			it.page = []Subscription{}
+		}
+		it.pos = 0
+		it.last = !response.HasNext() || len(response.Subscriptions) == 0
+	}
+	subscription := &it.page[it.pos]
+	it.pos++
+	return subscription, nil
+}
+
+// All collects the remaining subscriptions of the iterator
+func (it *SubscriptionIterator) All() ([]Subscription, error) {
+	var subscriptions []Subscription
+	for {
+		subscription, err := it.Next()
+		if err == io.EOF {
+			return subscriptions, nil
+		}
+		if err != nil {
+			return subscriptions, err
+		}
+		subscriptions = append(subscriptions, *subscription)
+	}
+}
+
+// SubscriptionOperation is an operation that can be applied to many subscriptions using a SubscriptionBulk.
+// See SuspendOperation, ResumeOperation, CancelOperation, PatchAmountOperation, MoveMandateOperation and
+// MigratePlanOperation.
+type SubscriptionOperation interface {
+	// name of the operation as used in the report
+	name() string
+	// skip returns the reason why the operation doesn't apply to the subscription or an empty string
+	skip(subscription *Subscription) string
+	// apply executes the operation, returning the subscription as it is after the operation (or would be in a dry-run)
+	apply(ctx context.Context, c *Client, subscription *Subscription, dryRun bool) (*Subscription, error)
+}
+
+// SuspendOperation suspends active subscriptions
+type SuspendOperation struct{}
+
+func (op *SuspendOperation) name() string { return "suspend" }
+
+func (op *SuspendOperation) skip(subscription *Subscription) string {
+	if subscription.State != SubscriptionStateActive {
+		return "subscription is " + string(subscription.State)
+	}
+	return ""
+}
+
+func (op *SuspendOperation) apply(ctx context.Context, c *Client, subscription *Subscription, dryRun bool) (*Subscription, error) {
+	if !dryRun {
+		if err := c.SubscriptionSuspend(ctx, subscription.MndtId, subscription.Ref); err != nil {
+			return nil, err
+		}
+	}
+	after := *subscription
+	after.State = SubscriptionStateSuspended
+	return &after, nil
+}
+
+// ResumeOperation resumes suspended subscriptions
+type ResumeOperation struct{}
+
+func (op *ResumeOperation) name() string { return "resume" }
+
+func (op *ResumeOperation) skip(subscription *Subscription) string {
+	if subscription.State != SubscriptionStateSuspended {
+		return "subscription is " + string(subscription.State)
+	}
+	return ""
+}
+
+func (op *ResumeOperation) apply(ctx context.Context, c *Client, subscription *Subscription, dryRun bool) (*Subscription, error) {
+	if !dryRun {
+		if err := c.SubscriptionResume(ctx, subscription.MndtId, subscription.Ref); err != nil {
+			return nil, err
+		}
+	}
+	after := *subscription
+	after.State = SubscriptionStateActive
+	return &after, nil
+}
+
+// CancelOperation cancels active or suspended subscriptions
+type CancelOperation struct{}
+
+func (op *CancelOperation) name() string { return "cancel" }
+
+func (op *CancelOperation) skip(subscription *Subscription) string {
+	return skipIfEnded(subscription)
+}
+
+func (op *CancelOperation) apply(ctx context.Context, c *Client, subscription *Subscription, dryRun bool) (*Subscription, error) {
+	if !dryRun {
+		if err := c.SubscriptionCancel(ctx, subscription.MndtId, subscription.Ref); err != nil {
+			return nil, err
+		}
+	}
+	after := *subscription
+	after.State = SubscriptionStateCancelled
+	return &after, nil
+}
+
+// PatchAmountOperation changes the amount of active or suspended subscriptions
+type PatchAmountOperation struct {
+	// Amount returns the new amount based on the current one
+	Amount func(current float64) float64
+}
+
+// IncreaseAmountByPercent creates an operation raising the amount of every subscription by a percentage (rounded
+// to the cent), a negative percentage lowers the amount.
+func IncreaseAmountByPercent(percent float64) *PatchAmountOperation {
+	return &PatchAmountOperation{
+		Amount: func(current float64) float64 {
+			return roundAmount(current * (100 + percent) / 100)
+		},
+	}
+}
+
+func (op *PatchAmountOperation) name() string { return "patch amount" }
+
+func (op *PatchAmountOperation) skip(subscription *Subscription) string {
+	if reason := skipIfEnded(subscription); reason != "" {
+		return reason
+	}
+	if sameAmount(op.Amount(subscription.Amount), subscription.Amount) {
+		return "amount is unchanged"
+	}
+	return ""
+}
+
+func (op *PatchAmountOperation) apply(ctx context.Context, c *Client, subscription *Subscription, dryRun bool) (*Subscription, error) {
+	amount := op.Amount(subscription.Amount)
+	if amount <= 0 {
+		return nil, errors.New("amount should be positive")
+	}
+	if dryRun {
+		after := *subscription
+		after.Amount = amount
+		return &after, nil
+	}
+	return c.SubscriptionPatch(ctx, subscription.MndtId, subscription.Ref, &PatchSubscriptionRequest{Amount: amount})
+}
+
+// MoveMandateOperation moves active or suspended subscriptions to another mandate
+type MoveMandateOperation struct {
+	MndtId string
+}
+
+func (op *MoveMandateOperation) name() string { return "move mandate" }
+
+func (op *MoveMandateOperation) skip(subscription *Subscription) string {
+	if reason := skipIfEnded(subscription); reason != "" {
+		return reason
+	}
+	if subscription.MndtId == op.MndtId {
+		return "already on mandate " + op.MndtId
+	}
+	return ""
+}
+
+func (op *MoveMandateOperation) apply(ctx context.Context, c *Client, subscription *Subscription, dryRun bool) (*Subscription, error) {
+	if op.MndtId == "" {
+		return nil, errors.New("a mandate to move to is required")
+	}
+	if dryRun {
+		after := *subscription
+		after.MndtId = op.MndtId
+		return &after, nil
+	}
+	return c.SubscriptionPatch(ctx, subscription.MndtId, subscription.Ref, &PatchSubscriptionRequest{MndtId: op.MndtId})
+}
+
+func skipIfEnded(subscription *Subscription) string {
+	if subscription.State == SubscriptionStateCancelled || subscription.State == SubscriptionStateClosed {
+		return "subscription is " + string(subscription.State)
+	}
+	return ""
+}
+
+// SubscriptionBulkItem is the outcome of the operation on a single subscription
+type SubscriptionBulkItem struct {
+	MndtId  string
+	Ref     string
+	Before  *Subscription // the subscription as listed
+	After   *Subscription // the subscription after the operation (nil when skipped or failed)
+	Skipped bool
+	Reason  string // why the subscription was skipped
+	Err     error
+}
+
+// SubscriptionBulkReport lists the outcome for every subscription in the order they were listed
+type SubscriptionBulkReport struct {
+	Operation string
+	DryRun    bool
+	Items     []SubscriptionBulkItem
+}
+
+// Succeeded returns the number of subscriptions to which the operation was applied
+func (r *SubscriptionBulkReport) Succeeded() int {
+	count := 0
+	for _, item := range r.Items {
+		if !item.Skipped && item.Err == nil {
+			count++
+		}
+	}
+	return count
+}
+
+// Failed returns the items for which the operation failed
+func (r *SubscriptionBulkReport) Failed() []SubscriptionBulkItem {
+	var failed []SubscriptionBulkItem
+	for _, item := range r.Items {
+		if item.Err != nil {
+			failed = append(failed, item)
+		}
+	}
+	return failed
+}
+
+// SubscriptionBulk applies an operation to all subscriptions matching a query, failures of single subscriptions
+// don't stop the others.
+type SubscriptionBulk struct {
+	client *Client
+	// Concurrency is the number of subscriptions updated at the same time (default 4)
+	Concurrency int
+	// DryRun reports what would change without calling Twikey (apart from listing the subscriptions)
+	DryRun bool
+	// Filter optionally limits the operation to the subscriptions for which it returns true
+	Filter func(subscription *Subscription) bool
+}
+
+// NewSubscriptionBulk creates a bulk updater using this client
+func (c *Client) NewSubscriptionBulk() *SubscriptionBulk {
+	return &SubscriptionBulk{
+		client:      c,
+		Concurrency: 4,
+	}
+}
+
+// Run applies the operation to all subscriptions matching the query. All subscriptions are listed before the first
+// one is updated, so the update can't shift the pages of the query. An error is only returned when the
+// subscriptions can't be listed, the outcome of every single subscription is part of the report.
+func (b *SubscriptionBulk) Run(ctx context.Context, query *SubscriptionListRequest, operation SubscriptionOperation) (*SubscriptionBulkReport, error) {
+	subscriptions, err := b.client.Subscriptions(ctx, query).All()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SubscriptionBulkReport{
+		Operation: operation.name(),
+		DryRun:    b.DryRun,
+	}
+	for i := range subscriptions {
+		if b.Filter != nil && !b.Filter(&subscriptions[i]) {
+			continue
+		}
+		report.Items = append(report.Items, SubscriptionBulkItem{
+			MndtId: subscriptions[i].MndtId,
+			Ref:    subscriptions[i].Ref,
+			Before: &subscriptions[i],
+		})
+	}
+
+	concurrency := b.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	jobs := make(chan *SubscriptionBulkItem)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range jobs {
+				if err := ctx.Err(); err != nil {
+					item.Err = err
+					continue
+				}
+				if reason := operation.skip(item.Before); reason != "" {
+					item.Skipped = true
+					item.Reason = reason
+					continue
+				}
+				item.After, item.Err = operation.apply(ctx, b.client, item.Before, b.DryRun)
+			}
+		}()
+	}
+	for i := range report.Items {
+		jobs <- &report.Items[i]
+	}
+	close(jobs)
+	wg.Wait()
+	return report, nil
+}
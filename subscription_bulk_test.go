@@ -0,0 +1,118 @@
+package twikey
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func newSubscriptionPagesServer(t *testing.T, calls *[]string) *httptest.Server {
+	var mu sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/creditor/subscription/query" {
+			AssertEquals(t, "M1", r.URL.Query().Get("mndtId"))
+			switch r.URL.Query().Get("page") {
+			case "":
+				_, _ = w.Write([]byte(`{"Subscriptions":[{"mndtId":"M1","ref":"A","state":"active","amount":10},{"mndtId":"M1","ref":"B","state":"suspended","amount":20}],"_links":{"next":"page=1"}}`))
+			case "1":
+				_, _ = w.Write([]byte(`{"Subscriptions":[{"mndtId":"M1","ref":"C","state":"active","amount":30}],"_links":{}}`))
+			default:
+				t.Errorf("Unexpected page %s", r.URL.Query().Get("page"))
+			}
+			return
+		}
+		mu.Lock()
+		*calls = append(*calls, r.Method+" "+r.URL.RequestURI())
+		mu.Unlock()
+		if r.URL.Path == "/creditor/subscription/M1/C/suspend" {
+			w.Header().Set("ApiError", "err_invalid_state")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"code":"err_invalid_state","message":"Invalid state"}`))
+			return
+		}
+		if r.Method == http.MethodPatch {
+			_, _ = w.Write([]byte(`{"mndtId":"M1","ref":"` + r.URL.Path[len(r.URL.Path)-1:] + `","state":"active","amount":11}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func TestSubscriptionIterator(t *testing.T) {
+	var calls []string
+	server := newSubscriptionPagesServer(t, &calls)
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+
+	request := &SubscriptionListRequest{MndtId: "M1"}
+	it := cl.Subscriptions(context.Background(), request)
+	var refs []string
+	for {
+		subscription, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		refs = append(refs, subscription.Ref)
+	}
+	AssertEquals(t, "A,B,C", strings.Join(refs, ","))
+	AssertEquals(t, 0, request.Page)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := cl.Subscriptions(ctx, request).Next(); err == nil {
+		t.Error("Expected the cancelled context to stop the iterator")
+	}
+}
+
+func TestSubscriptionBulk(t *testing.T) {
+	var calls []string
+	server := newSubscriptionPagesServer(t, &calls)
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+	ctx := context.Background()
+
+	bulk := cl.NewSubscriptionBulk()
+	report, err := bulk.Run(ctx, &SubscriptionListRequest{MndtId: "M1"}, &SuspendOperation{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, "suspend", report.Operation)
+	AssertEquals(t, 3, len(report.Items))
+	AssertEquals(t, SubscriptionStateSuspended, report.Items[0].After.State)
+	AssertEquals(t, true, report.Items[1].Skipped)
+	AssertEquals(t, "subscription is suspended", report.Items[1].Reason)
+	AssertEquals(t, 1, len(report.Failed()))
+	AssertEquals(t, "C", report.Failed()[0].Ref)
+	AssertEquals(t, 1, report.Succeeded())
+
+	// dry-run doesn't call Twikey
+	calls = nil
+	bulk.DryRun = true
+	report, err = bulk.Run(ctx, &SubscriptionListRequest{MndtId: "M1"}, IncreaseAmountByPercent(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 0, len(calls))
+	AssertEquals(t, 11.0, report.Items[0].After.Amount)
+	AssertEquals(t, 33.0, report.Items[2].After.Amount)
+
+	calls = nil
+	bulk.DryRun = false
+	bulk.Filter = func(subscription *Subscription) bool {
+		return subscription.Ref == "A"
+	}
+	report, err = bulk.Run(ctx, &SubscriptionListRequest{MndtId: "M1"}, &MoveMandateOperation{MndtId: "M2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 1, len(report.Items))
+	AssertEquals(t, 1, len(calls))
+	AssertEquals(t, "PATCH /creditor/subscription/M1/A?mndtId=M2", calls[0])
+}
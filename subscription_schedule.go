@@ -0,0 +1,273 @@
+package twikey
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// maxScheduledRuns guards against endless schedules when projecting unbounded subscriptions
+const maxScheduledRuns = 10000
+
+// interval returns the number of weeks or months between 2 runs, an empty recurrence is monthly
+func (r Recurrence) interval() (weeks int, months int, err error) {
+	value := string(r)
+	if value == "" {
+		value = string(RecurrenceMonthly)
+	}
+	if len(value) < 2 {
+		return 0, 0, fmt.Errorf("invalid recurrence %q", r)
+	}
+	count, err := strconv.Atoi(value[:len(value)-1])
+	if err != nil || count <= 0 {
+		return 0, 0, fmt.Errorf("invalid recurrence %q", r)
+	}
+	switch value[len(value)-1] {
+	case 'w':
+		return count, 0, nil
+	case 'm':
+		return 0, count, nil
+	}
+	return 0, 0, fmt.Errorf("invalid recurrence %q", r)
+}
+
+// addMonths adds a number of months to the date, keeping the day of the month of the anchor date when possible and
+// using the last day of the month otherwise (eg. the 31st of January becomes the 28th or 29th of February)
+func addMonths(anchor time.Time, months int) time.Time {
+	year, month, day := anchor.Date()
+	firstOfMonth := time.Date(year, month+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(firstOfMonth.Year(), firstOfMonth.Month(), day, 0, 0, 0, 0, time.UTC)
+}
+
+// runs calls fn for every future run of the subscription in [from, until] until fn returns false. A zero until
+// means no upper bound. The schedule is anchored on Start so month-end dates don't drift over time, unless the next
+// run isn't part of that schedule (eg. it was moved) in which case the schedule continues from the next run.
+func (s *Subscription) runs(from time.Time, until time.Time, fn func(time.Time) bool) error {
+	weeks, months, err := s.Recurrence.interval()
+	if err != nil {
+		return err
+	}
+
+	anchorDate := s.Start
+	if anchorDate == "" {
+		anchorDate = s.Next
+	}
+	anchor, err := time.Parse("2006-01-02", anchorDate)
+	if err != nil {
+		return fmt.Errorf("invalid start of subscription %q: %w", anchorDate, err)
+	}
+	// Runs before the next one are already executed (and part of Runs)
+	pending := from
+	if s.Next != "" {
+		if pending, err = time.Parse("2006-01-02", s.Next); err != nil {
+			return fmt.Errorf("invalid next run of subscription %q: %w", s.Next, err)
+		}
+		if !onSchedule(anchor, pending, weeks, months) {
+			anchor = pending
+		}
+	}
+
+	remaining := maxScheduledRuns
+	if s.StopAfter > 0 {
+		remaining = s.StopAfter - s.Runs
+	}
+	for i := 0; remaining > 0 && i < maxScheduledRuns; i++ {
+		run := scheduledRun(anchor, i, weeks, months)
+		if !until.IsZero() && run.After(until) {
+			return nil
+		}
+		if run.Before(pending) {
+			continue
+		}
+		remaining--
+		if !run.Before(from) && !fn(run) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// scheduledRun returns the i-th run of a schedule starting at anchor
+func scheduledRun(anchor time.Time, i int, weeks int, months int) time.Time {
+	if months > 0 {
+		return addMonths(anchor, i*months)
+	}
+	return anchor.AddDate(0, 0, i*weeks*7)
+}
+
+// onSchedule returns true when date is one of the runs of the schedule starting at anchor
+func onSchedule(anchor time.Time, date time.Time, weeks int, months int) bool {
+	for i := 0; i < maxScheduledRuns; i++ {
+		run := scheduledRun(anchor, i, weeks, months)
+		if !run.Before(date) {
+			return run.Equal(date)
+		}
+	}
+	return false
+}
+
+// NextRuns returns the dates of the next n runs of the subscription starting from its next run (or today). Fewer
+// dates are returned when the subscription stops earlier. This is a local projection, the actual collection may be
+// moved by Twikey (eg. for bank holidays).
+func (s *Subscription) NextRuns(n int) ([]time.Time, error) {
+	return s.NextRunsFrom(time.Now(), n)
+}
+
+// NextRunsFrom returns the dates of the next n runs of the subscription on or after the given date (eg. the time of
+// the TimeProvider of the client), see NextRuns.
+func (s *Subscription) NextRunsFrom(from time.Time, n int) ([]time.Time, error) {
+	var dates []time.Time
+	if n <= 0 {
+		return dates, nil
+	}
+	err := s.runs(dateOf(from), time.Time{}, func(run time.Time) bool {
+		dates = append(dates, run)
+		return len(dates) < n
+	})
+	return dates, err
+}
+
+// ForecastPeriod defines the granularity of a Forecast
+type ForecastPeriod string
+
+const (
+	ForecastDaily   ForecastPeriod = "2006-01-02"
+	ForecastMonthly ForecastPeriod = "2006-01"
+)
+
+// ForecastEntry contains the expected collections in a period
+type ForecastEntry struct {
+	Period string  `json:"period"` // yyyy-mm-dd or yyyy-mm depending on the granularity
+	Count  int     `json:"count"`  // Number of expected collections
+	Amount float64 `json:"amount"` // Total amount of the expected collections
+}
+
+// Forecast contains the expected collections of a set of subscriptions
+type Forecast struct {
+	From    string          `json:"from"`
+	Until   string          `json:"until"`
+	Entries []ForecastEntry `json:"entries"` // Sorted by period, periods without collections are omitted
+	Count   int             `json:"count"`
+	Amount  float64         `json:"amount"`
+	// Subscriptions that could not be projected (eg. an unknown recurrence) by mandate/ref
+	Skipped map[string]string `json:"skipped,omitempty"`
+
+	periods map[string]*ForecastEntry
+}
+
+// NewForecast projects the runs of all active subscriptions between from and until (inclusive) grouped by period
+func NewForecast(subscriptions []Subscription, from time.Time, until time.Time, period ForecastPeriod) *Forecast {
+	forecast := newForecast(from, until)
+	forecast.add(subscriptions, period)
+	forecast.finish()
+	return forecast
+}
+
+func newForecast(from time.Time, until time.Time) *Forecast {
+	return &Forecast{
+		From:    from.Format("2006-01-02"),
+		Until:   until.Format("2006-01-02"),
+		periods: make(map[string]*ForecastEntry),
+	}
+}
+
+// add accumulates the runs of the active subscriptions per period
+func (f *Forecast) add(subscriptions []Subscription, period ForecastPeriod) {
+	if period == "" {
+		period = ForecastMonthly
+	}
+	from, _ := time.Parse("2006-01-02", f.From)
+	until, _ := time.Parse("2006-01-02", f.Until)
+	for i := range subscriptions {
+		subscription := &subscriptions[i]
+		if subscription.State != SubscriptionStateActive {
+			continue
+		}
+		err := subscription.runs(from, until, func(run time.Time) bool {
+			key := run.Format(string(period))
+			entry, exists := f.periods[key]
+			if !exists {
+				entry = &ForecastEntry{Period: key}
+				f.periods[key] = entry
+			}
+			entry.Count++
+			entry.Amount += subscription.Amount
+			return true
+		})
+		if err != nil {
+			if f.Skipped == nil {
+				f.Skipped = make(map[string]string)
+			}
+			f.Skipped[subscription.MndtId+"/"+subscription.Ref] = err.Error()
+		}
+	}
+}
+
+// finish sorts the entries and computes the totals
+func (f *Forecast) finish() {
+	f.Entries = make([]ForecastEntry, 0, len(f.periods))
+	for _, entry := range f.periods {
+		entry.Amount = roundAmount(entry.Amount)
+		f.Entries = append(f.Entries, *entry)
+		f.Count += entry.Count
+		f.Amount += entry.Amount
+	}
+	sort.Slice(f.Entries, func(i, j int) bool {
+		return f.Entries[i].Period < f.Entries[j].Period
+	})
+	f.Amount = roundAmount(f.Amount)
+}
+
+// WriteCSV writes the entries of the forecast as csv with a header line
+func (f *Forecast) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"period", "count", "amount"}); err != nil {
+		return err
+	}
+	for _, entry := range f.Entries {
+		record := []string{entry.Period, strconv.Itoa(entry.Count), strconv.FormatFloat(entry.Amount, 'f', 2, 64)}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// WriteJSON writes the forecast as json
+func (f *Forecast) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(f)
+}
+
+// SubscriptionForecast builds a Forecast for all subscriptions matching the query, fetching all pages. The state of
+// the query defaults to active as only active subscriptions result in collections.
+func (c *Client) SubscriptionForecast(ctx context.Context, query *SubscriptionListRequest, from time.Time, until time.Time, period ForecastPeriod) (*Forecast, error) {
+	if until.Before(from) {
+		return nil, NewTwikeyError("err_invalid_period", "The end of the forecast should be after its start", "")
+	}
+	request := SubscriptionListRequest{}
+	if query != nil {
+		request = *query
+	}
+	if request.State == "" {
+		request.State = SubscriptionStateActive
+	}
+
+	subscriptions, err := c.Subscriptions(ctx, &request).All()
+	if err != nil {
+		return nil, err
+	}
+	forecast := newForecast(from, until)
+	forecast.add(subscriptions, period)
+	forecast.finish()
+	return forecast, nil
+}
@@ -0,0 +1,122 @@
+package twikey
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func parseDate(value string) time.Time {
+	t, _ := time.Parse("2006-01-02", value)
+	return t
+}
+
+func TestSubscriptionNextRunsMonthEnd(t *testing.T) {
+	subscription := &Subscription{
+		State:      SubscriptionStateActive,
+		Start:      "2023-01-31",
+		Recurrence: RecurrenceMonthly,
+	}
+	runs, err := subscription.NextRunsFrom(parseDate("2023-01-01"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 4, len(runs))
+	AssertEquals(t, parseDate("2023-01-31"), runs[0])
+	AssertEquals(t, parseDate("2023-02-28"), runs[1])
+	AssertEquals(t, parseDate("2023-03-31"), runs[2]) // no drift after a short month
+	AssertEquals(t, parseDate("2023-04-30"), runs[3])
+}
+
+func TestSubscriptionNextRunsStopAfter(t *testing.T) {
+	subscription := &Subscription{
+		State:      SubscriptionStateActive,
+		Start:      "2023-01-15",
+		Next:       "2023-07-15",
+		Runs:       2,
+		StopAfter:  4,
+		Recurrence: RecurrenceQuarterly,
+	}
+	runs, err := subscription.NextRunsFrom(parseDate("2023-01-01"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 2, len(runs))
+	AssertEquals(t, parseDate("2023-07-15"), runs[0])
+	AssertEquals(t, parseDate("2023-10-15"), runs[1])
+
+	subscription = &Subscription{Start: "2023-01-01", Recurrence: RecurrenceWeekly}
+	runs, _ = subscription.NextRunsFrom(parseDate("2023-01-01"), 2)
+	AssertEquals(t, parseDate("2023-01-08"), runs[1])
+
+	// a moved next run isn't on the schedule of the start, the schedule continues from there
+	subscription = &Subscription{Start: "2023-01-15", Next: "2023-03-20", Recurrence: RecurrenceMonthly}
+	runs, _ = subscription.NextRunsFrom(parseDate("2023-03-01"), 2)
+	AssertEquals(t, parseDate("2023-03-20"), runs[0])
+	AssertEquals(t, parseDate("2023-04-20"), runs[1])
+
+	subscription = &Subscription{Start: "2023-01-01", Recurrence: "1y"}
+	if _, err = subscription.NextRunsFrom(parseDate("2023-01-01"), 1); err == nil {
+		t.Error("Expected an invalid recurrence")
+	}
+}
+
+func TestForecast(t *testing.T) {
+	subscriptions := []Subscription{
+		{MndtId: "M1", Ref: "A", State: SubscriptionStateActive, Amount: 10, Start: "2023-01-31", Recurrence: RecurrenceMonthly},
+		{MndtId: "M2", Ref: "B", State: SubscriptionStateActive, Amount: 2.5, Start: "2023-01-01", Recurrence: RecurrenceWeekly, StopAfter: 3},
+		{MndtId: "M3", Ref: "C", State: SubscriptionStateSuspended, Amount: 100, Start: "2023-01-01"},
+		{MndtId: "M4", Ref: "D", State: SubscriptionStateActive, Amount: 1, Start: "2023-01-01", Recurrence: "x"},
+	}
+	forecast := NewForecast(subscriptions, parseDate("2023-01-01"), parseDate("2023-03-31"), ForecastMonthly)
+	AssertEquals(t, 3, len(forecast.Entries))
+	AssertEquals(t, ForecastEntry{Period: "2023-01", Count: 4, Amount: 17.5}, forecast.Entries[0])
+	AssertEquals(t, ForecastEntry{Period: "2023-02", Count: 1, Amount: 10}, forecast.Entries[1])
+	AssertEquals(t, 6, forecast.Count)
+	AssertEquals(t, 37.5, forecast.Amount)
+	AssertEquals(t, 1, len(forecast.Skipped))
+
+	var out bytes.Buffer
+	if err := forecast.WriteCSV(&out); err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, "period,count,amount\n2023-01,4,17.50\n2023-02,1,10.00\n2023-03,1,10.00\n", out.String())
+
+	daily := NewForecast(subscriptions, parseDate("2023-01-01"), parseDate("2023-01-31"), ForecastDaily)
+	AssertEquals(t, "2023-01-01", daily.Entries[0].Period)
+	AssertEquals(t, 4, len(daily.Entries))
+}
+
+func TestSubscriptionForecast(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AssertEquals(t, "active", r.URL.Query().Get("state"))
+		if r.URL.Query().Get("page") == "" {
+			_, _ = w.Write([]byte(`{"Subscriptions":[{"state":"active","amount":5,"start":"2023-01-10","recurrence":"1m"}],"_links":{"next":"page=1"}}`))
+		} else {
+			_, _ = w.Write([]byte(`{"Subscriptions":[{"state":"active","amount":7,"start":"2023-02-10","recurrence":"1m"}],"_links":{}}`))
+		}
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+
+	forecast, err := cl.SubscriptionForecast(context.Background(), nil, parseDate("2023-01-01"), parseDate("2023-02-28"), ForecastMonthly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 2, len(forecast.Entries))
+	AssertEquals(t, 17.0, forecast.Amount)
+
+	var out bytes.Buffer
+	if err = forecast.WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Forecast
+	if err = json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, "2023-02", decoded.Entries[1].Period)
+}
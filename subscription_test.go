@@ -73,6 +73,8 @@ func TestSubscriptionAdd(t *testing.T) {
 	}))
 	defer server.Close()
 	cl := NewMockedTestClient(server)
+	cl.TimeProvider = &TestTimeProvider{currentTime: time.Date(2022, 11, 28, 12, 0, 0, 0, time.UTC)}
+	cl.lastLogin = cl.TimeProvider.Now()
 
 	ctx := context.TODO()
 	output, err := cl.SubscriptionAdd(ctx, &SubscriptionAddRequest{
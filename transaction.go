@@ -2,13 +2,10 @@ package twikey
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -38,6 +35,39 @@ type ReservationRequest struct {
 	Force             bool
 }
 
+// Validate checks the request locally and returns a ValidationError listing all invalid fields
+func (request *TransactionRequest) Validate() error {
+	v := validation{}
+	v.required("DocumentReference", request.DocumentReference)
+	v.required("Msg", request.Msg)
+	if request.Amount == 0 {
+		v.fail("Amount", "is required")
+	}
+	v.date("TransactionDate", request.TransactionDate)
+	v.date("RequestedCollection", request.RequestedCollection)
+	return v.err()
+}
+
+// Validate checks the request locally and returns a ValidationError listing all invalid fields
+func (request *ReservationRequest) Validate() error {
+	return request.validateAt(time.Now())
+}
+
+func (request *ReservationRequest) validateAt(now time.Time) error {
+	v := validation{}
+	v.required("DocumentReference", request.DocumentReference)
+	if request.Amount <= 0 {
+		v.fail("Amount", "should be positive")
+	}
+	if request.Minimum < 0 || request.Minimum > request.Amount {
+		v.fail("Minimum", "should be between 0 and the amount")
+	}
+	if request.Expiration != nil && request.Expiration.Before(now) {
+		v.fail("Expiration", "should be in the future")
+	}
+	return v.err()
+}
+
 type TransactionDeleteRequest struct {
 	// ID of the transaction to delete
 	ID string
@@ -63,6 +93,14 @@ type Transaction struct {
 	BookedError         string  `json:"bkerror"`
 	BookedAmount        float64 `json:"bkamount"`
 	RequestedCollection string  `json:"reqcolldt"`
+
+	IdempotencyKey   string `json:"-"` // key used when creating the transaction
+	IdempotentReplay bool   `json:"-"` // true if Twikey returned the result of a previous call with the same key
+}
+
+func (transaction *Transaction) setIdempotency(key string, replayed bool) {
+	transaction.IdempotencyKey = key
+	transaction.IdempotentReplay = replayed
 }
 
 // Reservation is the response from Twikey when updates are received
@@ -71,6 +109,14 @@ type Reservation struct {
 	MndtId         string    `json:"mndtId"`
 	ReservedAmount float64   `json:"reservedAmount"`
 	Expires        time.Time `json:"expires"`
+
+	IdempotencyKey   string `json:"-"` // key used when creating the reservation
+	IdempotentReplay bool   `json:"-"` // true if Twikey returned the result of a previous call with the same key
+}
+
+func (reservation *Reservation) setIdempotency(key string, replayed bool) {
+	reservation.IdempotencyKey = key
+	reservation.IdempotentReplay = replayed
 }
 
 // TransactionList is a struct to contain the response coming from Twikey, should be considered internal
@@ -78,7 +124,15 @@ type TransactionList struct {
 	Entries []Transaction
 }
 
+func (list *TransactionList) setIdempotency(key string, replayed bool) {
+	for i := range list.Entries {
+		list.Entries[i].setIdempotency(key, replayed)
+	}
+}
+
 // CollectResponse is a struct to contain the response coming from Twikey, should be considered internal
+//
+// Deprecated: the response of Collect is a Collection
 type CollectResponse struct {
 	ID string `json:"rcurMsgId"`
 }
@@ -86,6 +140,10 @@ type CollectResponse struct {
 // TransactionNew sends a new transaction to Twikey
 func (c *Client) TransactionNew(ctx context.Context, transaction *TransactionRequest) (*Transaction, error) {
 
+	if err := c.validate(transaction); err != nil {
+		return nil, err
+	}
+
 	params := url.Values{}
 	params.Add("mndtId", transaction.DocumentReference)
 	params.Add("date", transaction.TransactionDate)
@@ -104,9 +162,7 @@ func (c *Client) TransactionNew(ctx context.Context, transaction *TransactionReq
 	c.Debug.Debugf("New transaction %s", params)
 
 	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/creditor/transaction", strings.NewReader(params.Encode()))
-	if transaction.IdempotencyKey != "" {
-		req.Header.Add("Idempotency-Key", transaction.IdempotencyKey)
-	}
+	c.addIdempotencyKey(req, &transaction.IdempotencyKey, []byte(params.Encode()))
 	if transaction.Reservation != "" {
 		req.Header.Add("X-RESERVATION", transaction.Reservation)
 	}
@@ -115,12 +171,19 @@ func (c *Client) TransactionNew(ctx context.Context, transaction *TransactionReq
 	if err != nil {
 		return nil, err
 	}
+	if len(transactionList.Entries) == 0 {
+		return nil, NewTwikeyError("system_error", "No transaction was returned", "")
+	}
 	return &transactionList.Entries[0], nil
 }
 
 // ReservationNew sends a new reservation to Twikey
 func (c *Client) ReservationNew(ctx context.Context, reservationRequest *ReservationRequest) (*Reservation, error) {
 
+	if err := c.validate(reservationRequest); err != nil {
+		return nil, err
+	}
+
 	params := url.Values{}
 	params.Add("mndtId", reservationRequest.DocumentReference)
 	params.Add("message", "ignore")
@@ -137,9 +200,7 @@ func (c *Client) ReservationNew(ctx context.Context, reservationRequest *Reserva
 	}
 	c.Debug.Debugf("New reservation %s", params)
 	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/creditor/reservation", strings.NewReader(params.Encode()))
-	if reservationRequest.IdempotencyKey != "" {
-		req.Header.Add("Idempotency-Key", reservationRequest.IdempotencyKey)
-	}
+	c.addIdempotencyKey(req, &reservationRequest.IdempotencyKey, []byte(params.Encode()))
 	reservation := &Reservation{}
 	err := c.sendRequest(req, reservation)
 	return reservation, err
@@ -159,6 +220,10 @@ func (c *Client) DeleteTransaction(ctx context.Context, request *TransactionDele
 		params.Add("ref", request.Ref)
 	}
 
+	if request.Reservation != "" {
+		params.Add("reservation", request.Reservation)
+	}
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, fmt.Sprintf(c.BaseURL+"/creditor/transaction?%s", params.Encode()), nil)
 	if err != nil {
 		return err
@@ -173,58 +238,55 @@ func (c *Client) DeleteTransaction(ctx context.Context, request *TransactionDele
 
 // TransactionFeed retrieves all transaction updates since the last call with a callback since there may be many
 func (c *Client) TransactionFeed(ctx context.Context, callback func(transaction *Transaction), options ...FeedOption) error {
+	return c.HandleTransactionFeed(ctx, func(transaction *Transaction) error {
+		callback(transaction)
+		return nil
+	}, options...)
+}
 
-	if err := c.refreshTokenIfRequired(); err != nil {
-		return err
-	}
-
-	feedOptions := parseFeedOptions(options)
-	_url := c.BaseURL + "/creditor/transaction"
-	for i, sideload := range feedOptions.includes {
-		if i == 0 {
-			_url = _url + "?include=" + sideload
-		} else {
-			_url = _url + "&include=" + sideload
-		}
-	}
-
+// HandleTransactionFeed retrieves all transaction updates since the last call, the first error returned by the handler
+// stops the feed and is returned as a FeedError containing the Seq of the last handled transaction.
+func (c *Client) HandleTransactionFeed(ctx context.Context, handler func(transaction *Transaction) error, options ...FeedOption) error {
+	feed := c.newFeedReader(ctx, "/creditor/transaction", options)
 	for {
-		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, _url, nil)
-		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-		req.Header.Add("Authorization", c.apiToken)
-		req.Header.Set("User-Agent", c.UserAgent)
-		if feedOptions.start != -1 {
-			req.Header.Set("X-RESUME-AFTER", fmt.Sprintf("%d", feedOptions.start))
-			feedOptions.start = -1
-		}
-
-		res, err := c.HTTPClient.Do(req)
-		if err != nil {
+		var paymentResponse TransactionList
+		if err := feed.next(&paymentResponse); err != nil {
 			return err
 		}
-		if res.StatusCode == 200 {
-			payload, _ := io.ReadAll(res.Body)
-			_ = res.Body.Close()
-			var paymentResponse TransactionList
-			err := json.Unmarshal(payload, &paymentResponse)
-			if err == nil {
-				c.Debug.Debugf("Fetched %d transactions", len(paymentResponse.Entries))
-				for _, transaction := range paymentResponse.Entries {
-					callback(&transaction)
-				}
-			} else {
+		c.Debug.Debugf("Fetched %d transactions", len(paymentResponse.Entries))
+		for i := range paymentResponse.Entries {
+			transaction := &paymentResponse.Entries[i]
+			if err := feed.handled(transaction.Seq, handler(transaction)); err != nil {
 				return err
 			}
-			if len(paymentResponse.Entries) == 0 {
-				return nil
-			}
-		} else {
-			c.Debug.Debugf("Error response from Twikey: %d", res.StatusCode)
-			return NewTwikeyErrorFromResponse(res)
+		}
+		if len(paymentResponse.Entries) == 0 {
+			return nil
 		}
 	}
 }
 
+// TransactionStream delivers the transaction feed over a channel, allowing it to be consumed by eg. a pool of workers.
+// The channel is closed when the feed is empty, when an error occurred (see FeedStream.Errors) or when the context is cancelled.
+func (c *Client) TransactionStream(ctx context.Context, options ...FeedOption) (<-chan *Transaction, *FeedStream) {
+	events := make(chan *Transaction)
+	stream := newFeedStream(options)
+	go func() {
+		defer close(events)
+		stream.finish(ctx, c.HandleTransactionFeed(ctx, func(transaction *Transaction) error {
+			stream.delivering(transaction.Seq)
+			select {
+			case events <- transaction:
+				return nil
+			case <-ctx.Done():
+				stream.undelivered()
+				return ctx.Err()
+			}
+		}, options...))
+	}()
+	return events, stream
+}
+
 type CollectOptions struct {
 	// Until is used to filter the eventual transactions that will be sent for collection
 	// this value is interpreted as a unix timestamp using milliseconds precision. Any
@@ -246,7 +308,9 @@ func WithUntil(until int64) CollectionOptionFunc {
 	}
 }
 
-// TransactionCollect collects all open transaction
+// TransactionCollect collects all open transaction and returns the id of the collection
+//
+// Deprecated: use Collect which allows all options and returns the details of the collection
 func (c *Client) TransactionCollect(ctx context.Context, template string, prenotify bool, opts ...CollectionOptionFunc) (string, error) {
 	opt := CollectOptions{}
 
@@ -254,53 +318,21 @@ func (c *Client) TransactionCollect(ctx context.Context, template string, prenot
 		f(&opt)
 	}
 
-	if err := c.refreshTokenIfRequired(); err != nil {
-		return "", err
-	}
-
 	if template == "" {
 		return "", NewTwikeyError("err_invalid_template", "A template is required", "")
 	}
 
-	params := url.Values{}
-	if _, err := strconv.Atoi(template); err == nil {
-		params.Add("ct", template)
-	} else {
-		params.Add("tc", template)
+	request := &CollectRequest{
+		Template:  template,
+		Prenotify: prenotify,
 	}
-	// TODO: Add this the the optional parameters
-	if prenotify {
-		params.Add("prenotify", "true")
-	}
-
 	if opt.Until != 0 {
-		params.Add("until", strconv.FormatInt(opt.Until, 10))
+		until := time.Unix(0, opt.Until*int64(time.Millisecond))
+		request.Until = &until
 	}
-
-	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/creditor/collect", strings.NewReader(params.Encode()))
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.apiToken)
-	req.Header.Set("User-Agent", c.UserAgent)
-	res, err := c.HTTPClient.Do(req)
-
-	c.Debug.Debugf("Collected transaction for %s using %s", template, params.Encode())
-
+	collection, err := c.Collect(ctx, request)
 	if err != nil {
 		return "", err
 	}
-	if res.StatusCode == 200 {
-		payload, _ := io.ReadAll(res.Body)
-		_ = res.Body.Close()
-		var collectionResponse CollectResponse
-		err := json.Unmarshal(payload, &collectionResponse)
-		if err == nil && collectionResponse.ID != "" {
-			c.Debug.Debugf("Collected transaction for %s into %s", template, collectionResponse.ID)
-			return collectionResponse.ID, nil
-		} else {
-			return "", err
-		}
-	} else {
-		c.Debug.Debugf("Error response from Twikey: %d", res.StatusCode)
-		return "", NewTwikeyErrorFromResponse(res)
-	}
+	return collection.Id, nil
 }
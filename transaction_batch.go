@@ -0,0 +1,348 @@
+package twikey
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// TransactionBatchItem is a single transaction of a batch
+type TransactionBatchItem struct {
+	// Key identifies the item within the batch (eg. the reference or the line in the file). It is used in the
+	// journal and to derive the idempotency key, so it should be the same when the batch is run again.
+	Key     string
+	Request *TransactionRequest
+}
+
+// TransactionIterator provides the items of a batch one by one, Next returns io.EOF when no more items are available.
+// When an item can't be read an error can be returned along with the item (having at least its Key) to report
+// the failure and continue with the next one. An error without an item stops the batch.
+type TransactionIterator interface {
+	Next() (*TransactionBatchItem, error)
+}
+
+type transactionSliceIterator struct {
+	requests []*TransactionRequest
+	pos      int
+}
+
+func (it *transactionSliceIterator) Next() (*TransactionBatchItem, error) {
+	if it.pos >= len(it.requests) {
+		return nil, io.EOF
+	}
+	request := it.requests[it.pos]
+	it.pos++
+	key := request.Ref
+	if key == "" {
+		key = "item " + strconv.Itoa(it.pos)
+	}
+	return &TransactionBatchItem{Key: key, Request: request}, nil
+}
+
+// TransactionsFromSlice iterates over the passed requests, the key of every item is the reference or its
+// position in the slice when there is no reference.
+func TransactionsFromSlice(requests []*TransactionRequest) TransactionIterator {
+	return &transactionSliceIterator{requests: requests}
+}
+
+// transactionRow is a transaction as found in an import file, using the names of the api
+type transactionRow struct {
+	Key         string  `json:"key"`
+	MndtId      string  `json:"mndtId"`
+	Date        string  `json:"date"`
+	Reqcolldt   string  `json:"reqcolldt"`
+	Message     string  `json:"message"`
+	Ref         string  `json:"ref"`
+	Amount      float64 `json:"amount"`
+	Place       string  `json:"place"`
+	Reservation string  `json:"reservation"`
+	Force       bool    `json:"force"`
+	Refase2e    bool    `json:"refase2e"`
+}
+
+// set assigns a value read from a csv file to the field with the given (lowercase) api name. Amounts can only use a
+// decimal comma in semicolon separated files, elsewhere "1,234" is as likely a thousands separator and refused.
+func (row *transactionRow) set(name string, value string, decimalComma bool) error {
+	var err error
+	switch name {
+	case "key":
+		row.Key = value
+	case "mndtid":
+		row.MndtId = value
+	case "date":
+		row.Date = value
+	case "reqcolldt":
+		row.Reqcolldt = value
+	case "message", "msg":
+		row.Message = value
+	case "ref":
+		row.Ref = value
+	case "amount":
+		if value != "" {
+			if decimalComma && !strings.Contains(value, ".") {
+				value = strings.Replace(value, ",", ".", 1)
+			}
+			if row.Amount, err = strconv.ParseFloat(value, 64); err != nil {
+				return fmt.Errorf("invalid amount %q", value)
+			}
+		}
+	case "place":
+		row.Place = value
+	case "reservation":
+		row.Reservation = value
+	case "force":
+		if value != "" {
+			row.Force, err = strconv.ParseBool(value)
+		}
+	case "refase2e":
+		if value != "" {
+			row.Refase2e, err = strconv.ParseBool(value)
+		}
+	}
+	return err
+}
+
+// item converts the row into a batch item, the fallback is used as key when the row has no key or ref
+func (row *transactionRow) item(fallback string) *TransactionBatchItem {
+	key := row.Key
+	if key == "" {
+		key = row.Ref
+	}
+	if key == "" {
+		key = fallback
+	}
+	return &TransactionBatchItem{
+		Key: key,
+		Request: &TransactionRequest{
+			DocumentReference:             row.MndtId,
+			TransactionDate:               row.Date,
+			RequestedCollection:           row.Reqcolldt,
+			Msg:                           row.Message,
+			Ref:                           row.Ref,
+			Amount:                        row.Amount,
+			Place:                         row.Place,
+			Reservation:                   row.Reservation,
+			Force:                         row.Force,
+			ReferenceIsEndToEndIdentifier: row.Refase2e,
+		},
+	}
+}
+
+// TransactionColumns maps the headers of a csv file to the api names of the fields of a transaction:
+// key, mndtId, date, reqcolldt, message, ref, amount, place, reservation, force and refase2e.
+// Headers matching one of those names (case insensitive) don't need to be mapped, other columns are ignored.
+type TransactionColumns map[string]string
+
+type csvTransactionIterator struct {
+	reader  *csv.Reader
+	columns []string
+	row     int
+}
+
+func (it *csvTransactionIterator) Next() (*TransactionBatchItem, error) {
+	record, err := it.reader.Read()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	it.row++
+	fallback := "row " + strconv.Itoa(it.row)
+	if err != nil {
+		var parseError *csv.ParseError
+		if errors.As(err, &parseError) {
+			return &TransactionBatchItem{Key: fallback}, err
+		}
+		return nil, err
+	}
+	// all columns are read before reporting an invalid value so the item has its key
+	row := &transactionRow{}
+	for i, value := range record {
+		if i < len(it.columns) {
+			if invalid := row.set(it.columns[i], strings.TrimSpace(value), it.reader.Comma == ';'); invalid != nil && err == nil {
+				err = invalid
+			}
+		}
+	}
+	return row.item(fallback), err
+}
+
+// TransactionsFromCSV reads transactions from a csv file with a header line, the columns are mapped to the fields
+// of the transaction using the api names or the passed columns. Both comma and semicolon separated files are
+// supported, amounts can only have a decimal comma in the latter. Rows without a key or ref get their position as key (eg. "row 12", not counting the header), so a
+// file shouldn't be changed before resuming a batch using such keys.
+func TransactionsFromCSV(r io.Reader, columns TransactionColumns) (TransactionIterator, error) {
+	buffered := bufio.NewReader(r)
+	header, err := buffered.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	header = strings.TrimPrefix(header, "\ufeff")
+	comma := ','
+	if strings.Count(header, ";") > strings.Count(header, ",") {
+		comma = ';'
+	}
+
+	reader := csv.NewReader(io.MultiReader(strings.NewReader(header), buffered))
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	names, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	mapped := make([]string, len(names))
+	found := false
+	for i, name := range names {
+		name = strings.TrimSpace(name)
+		if field, ok := columns[name]; ok {
+			name = field
+		}
+		mapped[i] = strings.ToLower(name)
+		found = found || mapped[i] == "mndtid"
+	}
+	if !found {
+		return nil, errors.New("no mndtId column found in " + strings.Join(names, string(comma)))
+	}
+	return &csvTransactionIterator{reader: reader, columns: mapped}, nil
+}
+
+type jsonlTransactionIterator struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (it *jsonlTransactionIterator) Next() (*TransactionBatchItem, error) {
+	for it.scanner.Scan() {
+		it.line++
+		content := strings.TrimSpace(it.scanner.Text())
+		if content == "" {
+			continue
+		}
+		row := &transactionRow{}
+		if err := json.Unmarshal([]byte(content), row); err != nil {
+			return &TransactionBatchItem{Key: "line " + strconv.Itoa(it.line)}, err
+		}
+		return row.item("line " + strconv.Itoa(it.line)), nil
+	}
+	if err := it.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// TransactionsFromJSONL reads transactions from a file containing a json object per line, using the api names
+// as described in TransactionColumns. Rows without a key or ref get the line number as key.
+func TransactionsFromJSONL(r io.Reader) TransactionIterator {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &jsonlTransactionIterator{scanner: scanner}
+}
+
+// TransactionBatchResult is the outcome of a single item of the batch
+type TransactionBatchResult struct {
+	Key         string
+	Id          int64        // id of the created transaction (also when skipped if the journal has it)
+	Transaction *Transaction // the created transaction (nil when skipped or failed)
+	Skipped     bool         // true if the journal shows the item was accepted in a previous run
+	Err         error
+}
+
+// TransactionBatch creates many transactions with bounded concurrency, failures of single transactions don't
+// stop the batch.
+type TransactionBatch struct {
+	client *Client
+	// Concurrency is the number of transactions sent at the same time (default 4)
+	Concurrency int
+	// RequestsPerSecond limits the rate at which transactions are sent, 0 means no limit
+	RequestsPerSecond float64
+	// JournalPath is the file in which the accepted items are recorded, running the batch again with
+	// the same journal skips those items. When empty no journal is kept.
+	JournalPath string
+}
+
+// NewTransactionBatch creates a batch uploader using this client
+func (c *Client) NewTransactionBatch() *TransactionBatch {
+	return &TransactionBatch{
+		client:      c,
+		Concurrency: 4,
+	}
+}
+
+// Run sends all transactions of the iterator and streams the result of every item over the returned channel, which
+// is closed when all items are handled or the context is cancelled. The channel must be drained by the caller.
+// Items without an IdempotencyKey get one derived from their key and content so a retry never creates duplicates.
+func (b *TransactionBatch) Run(ctx context.Context, items TransactionIterator) (<-chan *TransactionBatchResult, error) {
+	results := make(chan *TransactionBatchResult)
+	next := func() (*batchJob, error) {
+		item, err := items.Next()
+		if item == nil {
+			return nil, err
+		}
+		return &batchJob{key: item.Key, send: func(ctx context.Context) batchOutcome {
+			return b.create(ctx, item)
+		}}, err
+	}
+	emit := func(outcome batchOutcome) {
+		result := &TransactionBatchResult{Key: outcome.key, Skipped: outcome.skipped, Err: outcome.err}
+		result.Id, _ = strconv.ParseInt(outcome.id, 10, 64)
+		result.Transaction, _ = outcome.created.(*Transaction)
+		select {
+		case results <- result:
+		case <-ctx.Done():
+		}
+	}
+	settings := batchSettings{concurrency: b.Concurrency, requestsPerSecond: b.RequestsPerSecond, journalPath: b.JournalPath}
+	if err := runBatch(ctx, settings, next, emit, func() { close(results) }); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (b *TransactionBatch) create(ctx context.Context, item *TransactionBatchItem) batchOutcome {
+	if item.Request == nil {
+		return batchOutcome{err: errors.New("no transaction for " + item.Key)}
+	}
+	if item.Request.IdempotencyKey == "" {
+		payload, _ := json.Marshal(item.Request)
+		item.Request.IdempotencyKey = DerivedIdempotencyKey(item.Key, payload)
+	}
+	transaction, err := b.client.TransactionNew(ctx, item.Request)
+	if err != nil {
+		return batchOutcome{err: err}
+	}
+	return batchOutcome{id: strconv.FormatInt(transaction.Id, 10), created: transaction}
+}
+
+// WriteTransactionResults drains the results of a batch into a csv file with the columns key, status (created,
+// skipped or failed), id and error. It returns the number of failed items, the results are always drained even
+// when writing fails.
+func WriteTransactionResults(w io.Writer, results <-chan *TransactionBatchResult) (int, error) {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{"key", "status", "id", "error"})
+	failed := 0
+	for result := range results {
+		status, id, msg := "created", "", ""
+		switch {
+		case result.Err != nil:
+			status, msg = "failed", result.Err.Error()
+			failed++
+		case result.Skipped:
+			status = "skipped"
+		}
+		if result.Id != 0 {
+			id = strconv.FormatInt(result.Id, 10)
+		}
+		if err == nil {
+			err = writer.Write([]string{result.Key, status, id, msg})
+		}
+	}
+	writer.Flush()
+	if err == nil {
+		err = writer.Error()
+	}
+	return failed, err
+}
@@ -0,0 +1,165 @@
+package twikey
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTransactionBatchFromCSV(t *testing.T) {
+	var mutex sync.Mutex
+	calls := map[string]int{}
+	keys := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AssertEquals(t, "/creditor/transaction", r.URL.Path)
+		_ = r.ParseForm()
+		ref := r.Form.Get("ref")
+		mutex.Lock()
+		calls[ref]++
+		keys[ref] = r.Header.Get("Idempotency-Key")
+		mutex.Unlock()
+		if ref == "R3" {
+			w.Header().Set("ApiError", "err_no_contract")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"Entries":[{"id":` + strings.TrimPrefix(ref, "R") + `,"mndtId":"` + r.Form.Get("mndtId") + `","amount":` + r.Form.Get("amount") + `}]}`))
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+
+	content := "\ufeffContract;Total;message;ref;unused\n" +
+		"MNDT1;10,50;Usage january;R1;x\n" +
+		"MNDT2;20;Usage january;R2;x\n" +
+		"MNDT3;30;Usage january;R3;x\n" +
+		"MNDT4;abc;Usage january;R4;x\n"
+	columns := TransactionColumns{"Contract": "mndtId", "Total": "amount"}
+	journal := filepath.Join(t.TempDir(), "journal.txt")
+
+	run := func() string {
+		items, err := TransactionsFromCSV(strings.NewReader(content), columns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		batch := cl.NewTransactionBatch()
+		batch.Concurrency = 2
+		batch.RequestsPerSecond = 1000
+		batch.JournalPath = journal
+		results, err := batch.Run(context.Background(), items)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		failed, err := WriteTransactionResults(&out, results)
+		if err != nil {
+			t.Fatal(err)
+		}
+		AssertEquals(t, 2, failed)
+		return out.String()
+	}
+
+	output := run()
+	AssertEquals(t, true, strings.HasPrefix(output, "key,status,id,error\n"))
+	AssertEquals(t, true, strings.Contains(output, "R1,created,1,\n"))
+	AssertEquals(t, true, strings.Contains(output, "R2,created,2,\n"))
+	AssertEquals(t, true, strings.Contains(output, "R3,failed,,"))
+	AssertEquals(t, true, strings.Contains(output, `R4,failed,,"invalid amount ""abc"""`))
+	firstKey := keys["R3"]
+
+	// resuming skips the accepted rows and retries the failed one with the same key
+	output = run()
+	AssertEquals(t, true, strings.Contains(output, "R1,skipped,1,\n"))
+	AssertEquals(t, true, strings.Contains(output, "R2,skipped,2,\n"))
+	AssertEquals(t, 1, calls["R1"])
+	AssertEquals(t, 2, calls["R3"])
+	AssertEquals(t, firstKey, keys["R3"])
+}
+
+func TestTransactionsFromJSONL(t *testing.T) {
+	content := `{"mndtId":"MNDT1","amount":10.5,"message":"Usage","ref":"R1"}
+
+{"mndtId":"MNDT2","amount":5,"message":"Usage","key":"k2","refase2e":true}
+{"mndtId":"MNDT3","amount":5,"message":"Usage"}
+{broken`
+	items := TransactionsFromJSONL(strings.NewReader(content))
+	var keys []string
+	for {
+		item, err := items.Next()
+		if item == nil {
+			AssertEquals(t, "EOF", err.Error())
+			break
+		}
+		keys = append(keys, item.Key)
+		if item.Key == "k2" {
+			AssertEquals(t, "MNDT2", item.Request.DocumentReference)
+			AssertEquals(t, true, item.Request.ReferenceIsEndToEndIdentifier)
+		}
+		if item.Key == "line 5" && err == nil {
+			t.Error("Expected an error for the broken line")
+		}
+	}
+	AssertEquals(t, "R1,k2,line 4,line 5", strings.Join(keys, ","))
+}
+
+func TestTransactionsFromCSVWithoutMandate(t *testing.T) {
+	_, err := TransactionsFromCSV(strings.NewReader("amount,message\n10,test\n"), nil)
+	if err == nil {
+		t.Error("Expected an error without mndtId column")
+	}
+}
+
+func TestTransactionsFromCSVDecimalComma(t *testing.T) {
+	content := "mndtId,amount,ref\n" +
+		"MNDT1,10.50,R1\n" +
+		"MNDT2,\"1,234\",R2\n"
+	items, err := TransactionsFromCSV(strings.NewReader(content), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := items.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEquals(t, 10.5, item.Request.Amount)
+
+	// in a comma separated file the comma can't be told apart from a thousands separator
+	item, err = items.Next()
+	AssertEquals(t, "R2", item.Key)
+	if err == nil || item.Request.Amount != 0 {
+		t.Errorf("Expected an ambiguous amount to be refused, got %v", item.Request.Amount)
+	}
+}
+
+// transactionIteratorFunc allows a function to be used as TransactionIterator
+type transactionIteratorFunc func() (*TransactionBatchItem, error)
+
+func (f transactionIteratorFunc) Next() (*TransactionBatchItem, error) {
+	return f()
+}
+
+func TestTransactionBatchNilItem(t *testing.T) {
+	calls := 0
+	items := transactionIteratorFunc(func() (*TransactionBatchItem, error) {
+		calls++
+		if calls > 1 {
+			return nil, io.EOF
+		}
+		return nil, nil
+	})
+	results, err := NewClient("TEST_API_KEY").NewTransactionBatch().Run(context.Background(), items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all []*TransactionBatchResult
+	for result := range results {
+		all = append(all, result)
+	}
+	AssertEquals(t, 1, len(all))
+	AssertEquals(t, true, all[0].Err != nil)
+}
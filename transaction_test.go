@@ -2,6 +2,8 @@ package twikey
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
@@ -91,3 +93,24 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func TestTransactionNewEmptyResponse(t *testing.T) {
+	body := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+	c := NewMockedTestClient(server)
+	request := &TransactionRequest{DocumentReference: "MNDT1", Msg: "Test", Amount: 10}
+
+	for _, body = range []string{"", `{"Entries":[]}`} {
+		if _, err := c.TransactionNew(context.Background(), request); err == nil {
+			t.Errorf("Expected an error for the response %q", body)
+		}
+	}
+	body = ""
+	invoice := &Invoice{Number: "1", Date: "2022-01-01", Duedate: "2022-02-01", Amount: 10, Customer: &Customer{CustomerNumber: "1"}}
+	if _, err := c.InvoiceAdd(context.Background(), &NewInvoiceRequest{Invoice: invoice}); err == nil {
+		t.Error("Expected an error for an empty invoice response")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -45,6 +46,14 @@ type Client struct {
 	apiToken     string
 	lastLogin    time.Time
 	TimeProvider TimeProvider
+	// session guards apiToken and lastLogin as the client can be used by several goroutines (eg. a batch)
+	session sync.Mutex
+	// validateAccounts enables the offline validation of ibans and bics before sending a document
+	validateAccounts bool
+	// skipValidation disables the local validation of requests before sending them
+	skipValidation bool
+	// idempotencyKeys generates the keys for mutating calls (if configured)
+	idempotencyKeys IdempotencyKeyGenerator
 }
 
 type ClientOption = func(*Client)
@@ -127,6 +136,24 @@ func WithUserAgent(userAgent string) ClientOption {
 	}
 }
 
+// WithAccountValidation enables the offline validation of the iban and bic of documents before
+// they are sent to Twikey (see DocumentInvite, DocumentSign and DocumentUpdate). This avoids that
+// a customer is contacted for a document that will never be collectable. The accounts are checked
+// even when the other validations are disabled using WithoutValidation.
+func WithAccountValidation() ClientOption {
+	return func(client *Client) {
+		client.validateAccounts = true
+	}
+}
+
+// WithoutValidation disables the local validation of requests (see the Validate method on each request),
+// leaving all checks to Twikey itself.
+func WithoutValidation() ClientOption {
+	return func(client *Client) {
+		client.skipValidation = true
+	}
+}
+
 // NewClient is a convenience method to hit the ground running with the Twikey Rest API
 func NewClient(apiKey string, opts ...ClientOption) *Client {
 	c := &Client{
@@ -168,14 +195,16 @@ func (c *Client) sendRequest(req *http.Request, v interface{}) error {
 		return err
 	}
 
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
 	req.Header.Set("User-Agent", c.UserAgent)
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", c.apiToken)
+	req.Header.Set("Authorization", c.token())
 
 	c.Debug.Tracef("Calling %s %s", req.Method, req.URL)
 
-	res, err := c.HTTPClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		c.Debug.Tracef("Error while connecting %v", err)
 		return err
@@ -189,11 +218,13 @@ func (c *Client) sendRequest(req *http.Request, v interface{}) error {
 	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusBadRequest {
 		if res.Header.Get("Apierror") == "err_no_login" {
 			c.Debug.Tracef("Error while using apitoken, renewing")
-			c.lastLogin = time.Time{} // force re-authenticate
+			c.forceLogin()
 		}
 		var errRes errorResponse
 		if err = json.Unmarshal(payload, &errRes); err == nil {
-			return NewTwikeyError(errRes.Code, errRes.Message, errRes.Extra)
+			twikeyError := NewTwikeyError(errRes.Code, errRes.Message, errRes.Extra)
+			twikeyError.Status = res.StatusCode
+			return twikeyError
 		}
 		return NewTwikeyErrorFromResponse(res)
 	}
@@ -202,10 +233,19 @@ func (c *Client) sendRequest(req *http.Request, v interface{}) error {
 		return nil
 	}
 
-	if err = json.Unmarshal(payload, v); err != nil {
-		return NewTwikeyError("system_error", err.Error(), "")
+	// calls without content (204) still pass the metadata to the result, any other empty body is an error
+	if res.StatusCode != http.StatusNoContent {
+		if err = json.Unmarshal(payload, v); err != nil {
+			return NewTwikeyError("system_error", err.Error(), "")
+		}
 	}
 
+	if result, ok := v.(idempotentResult); ok {
+		result.setIdempotency(req.Header.Get("Idempotency-Key"), isReplayed(res))
+	}
+	if result, ok := v.(warnedResult); ok {
+		result.setWarnings(res.Header.Values("X-Warning"))
+	}
 	return nil
 }
 
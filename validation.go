@@ -0,0 +1,165 @@
+package twikey
+
+import (
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/twikey/twikey-api-go/iban"
+)
+
+// FieldError describes why a single field of a request is invalid
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (err FieldError) Error() string {
+	return err.Field + " " + err.Message
+}
+
+// ValidationError aggregates all invalid fields of a request, it is returned before anything is sent to Twikey
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (err *ValidationError) Error() string {
+	msgs := make([]string, len(err.Fields))
+	for i, field := range err.Fields {
+		msgs[i] = field.Error()
+	}
+	return "invalid request: " + strings.Join(msgs, ", ")
+}
+
+// HasField returns true if the given field was one of the invalid ones
+func (err *ValidationError) HasField(field string) bool {
+	for _, f := range err.Fields {
+		if f.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// validator is implemented by all requests that can be checked locally
+type validator interface {
+	Validate() error
+}
+
+// timedValidator is implemented by the requests of which the validation depends on the current time (eg. a date
+// that should be in the future), their Validate method uses the local clock
+type timedValidator interface {
+	validateAt(now time.Time) error
+}
+
+// validate runs the validation of the request unless it was disabled via WithoutValidation, the current time is
+// taken from the TimeProvider of the client
+func (c *Client) validate(request validator) error {
+	if c.skipValidation {
+		return nil
+	}
+	if timed, ok := request.(timedValidator); ok {
+		return timed.validateAt(c.TimeProvider.Now())
+	}
+	return request.Validate()
+}
+
+// validation collects the field errors while checking a request
+type validation struct {
+	fields []FieldError
+}
+
+func (v *validation) fail(field string, msg string) {
+	v.fields = append(v.fields, FieldError{Field: field, Message: msg})
+}
+
+func (v *validation) required(field string, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.fail(field, "is required")
+	}
+}
+
+func (v *validation) date(field string, value string) {
+	if value == "" {
+		return
+	}
+	if _, err := time.Parse("2006-01-02", value); err != nil {
+		v.fail(field, "should be formatted as yyyy-mm-dd")
+	}
+}
+
+func (v *validation) email(field string, value string) {
+	if value == "" {
+		return
+	}
+	if addr, err := mail.ParseAddress(value); err != nil || addr.Address != value {
+		v.fail(field, "is not a valid email address")
+	}
+}
+
+func (v *validation) isoCode(field string, value string) {
+	if value == "" {
+		return
+	}
+	if len(value) != 2 || strings.IndexFunc(value, func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < 'A' || r > 'Z')
+	}) != -1 {
+		v.fail(field, "should be a 2 letter iso code")
+	}
+}
+
+func (v *validation) decimal(field string, value string) {
+	if value == "" {
+		return
+	}
+	if _, err := strconv.ParseFloat(value, 64); err != nil {
+		v.fail(field, "is not a valid amount")
+	}
+}
+
+func (v *validation) oneOf(field string, value string, allowed ...string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.fail(field, "should be one of "+strings.Join(allowed, ", "))
+}
+
+func (v *validation) account(ibanField string, account string, bicField string, bic string) {
+	if account != "" {
+		if err := iban.Validate(account); err != nil {
+			v.fail(ibanField, err.Error())
+		}
+	}
+	if bic != "" {
+		if err := iban.ValidateBIC(bic); err != nil {
+			v.fail(bicField, err.Error())
+		}
+	}
+}
+
+// merge adds the errors of a nested validation (eg. the customer of an invoice)
+func (v *validation) merge(prefix string, err error) {
+	if verr, ok := err.(*ValidationError); ok {
+		for _, field := range verr.Fields {
+			v.fail(prefix+field.Field, field.Message)
+		}
+	}
+}
+
+func (v *validation) err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: v.fields}
+}
+
+// dateOf returns the date of the given time at midnight UTC, as used when comparing dates passed as yyyy-mm-dd
+func dateOf(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour)
+}
@@ -0,0 +1,81 @@
+package twikey
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRequestValidation(t *testing.T) {
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	tests := []struct {
+		name    string
+		request validator
+		fields  []string
+	}{
+		{"valid invite", &InviteRequest{Template: "1", Email: "john@doe.com", Language: "en", Iban: "BE09363107700857"}, nil},
+		{"invite without template", &InviteRequest{SignDate: "01/02/2022", Amount: "ten"}, []string{"Template", "SignDate", "Amount"}},
+		{"update with invalid state", &UpdateRequest{State: "gone", Country: "BEL"}, []string{"MandateNumber", "State", "Country"}},
+		{"valid transaction", &TransactionRequest{DocumentReference: "MNDT1", Msg: "Test", Amount: 10}, nil},
+		{"empty transaction", &TransactionRequest{TransactionDate: "tomorrow"}, []string{"DocumentReference", "Msg", "Amount", "TransactionDate"}},
+		{"reservation with minimum above amount", &ReservationRequest{DocumentReference: "MNDT1", Amount: 10, Minimum: 20}, []string{"Minimum"}},
+		{"paylink without amount", &PaylinkRequest{Title: "Test"}, []string{"Amount"}},
+		{"paylink for invoice", &PaylinkRequest{Invoice: "INV1"}, nil},
+		{"paylink sent by sms", &PaylinkRequest{Title: "Test", Amount: 1, SendInvite: "sms", RedirectUrl: "twikey.com"}, []string{"Mobile", "RedirectUrl"}},
+		{"invoice without content", &NewInvoiceRequest{}, []string{"Invoice"}},
+		{"invoice without customer", &NewInvoiceRequest{Invoice: &Invoice{Number: "1", Date: "2022-01-01", Duedate: "2022-02-01", Amount: 10}}, []string{"Invoice.Customer"}},
+		{"invoice with invalid customer", &NewInvoiceRequest{Invoice: &Invoice{Number: "1", Date: "2022-01-01", Duedate: "2022-02-01", Amount: 10, Customer: &Customer{CustomerNumber: "1", Email: "none"}}}, []string{"Invoice.Customer.Email"}},
+		{"valid subscription", &SubscriptionAddRequest{MndtId: "MNDT1", Message: "Monthly", Amount: 10, StartDate: tomorrow}, nil},
+		{"invalid subscription", &SubscriptionAddRequest{MndtId: "MNDT1", Message: "Monthly", Amount: 10, StartDate: "29/11/2022", Ref: "My Ref", Recurrence: "5d"}, []string{"StartDate", "Ref", "Recurrence"}},
+		{"subscription via plan", &SubscriptionAddRequest{MndtId: "MNDT1", Plan: "basic", StartDate: tomorrow}, nil},
+		{"anonymous customer", &Customer{}, []string{"CustomerNumber"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.request.Validate()
+			if test.fields == nil {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			verr, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("Expected a validation error, got %v", err)
+			}
+			if len(verr.Fields) != len(test.fields) {
+				t.Errorf("Expected %d invalid fields, got %v", len(test.fields), verr)
+			}
+			for _, field := range test.fields {
+				if !verr.HasField(field) {
+					t.Errorf("Expected %s to be invalid, got %v", field, verr)
+				}
+			}
+		})
+	}
+}
+
+func TestValidationUsesTimeProvider(t *testing.T) {
+	now := time.Date(2030, 1, 15, 12, 0, 0, 0, time.UTC)
+	cl := NewClient("TEST_API_KEY", WithTimeProvider(&TestTimeProvider{currentTime: now}))
+
+	expiration := now.Add(time.Hour)
+	request := &ReservationRequest{DocumentReference: "MNDT1", Amount: 10, Expiration: &expiration}
+	if err := cl.validate(request); err != nil {
+		t.Fatalf("Expected an expiration after the time of the client to be valid, got %v", err)
+	}
+	expiration = now.Add(-time.Hour)
+	if err, ok := cl.validate(request).(*ValidationError); !ok || !err.HasField("Expiration") {
+		t.Errorf("Expected an expiration before the time of the client to be refused, got %v", err)
+	}
+
+	subscription := &SubscriptionAddRequest{MndtId: "MNDT1", Message: "Monthly", Amount: 10, StartDate: "2030-01-16"}
+	if err := cl.validate(subscription); err != nil {
+		t.Fatalf("Expected a start after the date of the client to be valid, got %v", err)
+	}
+	for _, start := range []string{"2030-01-15", "2001-01-01"} {
+		subscription.StartDate = start
+		if err, ok := cl.validate(subscription).(*ValidationError); !ok || !err.HasField("StartDate") {
+			t.Errorf("Expected the start %s to be refused, got %v", start, err)
+		}
+	}
+}
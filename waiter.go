@@ -0,0 +1,282 @@
+package twikey
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// PaymentWaiter lets many callers wait for paylinks to reach a final state or for invoices to be paid. Updates are
+// received via the webhook (see ServeHTTP) and optionally a single shared poller reading the paylink and invoice
+// feeds as a fallback, so the number of calls to Twikey doesn't grow with the number of waiters.
+type PaymentWaiter struct {
+	client *Client
+	// PollInterval enables the poller when positive, it is the time between 2 reads of the feeds while someone is
+	// waiting. Polling is disabled by default as the poller consumes the feeds like PaylinkFeed and InvoiceFeed do,
+	// so it should only be enabled when nothing else reads them (or that reader uses OnPaylink and OnInvoice).
+	PollInterval time.Duration
+	// CheckOnWait fetches the current state when waiting starts, so a link that was paid before the call
+	// resolves immediately (default true)
+	CheckOnWait bool
+	// OnPaylink is called for every paylink read by the poller
+	OnPaylink func(paylink *Paylink)
+	// OnInvoice is called for every invoice read by the poller
+	OnInvoice func(invoice *Invoice)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	paylinks map[int64][]chan *Paylink
+	invoices map[string][]chan *Invoice
+	polling  bool
+}
+
+// NewPaymentWaiter creates a waiter using this client, Close stops the poller
+func (c *Client) NewPaymentWaiter() *PaymentWaiter {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &PaymentWaiter{
+		client:      c,
+		CheckOnWait: true,
+		ctx:         ctx,
+		cancel:      cancel,
+		paylinks:    make(map[int64][]chan *Paylink),
+		invoices:    make(map[string][]chan *Invoice),
+	}
+}
+
+// Close stops the poller, waiting callers continue until their context is done
+func (w *PaymentWaiter) Close() {
+	w.cancel()
+}
+
+// WaitForPaylink blocks until the paylink is paid, declined or expired and returns it in that state. Use a context
+// with a deadline to time out, in which case the context error is returned.
+func (w *PaymentWaiter) WaitForPaylink(ctx context.Context, id int64) (*Paylink, error) {
+	// registered before checking the current state so an update arriving meanwhile isn't missed
+	result := make(chan *Paylink, 1)
+	w.mu.Lock()
+	w.paylinks[id] = append(w.paylinks[id], result)
+	w.startPolling()
+	w.mu.Unlock()
+
+	if w.CheckOnWait {
+		paylink, err := w.client.PaylinkDetail(ctx, &PaylinkLookup{Id: id})
+		if err != nil {
+			w.forgetPaylink(id, result)
+			return nil, err
+		}
+		w.PaylinkUpdated(paylink)
+	}
+
+	select {
+	case paylink := <-result:
+		return paylink, nil
+	case <-ctx.Done():
+		w.forgetPaylink(id, result)
+		return nil, ctx.Err()
+	}
+}
+
+func (w *PaymentWaiter) forgetPaylink(id int64, result chan *Paylink) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	waiters := w.paylinks[id][:0]
+	for _, waiter := range w.paylinks[id] {
+		if waiter != result {
+			waiters = append(waiters, waiter)
+		}
+	}
+	if len(waiters) == 0 {
+		delete(w.paylinks, id)
+	} else {
+		w.paylinks[id] = waiters
+	}
+}
+
+// WaitForInvoicePaid blocks until the invoice (by its id) is paid or archived and returns it in that state. Use a
+// context with a deadline to time out, in which case the context error is returned.
+func (w *PaymentWaiter) WaitForInvoicePaid(ctx context.Context, id string) (*Invoice, error) {
+	// registered before checking the current state so an update arriving meanwhile isn't missed
+	result := make(chan *Invoice, 1)
+	w.mu.Lock()
+	w.invoices[id] = append(w.invoices[id], result)
+	w.startPolling()
+	w.mu.Unlock()
+
+	if w.CheckOnWait {
+		invoice, err := w.client.InvoiceDetail(ctx, id)
+		if err != nil {
+			w.forgetInvoice(id, result)
+			return nil, err
+		}
+		w.InvoiceUpdated(invoice)
+	}
+
+	select {
+	case invoice := <-result:
+		return invoice, nil
+	case <-ctx.Done():
+		w.forgetInvoice(id, result)
+		return nil, ctx.Err()
+	}
+}
+
+func (w *PaymentWaiter) forgetInvoice(id string, result chan *Invoice) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	waiters := w.invoices[id][:0]
+	for _, waiter := range w.invoices[id] {
+		if waiter != result {
+			waiters = append(waiters, waiter)
+		}
+	}
+	if len(waiters) == 0 {
+		delete(w.invoices, id)
+	} else {
+		w.invoices[id] = waiters
+	}
+}
+
+func isInvoiceResolved(invoice *Invoice) bool {
+	return invoice.IsPaid() || invoice.State == "ARCHIVED"
+}
+
+// PaylinkUpdated resolves the waiters of the paylink when it is in a final state, this is called by the poller and
+// the webhook but can also be used when updates are received in another way.
+func (w *PaymentWaiter) PaylinkUpdated(paylink *Paylink) {
+	if !paylink.IsFinal() {
+		return
+	}
+	w.mu.Lock()
+	waiters := w.paylinks[paylink.Id]
+	delete(w.paylinks, paylink.Id)
+	w.mu.Unlock()
+	for _, waiter := range waiters {
+		waiter <- paylink
+	}
+}
+
+// InvoiceUpdated resolves the waiters of the invoice when it is paid or archived, this is called by the poller and
+// the webhook but can also be used when updates are received in another way.
+func (w *PaymentWaiter) InvoiceUpdated(invoice *Invoice) {
+	if !isInvoiceResolved(invoice) {
+		return
+	}
+	w.mu.Lock()
+	waiters := w.invoices[invoice.Id]
+	delete(w.invoices, invoice.Id)
+	w.mu.Unlock()
+	for _, waiter := range waiters {
+		waiter <- invoice
+	}
+}
+
+// startPolling starts the shared poller unless it is running or disabled, the lock must be held
+func (w *PaymentWaiter) startPolling() {
+	if w.polling || w.PollInterval <= 0 {
+		return
+	}
+	w.polling = true
+	go w.poll(w.PollInterval)
+}
+
+// poll reads the feeds on every tick for as long as someone is waiting
+func (w *PaymentWaiter) poll(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.ctx.Done():
+			w.mu.Lock()
+			w.polling = false
+			w.mu.Unlock()
+			return
+		}
+
+		w.mu.Lock()
+		waitForPaylinks, waitForInvoices := len(w.paylinks) > 0, len(w.invoices) > 0
+		if !waitForPaylinks && !waitForInvoices {
+			w.polling = false
+			w.mu.Unlock()
+			return
+		}
+		w.mu.Unlock()
+
+		if waitForPaylinks {
+			err := w.client.PaylinkFeed(w.ctx, func(paylink *Paylink) {
+				if w.OnPaylink != nil {
+					w.OnPaylink(paylink)
+				}
+				w.PaylinkUpdated(paylink)
+			})
+			if err != nil {
+				w.client.Debug.Debugf("Unable to poll paylinks: %v", err)
+			}
+		}
+		if waitForInvoices {
+			err := w.client.InvoiceFeed(w.ctx, func(invoice *Invoice) {
+				if w.OnInvoice != nil {
+					w.OnInvoice(invoice)
+				}
+				w.InvoiceUpdated(invoice)
+			})
+			if err != nil {
+				w.client.Debug.Debugf("Unable to poll invoices: %v", err)
+			}
+		}
+	}
+}
+
+// ServeHTTP handles the webhook of Twikey. After verifying the signature, a paylink or invoice event for which
+// someone is waiting is resolved by fetching its current state. Other events are acknowledged and ignored.
+func (w *PaymentWaiter) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	payload := r.URL.RawQuery
+	if payload == "" && r.Body != nil {
+		body, _ := io.ReadAll(r.Body)
+		payload = string(body)
+	}
+	if err := w.client.VerifyWebhook(r.Header.Get("X-Signature"), payload); err != nil {
+		w.client.Debug.Debugf("Invalid webhook signature: %v", err)
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+	values, err := url.ParseQuery(payload)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	id := values.Get("id")
+	switch values.Get("type") {
+	case "paylink":
+		paylinkId, _ := strconv.ParseInt(id, 10, 64)
+		w.mu.Lock()
+		_, waiting := w.paylinks[paylinkId]
+		w.mu.Unlock()
+		if waiting {
+			if paylink, err := w.client.PaylinkDetail(r.Context(), &PaylinkLookup{Id: paylinkId}); err == nil {
+				w.PaylinkUpdated(paylink)
+			} else {
+				w.client.Debug.Debugf("Unable to fetch paylink %d: %v", paylinkId, err)
+			}
+		}
+	case "invoice":
+		w.mu.Lock()
+		_, waiting := w.invoices[id]
+		w.mu.Unlock()
+		if waiting {
+			if invoice, err := w.client.InvoiceDetail(r.Context(), id); err == nil {
+				w.InvoiceUpdated(invoice)
+			} else {
+				w.client.Debug.Debugf("Unable to fetch invoice %s: %v", id, err)
+			}
+		}
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
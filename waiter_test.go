@@ -0,0 +1,177 @@
+package twikey
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWaitForPaylinkViaPoller(t *testing.T) {
+	var feedCalls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/creditor/payment/link":
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"id":%s,"state":"created"}`, r.URL.Query().Get("id"))))
+		case "/creditor/payment/link/feed":
+			// the first read returns all updates, the next ones are empty
+			if atomic.AddInt32(&feedCalls, 1)%2 == 1 {
+				links := make([]string, 0, 100)
+				for i := 1; i <= 100; i++ {
+					links = append(links, fmt.Sprintf(`{"id":%d,"state":"paid"}`, i))
+				}
+				_, _ = w.Write([]byte(`{"Links":[` + strings.Join(links, ",") + `]}`))
+			} else {
+				_, _ = w.Write([]byte(`{"Links":[]}`))
+			}
+		default:
+			t.Errorf("Unexpected call %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+
+	waiter := cl.NewPaymentWaiter()
+	defer waiter.Close()
+	waiter.PollInterval = 20 * time.Millisecond
+
+	var wg sync.WaitGroup
+	var paid int32
+	for i := 1; i <= 100; i++ {
+		wg.Add(1)
+		go func(id int64) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			paylink, err := waiter.WaitForPaylink(ctx, id)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if paylink.Id == id && paylink.IsPaid() {
+				atomic.AddInt32(&paid, 1)
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+	AssertEquals(t, int32(100), paid)
+	// all waiters share the poller
+	if calls := atomic.LoadInt32(&feedCalls); calls > 10 {
+		t.Errorf("Expected a shared poller, got %d feed calls", calls)
+	}
+}
+
+func TestWaitForInvoicePaidViaWebhook(t *testing.T) {
+	var paid int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AssertEquals(t, "/creditor/invoice/inv1", r.URL.Path)
+		state := "BOOKED"
+		if atomic.LoadInt32(&paid) == 1 {
+			state = "PAID"
+		}
+		_, _ = w.Write([]byte(`{"id":"inv1","state":"` + state + `"}`))
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+
+	waiter := cl.NewPaymentWaiter()
+	defer waiter.Close()
+
+	done := make(chan *Invoice)
+	go func() {
+		invoice, err := waiter.WaitForInvoicePaid(context.Background(), "inv1")
+		if err != nil {
+			t.Error(err)
+		}
+		done <- invoice
+	}()
+
+	// wait until registered
+	for {
+		waiter.mu.Lock()
+		registered := len(waiter.invoices) == 1
+		waiter.mu.Unlock()
+		if registered {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	payload := "type=invoice&id=inv1"
+	hash := hmac.New(sha256.New, []byte(cl.APIKey))
+	hash.Write([]byte(payload))
+	signature := strings.ToUpper(hex.EncodeToString(hash.Sum(nil)))
+
+	recorder := httptest.NewRecorder()
+	waiter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/webhook?"+payload, nil))
+	AssertEquals(t, http.StatusForbidden, recorder.Code)
+
+	atomic.StoreInt32(&paid, 1)
+	request := httptest.NewRequest(http.MethodGet, "/webhook?"+payload, nil)
+	request.Header.Set("X-Signature", signature)
+	recorder = httptest.NewRecorder()
+	waiter.ServeHTTP(recorder, request)
+	AssertEquals(t, http.StatusNoContent, recorder.Code)
+
+	select {
+	case invoice := <-done:
+		AssertEquals(t, true, invoice.IsPaid())
+	case <-time.After(time.Second):
+		t.Fatal("Waiter was not resolved by the webhook")
+	}
+}
+
+func TestWaitForPaylinkTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id":1,"state":"started"}`))
+	}))
+	defer server.Close()
+	cl := NewMockedTestClient(server)
+
+	waiter := cl.NewPaymentWaiter()
+	defer waiter.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := waiter.WaitForPaylink(ctx, 1)
+	AssertEquals(t, context.DeadlineExceeded, err)
+	AssertEquals(t, 0, len(waiter.paylinks))
+}
+
+func TestWaitForInvoicePaidWebhookDuringCheck(t *testing.T) {
+	var waiter *PaymentWaiter
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// the invoice is paid while its current state is being checked
+			payload := "type=invoice&id=inv1"
+			hash := hmac.New(sha256.New, []byte("TEST_API_KEY"))
+			hash.Write([]byte(payload))
+			request := httptest.NewRequest(http.MethodGet, "/webhook?"+payload, nil)
+			request.Header.Set("X-Signature", strings.ToUpper(hex.EncodeToString(hash.Sum(nil))))
+			waiter.ServeHTTP(httptest.NewRecorder(), request)
+			_, _ = w.Write([]byte(`{"id":"inv1","state":"BOOKED"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"inv1","state":"PAID"}`))
+	}))
+	defer server.Close()
+	waiter = NewMockedTestClient(server).NewPaymentWaiter()
+	defer waiter.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	invoice, err := waiter.WaitForInvoicePaid(ctx, "inv1")
+	if err != nil {
+		t.Fatalf("Expected the webhook received during the check to resolve the waiter, got %v", err)
+	}
+	AssertEquals(t, true, invoice.IsPaid())
+}